@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// errCallTimeout marks an AWS call that got no response within --call-timeout, as opposed to
+// the whole run being interrupted or hitting --timeout
+var errCallTimeout = errors.New("AWS call timed out")
+
+// callTimeoutRetries is how many more times a call that hit --call-timeout is tried
+const callTimeoutRetries = 2
+
+// withCallTimeout bounds every AWS API call made by clients built from cfg, such as one
+// DescribeInstances page or one CreateTags, to timeout (--call-timeout). 0 leaves calls unbounded.
+func withCallTimeout(cfg *aws.Config, timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+	cfg.APIOptions = append(cfg.APIOptions, func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(callTimeoutMiddleware(timeout), middleware.After)
+	})
+}
+
+// callTimeoutMiddleware runs each call, including the SDK's own retries, under a derived
+// deadline and tries it again when that deadline passes. The run's own context still wins:
+// an interrupt or --timeout stops it without a retry.
+func callTimeoutMiddleware(timeout time.Duration) middleware.InitializeMiddleware {
+	return middleware.InitializeMiddlewareFunc("CallTimeout", func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+		operation := awsmiddleware.GetOperationName(ctx)
+		for attempt := 1; ; attempt++ {
+			callCtx, cancel := context.WithTimeout(ctx, timeout)
+			out, metadata, err := next.HandleInitialize(callCtx, in)
+			timedOut := err != nil && errors.Is(callCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil
+			cancel()
+			if !timedOut {
+				return out, metadata, err
+			}
+			if attempt > callTimeoutRetries {
+				return out, metadata, fmt.Errorf("%s got no response within --call-timeout %s after %d attempts: %w", operation, timeout, attempt, errCallTimeout)
+			}
+			warnf("%s got no response within --call-timeout %s, retrying (%d of %d)", operation, timeout, attempt, callTimeoutRetries)
+		}
+	})
+}
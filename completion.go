@@ -0,0 +1,145 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// completionCommand is the program name that generated completion scripts register for
+const completionCommand = "quick-tag"
+
+// validCompletionShells lists the shells `completion` can generate scripts for
+var validCompletionShells = []string{"bash", "zsh", "fish"}
+
+// completionFlag is one flag as seen by the completion generators
+type completionFlag struct {
+	name   string
+	usage  string
+	isBool bool
+	values []string // Fixed values to offer for the flag's argument, if any
+}
+
+// completionFlags collects the flags of fs in name order, with fixed values for the
+// flags that only accept a known set
+func completionFlags(fs *flag.FlagSet) []completionFlag {
+	values := map[string][]string{
+		"region":     knownRegions,
+		"engine":     validEngines,
+		"log-format": validLogFormats,
+		"output":     validOutputFormats,
+		"sort":       validSortKeys,
+	}
+
+	var flags []completionFlag
+	fs.VisitAll(func(f *flag.Flag) {
+		boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+		flags = append(flags, completionFlag{
+			name:   f.Name,
+			usage:  f.Usage,
+			isBool: ok && boolFlag.IsBoolFlag(),
+			values: values[f.Name],
+		})
+	})
+	return flags
+}
+
+// writeCompletion writes the completion script for shell, covering the flags of fs
+func writeCompletion(w io.Writer, shell string, fs *flag.FlagSet) error {
+	flags := completionFlags(fs)
+	switch shell {
+	case "bash":
+		writeBashCompletion(w, flags)
+	case "zsh":
+		writeZshCompletion(w, flags)
+	case "fish":
+		writeFishCompletion(w, flags)
+	default:
+		return fmt.Errorf("invalid completion shell %q: must be %s", shell, strings.Join(validCompletionShells, ", "))
+	}
+	return nil
+}
+
+// writeBashCompletion writes a bash completion function for flag names and fixed values
+func writeBashCompletion(w io.Writer, flags []completionFlag) {
+	var names []string
+	for _, f := range flags {
+		names = append(names, "--"+f.name)
+	}
+
+	fmt.Fprintf(w, "# bash completion for %s\n", completionCommand)
+	fmt.Fprintln(w, "_quick_tag() {")
+	fmt.Fprintln(w, `    local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"`)
+	fmt.Fprintln(w, `    case "$prev" in`)
+	for _, f := range flags {
+		if len(f.values) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "        --%s|-%s)\n", f.name, f.name)
+		fmt.Fprintf(w, "            COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(f.values, " "))
+		fmt.Fprintln(w, "            return ;;")
+	}
+	fmt.Fprintln(w, "    esac")
+	fmt.Fprintln(w, `    if [[ "$cur" == -* ]]; then`)
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(names, " "))
+	fmt.Fprintln(w, "    elif [[ $COMP_CWORD -eq 1 ]]; then")
+	fmt.Fprintln(w, `        COMPREPLY=($(compgen -W "completion" -- "$cur"))`)
+	fmt.Fprintln(w, `    elif [[ "$prev" == completion ]]; then`)
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(validCompletionShells, " "))
+	fmt.Fprintln(w, "    fi")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintf(w, "complete -o default -F _quick_tag %s\n", completionCommand)
+}
+
+// writeZshCompletion writes a zsh completion function using _arguments
+func writeZshCompletion(w io.Writer, flags []completionFlag) {
+	fmt.Fprintf(w, "#compdef %s\n\n", completionCommand)
+	fmt.Fprintln(w, "_arguments \\")
+	for _, f := range flags {
+		spec := fmt.Sprintf("--%s[%s]", f.name, zshEscape(f.usage))
+		switch {
+		case f.isBool:
+		case len(f.values) > 0:
+			spec += fmt.Sprintf(":%s:(%s)", f.name, strings.Join(f.values, " "))
+		default:
+			spec += fmt.Sprintf(":%s:_default", f.name)
+		}
+		fmt.Fprintf(w, "  %s \\\n", shellQuote(spec))
+	}
+	fmt.Fprintf(w, "  '1:command:(completion)' \\\n")
+	fmt.Fprintf(w, "  '2:shell:(%s)'\n", strings.Join(validCompletionShells, " "))
+}
+
+// writeFishCompletion writes fish `complete` commands for each flag
+func writeFishCompletion(w io.Writer, flags []completionFlag) {
+	fmt.Fprintf(w, "# fish completion for %s\n", completionCommand)
+	fmt.Fprintf(w, "complete -c %s -n __fish_use_subcommand -a completion -d 'Print a shell completion script'\n", completionCommand)
+	fmt.Fprintf(w, "complete -c %s -n '__fish_seen_subcommand_from completion' -x -a '%s'\n", completionCommand, strings.Join(validCompletionShells, " "))
+	for _, f := range flags {
+		line := fmt.Sprintf("complete -c %s -l %s -d %s", completionCommand, f.name, fishQuote(f.usage))
+		switch {
+		case f.isBool:
+		case len(f.values) > 0:
+			line += fmt.Sprintf(" -x -a '%s'", strings.Join(f.values, " "))
+		default:
+			line += " -r"
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// zshEscape escapes the characters that end an _arguments description
+func zshEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, ":", `\:`).Replace(s)
+}
+
+// shellQuote single-quotes s for bash and zsh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// fishQuote single-quotes s for fish, which allows \' and \\ inside single quotes
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
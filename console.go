@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// consoleFragments maps each resource type to its console service and the page fragment for
+// one resource, with %s standing for the resource ID
+var consoleFragments = map[string]struct{ service, fragment string }{
+	"instance":    {"ec2", "InstanceDetails:instanceId=%s"},
+	"volume":      {"ec2", "VolumeDetails:volumeId=%s"},
+	"eni":         {"ec2", "NetworkInterface:networkInterfaceId=%s"},
+	"eip":         {"ec2", "ElasticIpDetails:AllocationId=%s"},
+	"vpc":         {"vpcconsole", "VpcDetails:VpcId=%s"},
+	"subnet":      {"vpcconsole", "SubnetDetails:subnetId=%s"},
+	"route-table": {"vpcconsole", "RouteTableDetails:RouteTableId=%s"},
+	"nat-gateway": {"vpcconsole", "NatGatewayDetails:natGatewayId=%s"},
+	"igw":         {"vpcconsole", "InternetGateway:internetGatewayId=%s"},
+	"tgw":         {"vpcconsole", "TransitGatewayDetails:transitGatewayId=%s"},
+	"kms-key":     {"kms", "/kms/keys/%s"},
+}
+
+// consoleURL returns the AWS console page for a resource, e.g.
+// https://us-east-1.console.aws.amazon.com/ec2/home?region=us-east-1#InstanceDetails:instanceId=i-0abc,
+// or "" for a type the console has no page for
+func consoleURL(resourceType, id, region string) string {
+	page, ok := consoleFragments[resourceType]
+	if !ok || id == "" || region == "" {
+		return ""
+	}
+
+	// China and GovCloud regions have their own console domains
+	domain := "console.aws.amazon.com"
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		domain = "console.amazonaws.cn"
+	case strings.HasPrefix(region, "us-gov-"):
+		domain = "console.amazonaws-us-gov.com"
+	}
+
+	return fmt.Sprintf("https://%s.%s/%s/home?region=%s#%s",
+		region, domain, page.service, url.QueryEscape(region), fmt.Sprintf(page.fragment, url.PathEscape(id)))
+}
+
+// hyperlink wraps text in an OSC 8 escape so terminals that support it make it clickable.
+// Plain output (--no-color, pipes) gets the text unchanged.
+func hyperlink(text, target string) string {
+	if noColor {
+		return text
+	}
+	return "\x1b]8;;" + target + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	qc "github.com/bevelwork/quick_color"
+)
+
+// hoursPerMonth is the month length AWS uses for its own monthly estimates
+const hoursPerMonth = 730
+
+// instanceHourlyRates is a static rate card of us-east-1 on-demand Linux prices in USD per
+// hour for common instance types. It's only meant to give --with-cost a ballpark figure.
+var instanceHourlyRates = map[string]float64{
+	"t2.micro": 0.0116, "t2.small": 0.023, "t2.medium": 0.0464, "t2.large": 0.0928,
+	"t3.nano": 0.0052, "t3.micro": 0.0104, "t3.small": 0.0208, "t3.medium": 0.0416, "t3.large": 0.0832, "t3.xlarge": 0.1664, "t3.2xlarge": 0.3328,
+	"t3a.micro": 0.0094, "t3a.small": 0.0188, "t3a.medium": 0.0376, "t3a.large": 0.0752, "t3a.xlarge": 0.1504,
+	"t4g.nano": 0.0042, "t4g.micro": 0.0084, "t4g.small": 0.0168, "t4g.medium": 0.0336, "t4g.large": 0.0672, "t4g.xlarge": 0.1344,
+	"m5.large": 0.096, "m5.xlarge": 0.192, "m5.2xlarge": 0.384, "m5.4xlarge": 0.768,
+	"m6i.large": 0.096, "m6i.xlarge": 0.192, "m6i.2xlarge": 0.384,
+	"m6g.large": 0.077, "m6g.xlarge": 0.154,
+	"m7i.large": 0.1008, "m7i.xlarge": 0.2016,
+	"m7g.large": 0.0816, "m7g.xlarge": 0.1632,
+	"c5.large": 0.085, "c5.xlarge": 0.17, "c5.2xlarge": 0.34,
+	"c6i.large": 0.085, "c6i.xlarge": 0.17,
+	"c6g.large": 0.068, "c6g.xlarge": 0.136,
+	"c7g.large": 0.0725,
+	"r5.large":  0.126, "r5.xlarge": 0.252, "r5.2xlarge": 0.504,
+	"r6i.large": 0.126, "r6i.xlarge": 0.252,
+	"r6g.large": 0.1008, "r6g.xlarge": 0.2016,
+}
+
+// volumeMonthlyRates are us-east-1 EBS storage prices in USD per GB-month by volume type.
+// Provisioned IOPS and throughput charges aren't included.
+var volumeMonthlyRates = map[string]float64{
+	"gp2":      0.10,
+	"gp3":      0.08,
+	"io1":      0.125,
+	"io2":      0.125,
+	"st1":      0.045,
+	"sc1":      0.015,
+	"standard": 0.05,
+}
+
+// instanceCost estimates an instance's monthly cost for --with-cost, or returns nil when cost
+// wasn't asked for or the type isn't on the rate card. Stopped instances cost nothing here;
+// their storage is counted on their volumes.
+func (c *Config) instanceCost(instanceType types.InstanceType, state types.InstanceStateName) *float64 {
+	if !c.WithCost {
+		return nil
+	}
+	if state != types.InstanceStateNameRunning && state != types.InstanceStateNamePending {
+		return aws.Float64(0)
+	}
+	rate, ok := instanceHourlyRates[string(instanceType)]
+	if !ok {
+		return nil
+	}
+	return aws.Float64(rate * hoursPerMonth)
+}
+
+// volumeCost estimates a volume's monthly storage cost for --with-cost, or returns nil when
+// cost wasn't asked for or the volume type isn't on the rate card
+func (c *Config) volumeCost(volume types.Volume) *float64 {
+	if !c.WithCost {
+		return nil
+	}
+	rate, ok := volumeMonthlyRates[string(volume.VolumeType)]
+	if !ok {
+		return nil
+	}
+	return aws.Float64(rate * float64(aws.ToInt32(volume.Size)))
+}
+
+// formatDollars formats an amount in USD with thousands separators, e.g. 1234.5 -> "$1,234.50"
+func formatDollars(amount float64) string {
+	cents := int(math.Round(amount * 100))
+	return fmt.Sprintf("$%s.%02d", formatCount(cents/100), cents%100)
+}
+
+// costNote returns a " ~$12.34/mo" note with a resource's estimated cost, or "" when it has none
+func costNote(resource *ResourceInfo) string {
+	if resource.MonthlyCost == nil {
+		return ""
+	}
+	return " " + color("~"+formatDollars(*resource.MonthlyCost)+"/mo", qc.ColorYellow)
+}
+
+// hasCostEstimates reports whether any resource has an estimated cost, i.e. --with-cost was given
+func hasCostEstimates(resources []*ResourceInfo) bool {
+	return slices.ContainsFunc(resources, func(resource *ResourceInfo) bool { return resource.MonthlyCost != nil })
+}
+
+// printCostSummary prints the estimated monthly cost of the listed instances and volumes
+// (--with-cost), and how many of them weren't on the rate card. It prints nothing when no
+// resource has an estimate.
+func printCostSummary(w io.Writer, resources []*ResourceInfo) {
+	if !hasCostEstimates(resources) {
+		return
+	}
+	total, priced, unpriced := 0.0, 0, 0
+	for _, resource := range resources {
+		if resource.Type != "instance" && resource.Type != "volume" {
+			continue
+		}
+		if resource.MonthlyCost == nil {
+			unpriced++
+			continue
+		}
+		total += *resource.MonthlyCost
+		priced++
+	}
+
+	fmt.Fprintf(w, "%s Estimated monthly cost of these untagged instances and volumes: %s (%d priced at us-east-1 on-demand rates",
+		color("💰", qc.ColorYellow), color(formatDollars(total), qc.ColorYellow), priced)
+	if unpriced > 0 {
+		fmt.Fprintf(w, "; %d not on the rate card", unpriced)
+	}
+	fmt.Fprintln(w, ")")
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	cetypes "github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	qc "github.com/bevelwork/quick_color"
+)
+
+// newCostExplorerClient creates a Cost Explorer client, honoring a custom endpoint URL. Cost
+// Explorer is only served from us-east-1, whatever region is being tagged.
+func newCostExplorerClient(cfg aws.Config, endpointURL string) *costexplorer.Client {
+	return costexplorer.NewFromConfig(cfg, func(o *costexplorer.Options) {
+		o.Region = fallbackRegion
+		if endpointURL != "" {
+			debugf("Cost Explorer: using endpoint %s", endpointURL)
+			o.BaseEndpoint = stringPtr(endpointURL)
+		}
+	})
+}
+
+// costAllocationTagStatus returns whether tagKey is active for cost allocation. An empty
+// status means billing hasn't seen the key yet, so it can't be activated.
+func costAllocationTagStatus(ctx context.Context, client costexplorer.ListCostAllocationTagsAPIClient, tagKey string) (cetypes.CostAllocationTagStatus, error) {
+	paginator := costexplorer.NewListCostAllocationTagsPaginator(client, &costexplorer.ListCostAllocationTagsInput{
+		TagKeys: []string{tagKey},
+		Type:    cetypes.CostAllocationTagTypeUserDefined,
+	})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return "", wrapPermissionError(err, "ce:ListCostAllocationTags", "cost allocation tags")
+		}
+		for _, tag := range output.CostAllocationTags {
+			if aws.ToString(tag.TagKey) == tagKey {
+				return tag.Status, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// printCostTagNudge reports whether tagKey is active for cost allocation (--check-cost-tags),
+// with how to activate it if not. A failed check is only a warning; the tags are already applied.
+func printCostTagNudge(ctx context.Context, w io.Writer, client costexplorer.ListCostAllocationTagsAPIClient, tagKey string) {
+	status, err := costAllocationTagStatus(ctx, client, tagKey)
+	if err != nil {
+		warnf("Couldn't check cost allocation tags: %v", err)
+		return
+	}
+
+	switch status {
+	case cetypes.CostAllocationTagStatusActive:
+		fmt.Fprintf(w, "%s The %s tag is active for cost allocation, so it will show up in Cost Explorer and billing reports.\n", color("✅", qc.ColorGreen), tagKey)
+	case cetypes.CostAllocationTagStatusInactive:
+		fmt.Fprintf(w, "%s The %s tag isn't active for cost allocation. Activate it under Billing > Cost allocation tags, or run:\n", color("💡", qc.ColorYellow), tagKey)
+		fmt.Fprintf(w, "   aws ce update-cost-allocation-tags-status --cost-allocation-tags-status TagKey=%s,Status=Active\n", tagKey)
+	default:
+		fmt.Fprintf(w, "%s The %s tag hasn't reached billing data yet; new tag keys take up to 24 hours to appear.\n", color("💡", qc.ColorYellow), tagKey)
+		fmt.Fprintf(w, "   Then activate it under Billing > Cost allocation tags so costs can be grouped by it.\n")
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Defaults holds the last-used settings, loaded as flag defaults on the next run
+type Defaults struct {
+	Region  string `yaml:"Region,omitempty"`
+	Profile string `yaml:"Profile,omitempty"`
+}
+
+// getDefaultsFilePath returns the defaults file path, from $QUICK_TAG_CONFIG or ~/.quick-tag-config.yml
+func getDefaultsFilePath() string {
+	if envPath := os.Getenv("QUICK_TAG_CONFIG"); envPath != "" {
+		return envPath
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".quick-tag-config.yml")
+}
+
+// loadDefaults reads the defaults file; a missing file means no saved defaults
+func loadDefaults(path string) (*Defaults, error) {
+	defaults := &Defaults{}
+	if path == "" {
+		return defaults, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return defaults, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read defaults file: %v", err)
+	}
+
+	if err := yaml.Unmarshal(data, defaults); err != nil {
+		return nil, fmt.Errorf("failed to parse defaults file: %v", err)
+	}
+	return defaults, nil
+}
+
+// saveDefaults writes the defaults file
+func saveDefaults(path string, defaults *Defaults) error {
+	if path == "" {
+		return fmt.Errorf("unable to determine defaults file path")
+	}
+
+	data, err := yaml.Marshal(defaults)
+	if err != nil {
+		return fmt.Errorf("failed to marshal defaults: %v", err)
+	}
+	return writeFileAtomic(path, data, 0644)
+}
+
+// resolveDefault picks a setting's value: an explicitly set flag always wins, then
+// the saved default, then the flag's own default
+func resolveDefault(flagValue string, explicit bool, saved string) string {
+	if explicit || saved == "" {
+		return flagValue
+	}
+	return saved
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
+)
+
+// ec2DescribeAPI is the subset of the EC2 client used for ID lookups
+type ec2DescribeAPI interface {
+	DescribeImages(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error)
+	DescribeInstances(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error)
+}
+
+// describeCache memoizes DescribeImages and DescribeInstances results by ID for a single run,
+// so AMI and instance lookups are shared across the instance, volume, and ENI scans
+type describeCache struct {
+	client    ec2DescribeAPI
+	images    map[string]*types.Image    // nil value means looked up but not found
+	instances map[string]*types.Instance // nil value means looked up but not found
+}
+
+// newDescribeCache creates an empty cache backed by the given client
+func newDescribeCache(client ec2DescribeAPI) *describeCache {
+	return &describeCache{
+		client:    client,
+		images:    make(map[string]*types.Image),
+		instances: make(map[string]*types.Instance),
+	}
+}
+
+// addInstances records instances already fetched elsewhere (e.g. by the instance scan)
+func (c *describeCache) addInstances(instances ...types.Instance) {
+	for i := range instances {
+		if instances[i].InstanceId != nil {
+			c.instances[*instances[i].InstanceId] = &instances[i]
+		}
+	}
+}
+
+// describeImages returns the images for the given AMI IDs, only calling AWS for uncached IDs
+func (c *describeCache) describeImages(ctx context.Context, amiIDs []string) (map[string]types.Image, error) {
+	var missing []string
+	for _, amiID := range amiIDs {
+		if _, cached := c.images[amiID]; !cached {
+			missing = append(missing, amiID)
+		}
+	}
+
+	// Describe AMIs in batches (AWS limit is 200 per request)
+	batchSize := 200
+	for i := 0; i < len(missing); i += batchSize {
+		end := min(i+batchSize, len(missing))
+		batch := missing[i:end]
+
+		for len(batch) > 0 {
+			debugf("DescribeImages: %d AMI IDs %v", len(batch), batch)
+			output, err := c.client.DescribeImages(ctx, &ec2.DescribeImagesInput{
+				ImageIds: batch,
+			})
+			if err != nil {
+				// One deregistered or private AMI fails the whole batch, so drop the IDs the
+				// error names and retry the rest; those instances fall back to their AMI ID
+				invalid := invalidAMIIDs(err, batch)
+				if len(invalid) == 0 {
+					return nil, err
+				}
+				debugf("DescribeImages: dropping %v and retrying: %v", invalid, err)
+				for _, amiID := range invalid {
+					c.images[amiID] = nil
+				}
+				batch = slices.DeleteFunc(slices.Clone(batch), func(amiID string) bool {
+					return slices.Contains(invalid, amiID)
+				})
+				continue
+			}
+
+			for _, amiID := range batch {
+				c.images[amiID] = nil
+			}
+			for j := range output.Images {
+				if output.Images[j].ImageId != nil {
+					c.images[*output.Images[j].ImageId] = &output.Images[j]
+				}
+			}
+			break
+		}
+	}
+
+	images := make(map[string]types.Image)
+	for _, amiID := range amiIDs {
+		if image := c.images[amiID]; image != nil {
+			images[amiID] = *image
+		}
+	}
+	return images, nil
+}
+
+// amiIDPattern matches AMI IDs in DescribeImages error messages
+var amiIDPattern = regexp.MustCompile(`ami-[0-9A-Za-z]+`)
+
+// invalidAMIIDs returns the IDs from batch that an InvalidAMIID.* error names, e.g.
+// "The image id '[ami-0abc1234]' does not exist"
+func invalidAMIIDs(err error, batch []string) []string {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) || !strings.HasPrefix(apiErr.ErrorCode(), "InvalidAMIID.") {
+		return nil
+	}
+	var invalid []string
+	for _, amiID := range amiIDPattern.FindAllString(apiErr.ErrorMessage(), -1) {
+		if slices.Contains(batch, amiID) && !slices.Contains(invalid, amiID) {
+			invalid = append(invalid, amiID)
+		}
+	}
+	return invalid
+}
+
+// describeInstances returns the instances for the given IDs, only calling AWS for uncached IDs
+func (c *describeCache) describeInstances(ctx context.Context, instanceIDs []string) (map[string]types.Instance, error) {
+	var missing []string
+	for _, instanceID := range instanceIDs {
+		if _, cached := c.instances[instanceID]; !cached {
+			missing = append(missing, instanceID)
+		}
+	}
+
+	// Describe instances in batches (AWS limit is 1000 per request)
+	batchSize := 1000
+	for i := 0; i < len(missing); i += batchSize {
+		end := min(i+batchSize, len(missing))
+		batch := missing[i:end]
+
+		debugf("DescribeInstances: %d instance IDs %v", len(batch), batch)
+		output, err := c.client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+			InstanceIds: batch,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, instanceID := range batch {
+			c.instances[instanceID] = nil
+		}
+		for _, reservation := range output.Reservations {
+			c.addInstances(reservation.Instances...)
+		}
+	}
+
+	instances := make(map[string]types.Instance)
+	for _, instanceID := range instanceIDs {
+		if instance := c.instances[instanceID]; instance != nil {
+			instances[instanceID] = *instance
+		}
+	}
+	return instances, nil
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/bevelwork/quick_tag/naming"
+)
+
+// kmsAPI is the subset of the KMS client used to find and tag customer managed keys
+type kmsAPI interface {
+	ListKeys(ctx context.Context, params *kms.ListKeysInput, optFns ...func(*kms.Options)) (*kms.ListKeysOutput, error)
+	ListAliases(ctx context.Context, params *kms.ListAliasesInput, optFns ...func(*kms.Options)) (*kms.ListAliasesOutput, error)
+	DescribeKey(ctx context.Context, params *kms.DescribeKeyInput, optFns ...func(*kms.Options)) (*kms.DescribeKeyOutput, error)
+	ListResourceTags(ctx context.Context, params *kms.ListResourceTagsInput, optFns ...func(*kms.Options)) (*kms.ListResourceTagsOutput, error)
+	TagResource(ctx context.Context, params *kms.TagResourceInput, optFns ...func(*kms.Options)) (*kms.TagResourceOutput, error)
+	UntagResource(ctx context.Context, params *kms.UntagResourceInput, optFns ...func(*kms.Options)) (*kms.UntagResourceOutput, error)
+}
+
+// newKMSClient creates a KMS client, honoring a custom endpoint URL
+func newKMSClient(cfg aws.Config, endpointURL string) *kms.Client {
+	return kms.NewFromConfig(cfg, func(o *kms.Options) {
+		if endpointURL != "" {
+			debugf("KMS: using endpoint %s", endpointURL)
+			o.BaseEndpoint = stringPtr(endpointURL)
+		}
+	})
+}
+
+// kmsKeyIDPattern matches KMS key IDs: a UUID, or "mrk-" and 32 hex digits for multi-Region keys
+var kmsKeyIDPattern = regexp.MustCompile(`^([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}|mrk-[0-9a-f]{32})$`)
+
+// isKMSKeyID reports whether a resource ID is a KMS key ID rather than an EC2 resource ID
+func isKMSKeyID(id string) bool {
+	return kmsKeyIDPattern.MatchString(id)
+}
+
+// findUntaggedKMSKeys finds customer managed KMS keys without Name tags, naming them after
+// their alias or description. KMS has no batch describe, so each key costs a DescribeKey and a
+// ListResourceTags call; keys behind an aws/ alias are AWS managed and skipped before either.
+// Keys pending deletion are skipped too. Keys whose key policy denies DescribeKey or
+// ListResourceTags are skipped with a warning, and a caller without kms:ListKeys skips KMS
+// entirely rather than failing the whole scan.
+func findUntaggedKMSKeys(ctx context.Context, config *Config) ([]*ResourceInfo, error) {
+	keyIDs := config.TargetIDs["kms-key"]
+	pages, describeCalls, tagPages, needName := 0, 0, 0, 0
+	if config.TargetIDs == nil {
+		paginator := kms.NewListKeysPaginator(config.KMSClient, &kms.ListKeysInput{})
+		debugf("ListKeys: scanning all KMS keys in %s", config.Region)
+		for morePages(paginator.HasMorePages(), pages, config.MaxPages, "ListKeys") {
+			output, err := paginator.NextPage(ctx)
+			if err != nil {
+				if classifyAWSError(err) == awsErrorAccessDenied {
+					warnf("Skipping KMS keys: %v", wrapPermissionError(err, "kms:ListKeys", "KMS keys"))
+					return nil, nil
+				}
+				return nil, err
+			}
+			pages++
+			for _, key := range output.Keys {
+				if key.KeyId != nil {
+					keyIDs = append(keyIDs, *key.KeyId)
+				}
+			}
+			config.reportProgress(len(keyIDs))
+		}
+	}
+	if len(keyIDs) == 0 {
+		return nil, nil
+	}
+
+	aliases, awsManaged, aliasPages, err := kmsKeyAliases(ctx, config.KMSClient, config.MaxPages)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*ResourceInfo
+	for _, keyID := range keyIDs {
+		if awsManaged[keyID] {
+			continue
+		}
+		describeCalls++
+		described, err := config.KMSClient.DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: aws.String(keyID)})
+		if err != nil {
+			if classifyAWSError(err) == awsErrorAccessDenied {
+				warnf("Skipping KMS key %s: %v", keyID, wrapPermissionError(err, "kms:DescribeKey", "KMS keys"))
+				continue
+			}
+			return nil, err
+		}
+		metadata := described.KeyMetadata
+		if metadata == nil || metadata.KeyManager != kmstypes.KeyManagerTypeCustomer {
+			continue
+		}
+		if metadata.KeyState == kmstypes.KeyStatePendingDeletion || metadata.KeyState == kmstypes.KeyStatePendingReplicaDeletion {
+			continue
+		}
+		if !config.createdAfter(metadata.CreationDate) {
+			continue
+		}
+
+		tags, keyTagPages, err := kmsKeyTags(ctx, config.KMSClient, keyID, config.MaxPages)
+		tagPages += keyTagPages
+		if err != nil {
+			if classifyAWSError(err) == awsErrorAccessDenied {
+				warnf("Skipping KMS key %s: %v", keyID, wrapPermissionError(err, "kms:ListResourceTags", "KMS keys"))
+				continue
+			}
+			return nil, err
+		}
+
+		currentName, hasNameTag := tags["Name"]
+		baseName := config.baseName(currentName, keyID)
+		generated := naming.IsQuickTagCreatedName(baseName, "kms-key") || tags[markerTagKey] == "true"
+		suggestedName := naming.KMSKeyName(aliases[keyID], aws.ToString(metadata.Description), keyID)
+		needsTagging := config.PreviewNames || !hasNameTag || currentName == "" || (generated && baseName != suggestedName) || config.NamePolicy.violates("kms-key", currentName)
+		if !needsTagging {
+			continue
+		}
+
+		source := "key ID"
+		switch {
+		case aliases[keyID] != "":
+			source = "alias"
+		case aws.ToString(metadata.Description) != "":
+			source = "description"
+		}
+
+		needName++
+		keys = config.collect(keys, &ResourceInfo{
+			ID:            keyID,
+			Type:          "kms-key",
+			Name:          currentName,
+			EmptyName:     hasNameTag && currentName == "",
+			Tags:          tags,
+			SuggestedName: suggestedName,
+			State:         string(metadata.KeyState),
+			Extra:         aliases[keyID],
+			Source:        source,
+		})
+	}
+
+	debugf("KMS: %d keys checked, %d described, %d need tagging", len(keyIDs), describeCalls, needName)
+	config.Stats.recordScan("ListKeys", "kms-key", pages, len(keyIDs), needName)
+	config.Stats.recordScan("ListAliases", "kms-key", aliasPages, 0, 0)
+	config.Stats.recordScan("DescribeKey", "kms-key", describeCalls, 0, 0)
+	config.Stats.recordScan("ListResourceTags", "kms-key", tagPages, 0, 0)
+	logEvent(slog.LevelInfo, "scan finished", "type", "kms-key", "scanned", len(keyIDs), "need_name", needName)
+
+	return keys, nil
+}
+
+// kmsKeyAliases maps key IDs to their first alias name, without the "alias/" prefix, and
+// returns the IDs of keys behind AWS managed aliases (alias/aws/...) separately, along with
+// the number of ListAliases pages fetched
+func kmsKeyAliases(ctx context.Context, client kmsAPI, maxPages int) (map[string]string, map[string]bool, int, error) {
+	aliases := make(map[string]string)
+	awsManaged := make(map[string]bool)
+	paginator := kms.NewListAliasesPaginator(client, &kms.ListAliasesInput{})
+	pages := 0
+	for morePages(paginator.HasMorePages(), pages, maxPages, "ListAliases") {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			if classifyAWSError(err) == awsErrorAccessDenied {
+				warnf("Not naming KMS keys after aliases: %v", wrapPermissionError(err, "kms:ListAliases", "KMS aliases"))
+				return aliases, awsManaged, pages, nil
+			}
+			return nil, nil, pages, err
+		}
+		pages++
+		for _, alias := range output.Aliases {
+			name := strings.TrimPrefix(aws.ToString(alias.AliasName), "alias/")
+			if alias.TargetKeyId == nil {
+				continue
+			}
+			if strings.HasPrefix(name, "aws/") {
+				awsManaged[*alias.TargetKeyId] = true
+				continue
+			}
+			if _, exists := aliases[*alias.TargetKeyId]; !exists {
+				aliases[*alias.TargetKeyId] = name
+			}
+		}
+	}
+	return aliases, awsManaged, pages, nil
+}
+
+// kmsKeyTags returns all tags on a KMS key as a key/value map, and the number of
+// ListResourceTags pages fetched (at most maxPages when it's above 0)
+func kmsKeyTags(ctx context.Context, client kmsAPI, keyID string, maxPages int) (map[string]string, int, error) {
+	tags := make(map[string]string)
+	paginator := kms.NewListResourceTagsPaginator(client, &kms.ListResourceTagsInput{KeyId: aws.String(keyID)})
+	pages := 0
+	for morePages(paginator.HasMorePages(), pages, maxPages, "ListResourceTags") {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, pages, err
+		}
+		pages++
+		for _, tag := range output.Tags {
+			tags[aws.ToString(tag.TagKey)] = aws.ToString(tag.TagValue)
+		}
+	}
+	return tags, pages, nil
+}
+
+// kmsResourceTags returns the current tags of each KMS key, like getTags
+func kmsResourceTags(ctx context.Context, client kmsAPI, keyIDs []string) (map[string]map[string]string, error) {
+	tags := make(map[string]map[string]string)
+	for _, keyID := range keyIDs {
+		keyTags, _, err := kmsKeyTags(ctx, client, keyID, 0)
+		if err != nil {
+			return nil, wrapPermissionError(err, "kms:ListResourceTags", "KMS keys")
+		}
+		tags[keyID] = keyTags
+	}
+	return tags, nil
+}
+
+// tagKMSKeys sets the given tags on each KMS key; KMS has no batch tagging call
+func tagKMSKeys(ctx context.Context, client kmsAPI, keyIDs []string, tags []kmstypes.Tag) error {
+	for _, keyID := range keyIDs {
+		debugf("TagResource: %s (%d tags)", keyID, len(tags))
+		if _, err := client.TagResource(ctx, &kms.TagResourceInput{KeyId: aws.String(keyID), Tags: tags}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// kmsTags converts EC2 tags, such as Name and --extra-tag tags, to KMS tags
+func kmsTags(tags []types.Tag) []kmstypes.Tag {
+	converted := make([]kmstypes.Tag, len(tags))
+	for i, tag := range tags {
+		converted[i] = kmstypes.Tag{TagKey: tag.Key, TagValue: tag.Value}
+	}
+	return converted
+}
+
+// revertKMSKey undoes one history action on a KMS key, as revertTags describes
+func revertKMSKey(ctx context.Context, client kmsAPI, action TagHistoryEntry) error {
+	restore, remove := revertTags(action)
+	if len(restore) > 0 {
+		if err := tagKMSKeys(ctx, client, []string{action.Resource}, kmsTags(restore)); err != nil {
+			return wrapPermissionError(err, "kms:TagResource", action.Resource)
+		}
+	}
+	if len(remove) == 0 {
+		return nil
+	}
+	debugf("UntagResource: %s %s", action.Resource, strings.Join(remove, ","))
+	if _, err := client.UntagResource(ctx, &kms.UntagResourceInput{KeyId: aws.String(action.Resource), TagKeys: remove}); err != nil {
+		return fmt.Errorf("failed to remove tags: %w", wrapPermissionError(err, "kms:UntagResource", action.Resource))
+	}
+	return nil
+}
@@ -1,546 +1,2620 @@
 // Package main provides a command-line tool for quickly tagging AWS EC2 instances,
-// EBS volumes, and ENIs that don't have Name tags. The tool scans all resources and
+// EBS volumes, ENIs, Elastic IPs, VPCs, subnets, route tables, NAT gateways, internet gateways,
+// transit gateways, and KMS keys that don't have Name tags. The tool scans all resources and
 // provides an interactive interface for creating appropriate Name tags.
 
 package main
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"maps"
 	"os"
+	"os/signal"
+	"os/user"
 	"path/filepath"
+	"runtime/debug"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
 	qc "github.com/bevelwork/quick_color"
+	"github.com/bevelwork/quick_tag/naming"
 	versionpkg "github.com/bevelwork/quick_tag/version"
+	"golang.org/x/term"
 	"gopkg.in/yaml.v3"
 )
 
 // Use quick_color (aliased as qc) directly for colors
 
 // ResourceInfo represents a resource that needs tagging
+// Plans serialize it as JSON and --output yaml as YAML, so both share one field set.
 type ResourceInfo struct {
-	ID            string // Resource ID
-	Type          string // "instance", "volume", or "eni"
-	Name          string // Current name (if any)
-	SuggestedName string // Suggested name based on rules
-	State         string // Resource state
-	Extra         string // Additional info (AMI for instances, mount point for volumes, attachment info for ENIs)
+	ID            string            `yaml:"ID" json:"ID"`                                       // Resource ID
+	Type          string            `yaml:"Type" json:"Type"`                                   // "instance", "volume", "eni", "eip", "vpc", "subnet", "route-table", "nat-gateway", "igw", "tgw", or "kms-key"
+	Name          string            `yaml:"Name" json:"Name"`                                   // Current name (if any)
+	SuggestedName string            `yaml:"SuggestedName" json:"SuggestedName"`                 // Suggested name based on rules
+	State         string            `yaml:"State" json:"State"`                                 // Resource state
+	Extra         string            `yaml:"Extra,omitempty" json:"Extra,omitempty"`             // Additional info (AMI for instances, mount point for volumes, attachment info for ENIs, public IP for EIPs)
+	InstanceID    string            `yaml:"InstanceID,omitempty" json:"InstanceID,omitempty"`   // Attached instance ID (volumes and EIPs only)
+	EmptyName     bool              `yaml:"EmptyName" json:"EmptyName"`                         // Name tag exists but its value is empty
+	Source        string            `yaml:"Source,omitempty" json:"Source,omitempty"`           // Where the suggested name came from, e.g. "AMI" or "instance i-0abc"
+	Tags          map[string]string `yaml:"-" json:"-"`                                         // All current tags, only an input to --name-template and --name-from-tag; never written out
+	Region        string            `yaml:"Region,omitempty" json:"Region,omitempty"`           // Region the resource is in; only set when scanning several regions
+	MonthlyCost   *float64          `yaml:"MonthlyCost,omitempty" json:"MonthlyCost,omitempty"` // Estimated USD per month (--with-cost; instances and volumes on the rate card only)
 }
 
 // Config holds AWS clients and application configuration
 type Config struct {
-	EC2Client   *ec2.Client
-	Region      string
-	PrivateMode bool
+	EC2Client         *ec2.Client
+	Describe          *describeCache
+	Region            string
+	PrivateMode       bool
+	HistoryFile       string
+	HistoryMax        int
+	ConfirmOver       int                 // Bulk confirmation only above this many tags, even with --yes (--confirm-over; 0 to disable)
+	NoHistory         bool                // Don't record tagging in the history file (--no-history)
+	TargetIDs         map[string][]string // Explicit resource IDs by type from --ids-from (nil scans everything)
+	Filters           []types.Filter      // Describe filters from --filter-tag, combined with AND
+	AssumeYes         bool                // Skip selection and confirmation prompts (--yes)
+	Concurrency       int                 // Parallel CreateTags workers when auto-applying (--concurrency)
+	ExtraTags         []types.Tag         // Fixed tags from --extra-tag, applied alongside Name
+	VerboseNames      bool                // Add instance type/platform and volume type/size to names (--verbose-names)
+	Stats             *runStats           // Scan and API call counters for --stats
+	TagPrefix         string              // Prepended to every suggested name (--tag-prefix)
+	TagSuffix         string              // Appended to every suggested name (--tag-suffix)
+	NameTemplate      string              // Builds suggested names from other tags, e.g. "${Service}-${Environment}" (--name-template)
+	NameFromTag       string              // Use this tag's value as the suggested name when a resource has it (--name-from-tag)
+	Engine            string              // "ec2" describes each type; "tagging-api" finds candidates with GetResources first
+	TaggingClient     *resourcegroupstaggingapi.Client
+	KMSClient         kmsAPI                      // Finds and tags KMS keys
+	ScanKMS           bool                        // Scan customer managed KMS keys (--kms, or KMS key IDs in --ids-from)
+	Report            *RunReport                  // Per-resource outcomes for --report-file (nil when not requested)
+	Progress          func(seen int)              // Called with the running count of resources scanned (nil to skip)
+	Emit              func(*ResourceInfo)         // Streams each resource as soon as it's named instead of collecting them (--output ndjson)
+	IncludeTerminated bool                        // List terminated instances too; they can't be tagged (--include-terminated)
+	Overwrite         bool                        // Let auto-applied tags replace existing non-empty names (--overwrite)
+	NamePolicy        namePolicy                  // Name patterns by type; resources whose Name breaks them are included (--name-policy)
+	Principal         string                      // Caller ARN from GetCallerIdentity, recorded in history
+	User              string                      // Local OS username, recorded in history
+	SkipAWSDefaults   bool                        // Treat ENIs named with an AWS default (e.g. their own ID) as tagged (--skip-aws-defaults)
+	Attachment        string                      // "attached" or "unattached" to only include such volumes and ENIs ("" for both)
+	States            []string                    // Only include resources in these states (--state)
+	MaxPages          int                         // Most pages each scan fetches; 0 for no limit (--max-pages)
+	PageSize          int32                       // MaxResults for instance, volume, and ENI scans; 0 for the API default (--page-size)
+	CreatedAfter      time.Time                   // Only include resources created after this time; zero for all (--created-after)
+	ShowLinks         bool                        // Print each resource's AWS console URL in the per-resource prompt (--show-links)
+	SortBy            string                      // Resource field to sort the listing by: type, id, state, name, or suggested (--sort)
+	Reverse           bool                        // Reverse the --sort order (--reverse)
+	PreviewNames      bool                        // Keep resources that are already named so their names can be compared (--preview-names)
+	Regions           []string                    // Regions to scan concurrently when --region lists several (report-only modes)
+	ForRegion         func(region string) *Config // Copies the config with clients for another region; set with Regions
+	NoSpinner         bool                        // Scans don't start their own spinners, e.g. while regions share one
+	WithCost          bool                        // Estimate monthly cost of instances and volumes from a static rate card (--with-cost)
+}
+
+// keyValue is a single key=value pair from the command line
+type keyValue struct {
+	Key   string
+	Value string
+}
+
+// keyValueList is a repeatable command line flag of key=value pairs
+type keyValueList []keyValue
+
+// String implements flag.Value
+func (l *keyValueList) String() string {
+	var parts []string
+	for _, kv := range *l {
+		parts = append(parts, kv.Key+"="+kv.Value)
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set implements flag.Value, parsing a single key=value pair
+func (l *keyValueList) Set(value string) error {
+	key, val, found := strings.Cut(value, "=")
+	if !found || key == "" {
+		return fmt.Errorf("expected key=value, got %q", value)
+	}
+	*l = append(*l, keyValue{Key: key, Value: val})
+	return nil
+}
+
+// tagFilters converts --filter-tag pairs into EC2 describe filters
+func tagFilters(pairs keyValueList) []types.Filter {
+	var filters []types.Filter
+	for _, kv := range pairs {
+		filters = append(filters, types.Filter{
+			Name:   stringPtr("tag:" + kv.Key),
+			Values: []string{kv.Value},
+		})
+	}
+	return filters
+}
+
+// extraTags converts --extra-tag pairs into tags applied alongside Name
+func extraTags(pairs keyValueList) ([]types.Tag, error) {
+	var tags []types.Tag
+	for _, kv := range pairs {
+		if kv.Key == "Name" {
+			return nil, fmt.Errorf("--extra-tag cannot set Name")
+		}
+		tags = append(tags, types.Tag{Key: stringPtr(kv.Key), Value: stringPtr(kv.Value)})
+	}
+	return tags, nil
+}
+
+// tagKeys returns the keys of the given tags
+func tagKeys(tags []types.Tag) []string {
+	var keys []string
+	for _, tag := range tags {
+		keys = append(keys, aws.ToString(tag.Key))
+	}
+	return keys
 }
 
 // TagHistoryEntry represents a single tagging action in the history
 type TagHistoryEntry struct {
-	Account   string `yaml:"Account"`
-	Resource  string `yaml:"Resource"`
-	OldValue  string `yaml:"OldValue"`
-	NewValue  string `yaml:"NewValue"`
-	Timestamp string `yaml:"Timestamp"`
-	RunID     string `yaml:"RunID"`
-	Undone    bool   `yaml:"Undone"` // Track if this action has been undone (defaults to false)
+	Account   string   `yaml:"Account"`
+	Region    string   `yaml:"Region,omitempty"` // Empty for entries recorded before regions were tracked
+	Resource  string   `yaml:"Resource"`
+	OldValue  string   `yaml:"OldValue"`
+	NewValue  string   `yaml:"NewValue"`
+	Timestamp string   `yaml:"Timestamp"`
+	RunID     string   `yaml:"RunID"`
+	Undone    bool     `yaml:"Undone"`              // Track if this action has been undone (defaults to false)
+	ExtraTags []string `yaml:"ExtraTags,omitempty"` // Keys of --extra-tag tags added alongside Name, removed on undo
+	Principal string   `yaml:"Principal,omitempty"` // IAM principal ARN that made the change; empty in older history files
+	User      string   `yaml:"User,omitempty"`      // Local OS username that ran quick-tag; empty in older history files
+
+	// PriorTags holds the values ExtraTags keys had before the run; undo restores these rather
+	// than removing the key. Older history files don't record it, so undo removes every key.
+	PriorTags map[string]string `yaml:"PriorTags,omitempty"`
 }
 
 // TagHistory represents the complete history of tagging actions
 type TagHistory struct {
+	Version int               `yaml:"version"` // Schema version; 0 for files written before it was recorded
 	Actions []TagHistoryEntry `yaml:"actions"`
 }
 
+// historyVersion is the history file schema version this build writes
+const historyVersion = 1
+
+// historyMigrations upgrade a history file one schema version at a time: historyMigrations[v]
+// turns version v into v+1. Append one whenever TagHistoryEntry changes in a way old entries
+// need filling in for.
+var historyMigrations = []func(*TagHistory){
+	// v0 -> v1: v0 files predate the version field. Fields added to entries since then (Region,
+	// ExtraTags, Principal, User) read as empty, which already means "not recorded", so only
+	// a missing actions list needs filling in.
+	func(history *TagHistory) {
+		if history.Actions == nil {
+			history.Actions = []TagHistoryEntry{}
+		}
+	},
+}
+
+// migrateHistory upgrades history to historyVersion in memory, reporting whether it changed.
+// Files from a newer quick-tag are refused rather than risk dropping fields on the next save.
+func migrateHistory(history *TagHistory) (bool, error) {
+	if history.Version > historyVersion {
+		return false, fmt.Errorf("history file is version %d, but this quick-tag only understands up to version %d; upgrade quick-tag", history.Version, historyVersion)
+	}
+	migrated := false
+	for history.Version < historyVersion {
+		historyMigrations[history.Version](history)
+		history.Version++
+		migrated = true
+	}
+	return migrated, nil
+}
+
+// defaultHistoryMaxRuns is the number of runs kept in the history file by default
+const defaultHistoryMaxRuns = 100
+
+// Prune trims the history to at most maxRuns runs and returns how many runs were removed.
+// Fully-undone runs are dropped first (oldest first), then the oldest remaining runs.
+// A maxRuns of zero or less disables pruning.
+func (h *TagHistory) Prune(maxRuns int) int {
+	if maxRuns <= 0 {
+		return 0
+	}
+
+	// Collect runs in the order they first appear and whether every action was undone
+	var runOrder []string
+	fullyUndone := make(map[string]bool)
+	for _, action := range h.Actions {
+		if _, seen := fullyUndone[action.RunID]; !seen {
+			runOrder = append(runOrder, action.RunID)
+			fullyUndone[action.RunID] = true
+		}
+		if !action.Undone {
+			fullyUndone[action.RunID] = false
+		}
+	}
+
+	excess := len(runOrder) - maxRuns
+	if excess <= 0 {
+		return 0
+	}
+
+	remove := make(map[string]bool)
+	for _, runID := range runOrder {
+		if len(remove) == excess {
+			break
+		}
+		if fullyUndone[runID] {
+			remove[runID] = true
+		}
+	}
+	for _, runID := range runOrder {
+		if len(remove) == excess {
+			break
+		}
+		remove[runID] = true
+	}
+
+	kept := h.Actions[:0]
+	for _, action := range h.Actions {
+		if !remove[action.RunID] {
+			kept = append(kept, action)
+		}
+	}
+	h.Actions = kept
+
+	return len(remove)
+}
+
+// Merge adds the actions from other that aren't already in the history, matching by RunID,
+// Resource, and Timestamp, and returns how many were added. An action undone on either side
+// stays undone. Actions are kept in timestamp order so --undo finds the newest run.
+func (h *TagHistory) Merge(other *TagHistory) int {
+	type actionKey struct{ runID, resource, timestamp string }
+	index := make(map[actionKey]int)
+	for i, action := range h.Actions {
+		index[actionKey{action.RunID, action.Resource, action.Timestamp}] = i
+	}
+
+	added := 0
+	for _, action := range other.Actions {
+		key := actionKey{action.RunID, action.Resource, action.Timestamp}
+		if i, exists := index[key]; exists {
+			h.Actions[i].Undone = h.Actions[i].Undone || action.Undone
+			continue
+		}
+		index[key] = len(h.Actions)
+		h.Actions = append(h.Actions, action)
+		added++
+	}
+
+	if added > 0 {
+		sort.SliceStable(h.Actions, func(i, j int) bool {
+			ti, _ := time.Parse(time.RFC3339, h.Actions[i].Timestamp)
+			tj, _ := time.Parse(time.RFC3339, h.Actions[j].Timestamp)
+			return ti.Before(tj)
+		})
+	}
+	return added
+}
+
+// Exit codes for scripting, alongside 0 for success, 1 from log.Fatal, and 2 for flag errors
+const (
+	exitNoResources    = 3 // The scan found no resources needing Name tags
+	exitPartialFailure = 4 // Tagging stopped on an error after some tags were applied
+)
+
+// exitCodeHelp documents the exit codes in --help output
+const exitCodeHelp = `
+Exit codes:
+  0  tags were applied (or nothing was selected)
+  1  error before any tags were applied
+  2  invalid command line flags
+  3  no resources without Name tags were found (--count still exits 0)
+  4  tagging stopped on an error after some tags were applied
+`
+
 // version is set at build time via ldflags
 var version = ""
 
+// verbose enables debug logging of AWS API calls to stderr
+var verbose = false
+
+// noColor disables ANSI color codes in all output
+var noColor = false
+
+// quiet suppresses the header, spinners, and per-resource output, leaving errors and the final summary
+var quiet = false
+
+// stdoutIsTTY reports whether stdout is an interactive terminal; spinners are suppressed otherwise
+var stdoutIsTTY = true
+
+// logger receives structured operational logs with --log-format json; nil keeps the plain text output
+var logger *slog.Logger
+
+// stdinIsTTY reports whether stdin is an interactive terminal; prompts refuse to wait otherwise
+var stdinIsTTY = true
+
+// promptTimeout gives up on an unanswered prompt after this long (0 waits forever)
+var promptTimeout time.Duration
+
 func main() {
 	// Parse command line flags
-	region := flag.String("region", "us-east-1", "AWS region to use")
+	region := flag.String("region", "", "AWS region to use (defaults to the region from your AWS config/profile, then "+fallbackRegion+")")
+	profile := flag.String("profile", "", "AWS profile to use (defaults to $AWS_PROFILE or the default profile)")
 	privateMode := flag.Bool("private", false, "Enable private mode (hide account information)")
 	showVersion := flag.Bool("version", false, "Show version information")
 	undoFlag := flag.Bool("undo", false, "Undo the last tagging run")
+	undoResources := flag.String("undo-resource", "", "With --undo, only revert these comma-separated resource IDs from the last run")
+	force := flag.Bool("force", false, "With --undo, revert without asking for confirmation")
+	pageSize := flag.Int("page-size", 0, "Results per page for instance, volume, and ENI scans (5-1000, volumes cap at 500; 0 for the AWS default)")
+	maxPages := flag.Int("max-pages", 0, "Maximum number of pages each scan fetches, to bound API usage on huge accounts (0 for no limit)")
+	limit := flag.Int("limit", 0, "Maximum number of untagged resources to process (0 for no limit)")
+	historyFile := flag.String("history-file", "", "Path to the history file (defaults to $QUICK_TAG_HISTORY or ~/.quick-tag.yml)")
+	noHistory := flag.Bool("no-history", false, "Don't record this run in the history file, e.g. in throwaway containers (--undo can't revert it)")
+	historyMax := flag.Int("history-max-runs", defaultHistoryMaxRuns, "Maximum number of runs to keep in the history file (0 for unlimited)")
+	importFile := flag.String("import-history", "", "Merge the runs from another machine's history file into the local one and exit, so --undo can revert them")
+	pruneFlag := flag.Bool("prune-history", false, "Prune the history file down to --history-max-runs and exit")
+	showHistory := flag.Bool("history", false, "List tagging history and exit")
+	since := flag.Duration("since", 0, "Only consider history from within this duration, e.g. 24h (applies to --history and --undo)")
+	flag.BoolVar(&verbose, "verbose", false, "Log AWS API calls and page counts to stderr")
+	logFormat := flag.String("log-format", "text", "Format for logs on stderr: text, or json for structured logs (scan counts, tagging outcomes, errors)")
+	endpointFlag := flag.String("endpoint-url", "", "Custom AWS endpoint URL, e.g. for LocalStack (defaults to $AWS_ENDPOINT_URL)")
+	noColorFlag := flag.Bool("no-color", false, "Disable colored output (automatic when stdout is not a terminal or NO_COLOR is set)")
+	flag.BoolVar(&quiet, "quiet", false, "Only print errors and a final one-line summary")
+	assumeYes := flag.Bool("yes", false, "Tag all untagged resources without prompting")
+	interactive := flag.Bool("interactive", true, "Prompt for selection and confirmation; --interactive=false is the same as --yes")
+	namePolicyFile := flag.String("name-policy", "", "YAML file mapping resource types to Name regexes; also list resources whose existing Name breaks them, with compliant suggestions")
+	tui := flag.Bool("tui", false, "Pick resources from an arrow-key checkbox list instead of typing numbers (falls back to the text prompt when not a terminal)")
+	confirmOver := flag.Int("confirm-over", 0, "When applying all at once (all, --yes, --apply), skip the final confirmation for up to N tags and always ask above N (0 asks unless --yes); asking needs a terminal")
+	overwrite := flag.Bool("overwrite", false, "Let auto-applied tags (--yes, 'all', 'c', --apply) replace existing non-empty names, such as stale generated ones")
+	timeout := flag.Duration("timeout", 0, "Overall time limit for the run, e.g. 5m (0 for no limit)")
+	callTimeout := flag.Duration("call-timeout", 0, fmt.Sprintf("Time limit for each AWS API call, such as one describe page or CreateTags, e.g. 30s; timed-out calls are retried %d times (0 for no limit)", callTimeoutRetries))
+	flag.DurationVar(&promptTimeout, "prompt-timeout", 0, "Cancel safely if a prompt gets no answer within this duration, e.g. 2m (0 waits forever)")
+	output := flag.String("output", "", "Print untagged resources in the given format and exit instead of tagging (table, yaml, json, or ndjson to stream one JSON object per line while scanning)")
+	outputFile := flag.String("output-file", "", "Write the --output or --count results to this file instead of stdout, creating parent directories; status messages stay on stdout")
+	sortBy := flag.String("sort", "type", "Sort the listing by type, id, state, name (current name), or suggested (suggested name); ties go by type, then ID")
+	reverse := flag.Bool("reverse", false, "Reverse the --sort order")
+	scanKMS := flag.Bool("kms", false, "Also scan customer managed KMS keys; KMS has no batch describe, so this costs two API calls per key")
+	idsFrom := flag.String("ids-from", "", "Only consider the instance/volume/ENI/EIP/VPC/subnet/route table/NAT gateway/internet gateway/transit gateway/KMS key IDs listed in this file (- for stdin) instead of scanning everything")
+	planFile := flag.String("plan", "", "Save the selected tag changes to this file for a later --apply instead of tagging")
+	applyFile := flag.String("apply", "", "Apply the tag changes saved by --plan without re-scanning")
+	resume := flag.String("resume", "", "Resume an interrupted run: re-scan, skip resources already tagged in this run ID (see --history), and record the rest under it")
+	concurrency := flag.Int("concurrency", 1, "Number of tags to apply in parallel when applying without per-tag prompts")
+	throttleThreshold := flag.Int("throttle-threshold", defaultThrottleThreshold, "With --plan or --output table, warn when applying would take more than this many tagging API calls (0 to disable)")
+	spinnerStyle := flag.String("spinner", "braille", "Progress spinner style (braille, dots, line, or none)")
+	spinnerInterval := flag.Duration("spinner-interval", 100*time.Millisecond, "Time between progress spinner frames")
+	reportFile := flag.String("report-file", "", "Write a JSON summary of the run (account, region, per-resource old/new names and outcome) to this file")
+	withCost := flag.Bool("with-cost", false, "Show the estimated monthly cost of untagged instances and volumes (us-east-1 on-demand list prices)")
+	checkCostTags := flag.Bool("check-cost-tags", false, "After tagging, check whether the Name tag is active for cost allocation and explain how to activate it")
+	showLinks := flag.Bool("show-links", false, "Show each resource's AWS console link when confirming tags one by one, to check it before tagging")
+	previewNames := flag.Bool("preview-names", false, "Show the current and suggested name of every resource, named or not, to check naming rules without tagging anything")
+	count := flag.Bool("count", false, "Only print how many resources need a Name, per type and in total, then exit (with --output json for a JSON object)")
+	showStats := flag.Bool("stats", false, "Print resource counts and AWS API call counts at the end of the run")
+	tagPrefix := flag.String("tag-prefix", "", "Prefix for every generated name, e.g. platform/")
+	tagSuffix := flag.String("tag-suffix", "", "Suffix for every generated name, e.g. -prod")
+	nameTemplate := flag.String("name-template", "", "Build names from other tags instead, e.g. '${Service}-${Environment}'; missing tags show as missing-<key>")
+	nameFromTag := flag.String("name-from-tag", "", "Use this tag's value as the Name when a resource has it, e.g. Service or aws:cloudformation:logical-id; others get the usual suggestion")
+	engine := flag.String("engine", "ec2", "How to find untagged resources: ec2 (describe each type) or tagging-api (Resource Groups Tagging API; skips never-tagged resources)")
+	verboseNames := flag.Bool("verbose-names", false, "Add instance type and platform to instance names, e.g. \"web (t3.large, linux)\", and volume type and size to volume names, e.g. \"unattached gp3-100GiB\"")
+	createdAfterFlag := flag.String("created-after", "", "Only include resources created after this date (2006-01-02) or RFC 3339 time; types with no creation time are skipped")
+	stateList := flag.String("state", "", "Only include resources in these states, comma-separated, e.g. running,in-use")
+	onlyAttached := flag.Bool("only-attached", false, "Only include volumes and ENIs that are attached to something")
+	skipAWSDefaults := flag.Bool("skip-aws-defaults", false, "Treat ENIs whose Name is an AWS default, like their own ID or \"Primary network interface\", as already named")
+	onlyUnattached := flag.Bool("only-unattached", false, "Only include volumes and ENIs that aren't attached to anything, e.g. to hunt orphans")
+	includeTerminated := flag.Bool("include-terminated", false, "Include terminated instances in the scan; they can't be tagged, so this requires --output")
+	markerTag := flag.Bool("marker-tag", false, "Also set a "+markerTagKey+"=true tag so later runs reliably recognize generated names")
+	var filterTags, extraTagPairs keyValueList
+	flag.Var(&filterTags, "filter-tag", "Only scan resources with this tag, as key=value (repeatable; all must match)")
+	flag.Var(&extraTagPairs, "extra-tag", "Also set this tag on every tagged resource, as key=value (repeatable; --undo removes it or restores the value it overwrote)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "  %s [flags]\n  %s completion bash|zsh|fish\n", os.Args[0], os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprint(flag.CommandLine.Output(), exitCodeHelp)
+	}
 	flag.Parse()
 
+	// Print a shell completion script, e.g. quick-tag completion bash
+	if flag.Arg(0) == "completion" {
+		if err := writeCompletion(os.Stdout, flag.Arg(1), flag.CommandLine); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// --interactive=false is another spelling of --yes for automation
+	if !*interactive {
+		*assumeYes = true
+	}
+
+	if *output != "" && !slices.Contains(validOutputFormats, *output) {
+		log.Fatalf("invalid --output %q: must be table, yaml, json, or ndjson", *output)
+	}
+	if *count && *output != "" && *output != "json" {
+		log.Fatalf("--count only supports --output json, not %q", *output)
+	}
+	if *outputFile != "" && *output == "" && !*count {
+		log.Fatal("--output-file needs --output or --count to know what to write")
+	}
+	// Keep stdout to just the document or count, unless that goes to --output-file
+	if (*output == "yaml" || *output == "json" || *output == "ndjson" || *count) && *outputFile == "" {
+		quiet = true
+	}
+	if *output == "ndjson" && *limit > 0 {
+		log.Fatal("--limit can't be used with --output ndjson, which streams resources as they're scanned")
+	}
+
+	if !slices.Contains(validSortKeys, *sortBy) {
+		log.Fatalf("invalid --sort %q: must be type, id, state, name, or suggested", *sortBy)
+	}
+	if *output == "ndjson" && (*sortBy != "type" || *reverse) {
+		log.Fatal("--sort and --reverse can't be used with --output ndjson, which streams resources unsorted as they're scanned")
+	}
+
+	if *includeTerminated && *output == "" {
+		log.Fatal("--include-terminated requires --output, since terminated instances can't be tagged")
+	}
+
+	if !slices.Contains(validLogFormats, *logFormat) {
+		log.Fatalf("invalid --log-format %q: must be text or json", *logFormat)
+	}
+	if *logFormat == "json" {
+		logger = newJSONLogger(os.Stderr, verbose)
+		// Route log.Printf warnings and log.Fatal errors through the JSON handler too
+		slog.SetDefault(logger)
+	}
+
+	if err := validateEngine(*engine); err != nil {
+		log.Fatal(err)
+	}
+
+	if *onlyAttached && *onlyUnattached {
+		log.Fatal("--only-attached and --only-unattached cannot be used together")
+	}
+	var attachment string
+	switch {
+	case *onlyAttached:
+		attachment = "attached"
+	case *onlyUnattached:
+		attachment = "unattached"
+	}
+
+	if *noHistory && (*showHistory || *pruneFlag || *undoFlag || *importFile != "") {
+		log.Fatal("--no-history cannot be used with --history, --prune-history, --import-history, or --undo")
+	}
+
+	if *resume != "" && (*undoFlag || *noHistory || *applyFile != "" || *planFile != "") {
+		log.Fatal("--resume cannot be used with --undo, --no-history, --apply, or --plan")
+	}
+
+	if *previewNames && (*undoFlag || *applyFile != "" || *planFile != "" || *resume != "" || *count || *output == "ndjson") {
+		log.Fatal("--preview-names cannot be used with --undo, --apply, --plan, --resume, --count, or --output ndjson")
+	}
+	if *previewNames && *engine == "tagging-api" {
+		log.Fatal("--preview-names needs --engine ec2; the tagging API only lists resources that still need a Name")
+	}
+
+	if *callTimeout < 0 {
+		log.Fatal("--call-timeout must be 0 or more")
+	}
+
+	if *confirmOver < 0 {
+		log.Fatal("--confirm-over must be 0 or more")
+	}
+
+	if *undoResources != "" && !*undoFlag {
+		log.Fatal("--undo-resource only applies to --undo")
+	}
+
+	if *force && !*undoFlag {
+		log.Fatal("--force only applies to --undo; use --yes to tag without prompting")
+	}
+
+	if *planFile != "" && *applyFile != "" {
+		log.Fatal("--plan and --apply cannot be used together")
+	}
+
+	extra, err := extraTags(extraTagPairs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *markerTag {
+		extra = append(extra, types.Tag{Key: stringPtr(markerTagKey), Value: stringPtr("true")})
+	}
+
+	if *maxPages < 0 {
+		log.Fatalf("invalid --max-pages %d: must be 0 or more", *maxPages)
+	}
+	if err := validatePageSize(*pageSize); err != nil {
+		log.Fatal(err)
+	}
+	createdAfter, err := parseCreatedAfter(*createdAfterFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *concurrency < 1 {
+		log.Fatalf("invalid --concurrency %d: must be at least 1", *concurrency)
+	}
+
+	var policy namePolicy
+	if *namePolicyFile != "" {
+		if policy, err = loadNamePolicy(*namePolicyFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if spinner, err = newSpinnerConfig(*spinnerStyle, *spinnerInterval); err != nil {
+		log.Fatal(err)
+	}
+
+	// Plain output when piping to a file or another command
+	stdoutIsTTY = term.IsTerminal(int(os.Stdout.Fd()))
+	stdinIsTTY = term.IsTerminal(int(os.Stdin.Fd()))
+	noColor = shouldDisableColor(*noColorFlag, stdoutIsTTY)
+
+	// Fall back to the region and profile saved by the last run unless set explicitly
+	defaultsPath := getDefaultsFilePath()
+	savedDefaults, err := loadDefaults(defaultsPath)
+	if err != nil {
+		log.Printf("Warning: ignoring saved defaults: %v", err)
+		savedDefaults = &Defaults{}
+	}
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	*region = resolveDefault(*region, explicit["region"], savedDefaults.Region)
+	*profile = resolveDefault(*profile, explicit["profile"], savedDefaults.Profile)
+	requestedRegion := *region
+
+	// --region us-east-1,eu-west-1 scans several regions at once for an inventory; tagging
+	// still happens one region at a time
+	scanRegions := splitList(*region)
+	if len(scanRegions) > 1 {
+		if *output == "" && !*count && !*previewNames {
+			log.Fatal("several --region values only work with --output, --count, or --preview-names; tag one region at a time")
+		}
+		*region = scanRegions[0]
+		requestedRegion = savedDefaults.Region // Don't remember a list as the default region
+	}
+
+	endpointURL := resolveEndpointURL(*endpointFlag)
+
+	historyPath := getHistoryFilePath(*historyFile)
+
+	// Stop in-flight AWS calls on Ctrl+C/SIGTERM or when --timeout elapses
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
 	// Handle version flag
 	if *showVersion {
-		fmt.Println(resolveVersion())
+		fmt.Println(versionInfo())
 		os.Exit(0)
 	}
 
+	// Handle history flag
+	if *showHistory {
+		if err := listHistory(os.Stdout, historyPath, *since); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Handle prune flag
+	if *pruneFlag {
+		removed, err := pruneHistory(historyPath, *historyMax)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s Pruned %d runs from history\n", color("✅", qc.ColorGreen), removed)
+		return
+	}
+
+	// Handle import flag
+	if *importFile != "" {
+		added, err := importHistory(historyPath, *importFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s Imported %d actions from %s\n", color("✅", qc.ColorGreen), added, *importFile)
+		return
+	}
+
 	// Handle undo flag
 	if *undoFlag {
-		if err := undoLastRun(); err != nil {
-			log.Fatal(err)
+		if err := undoLastRun(ctx, historyPath, endpointURL, *region, *profile, *since, *force, splitList(*undoResources)); err != nil {
+			fatal(ctx, err)
 		}
 		return
 	}
 
-	// Generate a unique run ID for this execution
-	runID := generateRunID()
+	// Without a terminal to answer prompts, fail now rather than after scanning
+	if !stdinIsTTY && willPrompt(*assumeYes, *confirmOver, *output, *count, *previewNames) {
+		if *assumeYes {
+			log.Fatal("--confirm-over asks before applying more than N tags, which needs a terminal on stdin; drop it to apply without asking")
+		}
+		log.Fatal(errStdinNotTerminal)
+	}
+
+	// Read explicit resource IDs before touching AWS so bad input fails fast
+	var targetIDs map[string][]string
+	if *idsFrom != "" {
+		var err error
+		targetIDs, err = loadTargetIDs(*idsFrom)
+		if err != nil {
+			log.Fatalf("failed to read resource IDs from %s: %v", *idsFrom, err)
+		}
+	}
+
+	// A saved plan fixes the region and the exact changes to make
+	var plan *TagPlan
+	if *applyFile != "" {
+		var err error
+		plan, err = loadPlan(*applyFile)
+		if err != nil {
+			log.Fatalf("failed to load plan %s: %v", *applyFile, err)
+		}
+		*region = plan.Region
+	}
 
-	ctx := context.Background()
+	// Generate a unique run ID for this execution; a resumed run keeps its original ID so
+	// --undo reverts the whole run
+	runID := generateRunID()
+	if *resume != "" {
+		runID = *resume
+	}
 
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(*region))
+	cfg, err := loadAWSConfig(ctx, *region, *profile)
 	if err != nil {
-		log.Fatal(err)
+		fatal(ctx, err)
+	}
+	*region = cfg.Region
+	withCallTimeout(&cfg, *callTimeout)
+
+	// Catch region typos now; the lookup goes to a region that's sure to exist
+	lookupCfg := cfg.Copy()
+	lookupCfg.Region = fallbackRegion
+	lookupClient := newEC2Client(lookupCfg, endpointURL)
+	checkRegions := scanRegions
+	if len(checkRegions) <= 1 {
+		checkRegions = []string{*region} // Also covers a region from the profile or environment
 	}
-	stsClient := sts.NewFromConfig(cfg)
+	for _, r := range checkRegions {
+		if err := validateRegion(ctx, r, lookupClient); err != nil {
+			fatal(ctx, err)
+		}
+	}
+
+	stsClient := newSTSClient(cfg, endpointURL)
+	debugf("GetCallerIdentity: region %s", *region)
 	callerIdentity, err := stsClient.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
 	if err != nil {
-		log.Fatal(fmt.Errorf("failed to authenticate with aws: %v", err))
+		fatal(ctx, fmt.Errorf("failed to authenticate with aws: %v", err))
+	}
+	// Remember the region and profile for next time, once they've proven to work
+	if explicit["region"] || explicit["profile"] {
+		if err := saveDefaults(defaultsPath, &Defaults{Region: requestedRegion, Profile: *profile}); err != nil {
+			log.Printf("Warning: failed to save defaults: %v", err)
+		}
+	}
+	if plan != nil && plan.Account != *callerIdentity.Account {
+		log.Fatalf("plan %s was created for account %s, but the current credentials are for account %s", *applyFile, plan.Account, *callerIdentity.Account)
+	}
+	if !quiet {
+		printHeader(*privateMode, callerIdentity)
+	}
+	logEvent(slog.LevelInfo, "run started", "run_id", runID, "account", *callerIdentity.Account, "region", *region)
+
+	var resumed map[string]bool
+	if *resume != "" {
+		resumed, err = resumedResources(historyPath, *resume, *callerIdentity.Account)
+		if err != nil {
+			log.Fatal(err)
+		}
+		infof("%s Resuming run %s: %d resources were already tagged in it\n", color("ℹ️", qc.ColorCyan), *resume, len(resumed))
 	}
-	printHeader(*privateMode, callerIdentity)
 
 	// Create configuration with EC2 client
+	ec2Client := newEC2Client(cfg, endpointURL)
 	config := &Config{
-		EC2Client:   ec2.NewFromConfig(cfg),
-		Region:      *region,
-		PrivateMode: *privateMode,
+		EC2Client:         ec2Client,
+		KMSClient:         newKMSClient(cfg, endpointURL),
+		ScanKMS:           *scanKMS || len(targetIDs["kms-key"]) > 0,
+		Describe:          newDescribeCache(ec2Client),
+		Region:            *region,
+		PrivateMode:       *privateMode,
+		HistoryFile:       historyPath,
+		HistoryMax:        *historyMax,
+		NoHistory:         *noHistory,
+		ConfirmOver:       *confirmOver,
+		TargetIDs:         targetIDs,
+		Filters:           tagFilters(filterTags),
+		AssumeYes:         *assumeYes,
+		Concurrency:       *concurrency,
+		ExtraTags:         extra,
+		VerboseNames:      *verboseNames,
+		Engine:            *engine,
+		TagPrefix:         *tagPrefix,
+		TagSuffix:         *tagSuffix,
+		NameTemplate:      *nameTemplate,
+		NameFromTag:       *nameFromTag,
+		Stats:             newRunStats(),
+		IncludeTerminated: *includeTerminated,
+		Overwrite:         *overwrite,
+		NamePolicy:        policy,
+		Principal:         aws.ToString(callerIdentity.Arn),
+		User:              localUsername(),
+		Attachment:        attachment,
+		SkipAWSDefaults:   *skipAWSDefaults,
+		States:            splitList(*stateList),
+		MaxPages:          *maxPages,
+		PageSize:          int32(*pageSize),
+		WithCost:          *withCost,
+		CreatedAfter:      createdAfter,
+		PreviewNames:      *previewNames,
+		SortBy:            *sortBy,
+		Reverse:           *reverse,
+		ShowLinks:         *showLinks,
 	}
 
-	// Step 1: Scan for untagged resources
-	untaggedResources, err := showProgressWithResult("Scanning for untagged resources...", func() ([]*ResourceInfo, error) {
-		return findUntaggedResources(ctx, config)
-	})
-	if err != nil {
-		log.Fatal(err)
+	// Report statistics however the run ends normally
+	reportStats := func() {
+		if *showStats {
+			printStats(os.Stdout, config.Stats)
+		}
 	}
+	defer reportStats()
 
-	if len(untaggedResources) == 0 {
-		fmt.Printf("%s All resources already have Name tags!\n", color("✅", qc.ColorGreen))
-		return
+	if *reportFile != "" {
+		config.Report = newRunReport(runID, *callerIdentity.Account, config.Region)
+	}
+	// Write the report before finishTagging, which exits on failure, and before the other
+	// os.Exit calls; the deferred call covers runs that tag nothing, e.g. no selection
+	writeReport := func() {
+		if config.Report == nil {
+			return
+		}
+		if err := saveRunReport(*reportFile, config.Report); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	defer writeReport()
+	// Remind about cost allocation once there are tags to group costs by
+	nudgeCostTags := func(applied int) {
+		if !*checkCostTags || applied == 0 {
+			return
+		}
+		printCostTagNudge(ctx, os.Stdout, newCostExplorerClient(cfg, endpointURL), "Name")
+	}
+	if config.Engine == "tagging-api" {
+		config.TaggingClient = newTaggingClient(cfg, endpointURL)
+	}
+	if len(scanRegions) > 1 {
+		config.Regions = scanRegions
+		config.ForRegion = func(region string) *Config {
+			regionCfg := cfg.Copy()
+			regionCfg.Region = region
+			scoped := *config
+			scoped.Region = region
+			scoped.EC2Client = newEC2Client(regionCfg, endpointURL)
+			scoped.Describe = newDescribeCache(scoped.EC2Client)
+			scoped.KMSClient = newKMSClient(regionCfg, endpointURL)
+			if scoped.TaggingClient != nil {
+				scoped.TaggingClient = newTaggingClient(regionCfg, endpointURL)
+			}
+			return &scoped
+		}
+	}
+	if config.NoHistory && *output == "" && !*count && *planFile == "" {
+		infof("%s --no-history: this run won't be recorded, so --undo can't revert it\n", color("ℹ️", qc.ColorCyan))
 	}
 
-	fmt.Printf("Found %d resources without Name tags:\n", len(untaggedResources))
-
-	// Step 2: Display resources and allow selection
-	selectedResources, autoApply := selectResources(untaggedResources)
-	if len(selectedResources) == 0 {
-		fmt.Println("No resources selected. Exiting.")
+	// Apply a saved plan instead of scanning
+	if plan != nil {
+		applied, err := applyPlan(ctx, config, plan, *callerIdentity.Account, runID)
+		writeReport()
+		finishTagging(ctx, applied, err)
+		nudgeCostTags(applied)
 		return
 	}
 
-	// Step 3: Apply tags
-	if err := applyTags(ctx, config, selectedResources, *callerIdentity.Account, runID, autoApply); err != nil {
-		log.Fatal(err)
+	// --output-file takes the results, leaving stdout for status messages. They only replace
+	// the file once complete, so a failed scan keeps the previous export: ndjson streams into a
+	// temp file, and the other formats, which hold every resource anyway, are buffered.
+	var results io.Writer = os.Stdout
+	var buffered bytes.Buffer
+	if *outputFile != "" {
+		results = &buffered
+	}
+	flushResults := func() {
+		if *outputFile == "" {
+			return
+		}
+		if err := writeOutputFile(*outputFile, func(w io.Writer) error {
+			_, err := buffered.WriteTo(w)
+			return err
+		}); err != nil {
+			fatal(ctx, err)
+		}
+	}
+	announceResults := func(written int) {
+		if *outputFile != "" {
+			infof("%s Wrote %d resources to %s\n", color("✅", qc.ColorGreen), written, *outputFile)
+		}
+	}
+	wroteResults := func(written int) {
+		flushResults()
+		announceResults(written)
 	}
 
-	fmt.Printf("\n%s Successfully completed tagging process!\n", color("✅", qc.ColorGreen))
-}
+	// Interactive sessions can re-scan after tagging to work through what's left
+	for scans := 0; ; scans++ {
+		if scans > 0 {
+			// Names changed since the last scan, so don't reuse cached lookups
+			config.Describe = newDescribeCache(ec2Client)
+		}
 
-// findUntaggedResources scans for EC2 instances, EBS volumes, and ENIs without Name tags
-func findUntaggedResources(ctx context.Context, config *Config) ([]*ResourceInfo, error) {
-	var resources []*ResourceInfo
+		// Stream resources as each page is scanned instead of collecting them all first
+		if *output == "ndjson" {
+			var streamed int
+			stream := func(w io.Writer) (err error) {
+				streamed, err = streamResources(ctx, config, w)
+				return err
+			}
+			if *outputFile != "" {
+				err = writeOutputFile(*outputFile, stream)
+			} else {
+				err = stream(os.Stdout)
+			}
+			if err != nil {
+				fatal(ctx, err)
+			}
+			announceResults(streamed)
+			if streamed == 0 {
+				writeReport()
+				reportStats()
+				os.Exit(exitNoResources)
+			}
+			return
+		}
 
-	// Find untagged instances
-	instances, err := showProgressWithResult("Scanning EC2 instances...", func() ([]*ResourceInfo, error) {
-		return findUntaggedInstances(ctx, config)
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to find untagged instances: %v", err)
-	}
-	resources = append(resources, instances...)
+		// Step 1: Scan for untagged resources
+		var untaggedResources []*ResourceInfo
+		if len(config.Regions) > 1 {
+			// Regions share one spinner listing the ones still being scanned
+			untaggedResources, err = findUntaggedResources(ctx, config)
+		} else {
+			untaggedResources, err = showProgressWithResult("Scanning for untagged resources...", func() ([]*ResourceInfo, error) {
+				return findUntaggedResources(ctx, config)
+			})
+		}
+		if err != nil {
+			fatal(ctx, err)
+		}
+
+		// Dashboards only need the numbers; zero is a result, not a failure
+		if *count {
+			if err := writeCounts(results, countResources(config, untaggedResources), *output); err != nil {
+				fatal(ctx, err)
+			}
+			flushResults()
+			return
+		}
+
+		// Compare every resource's name with the suggestion instead of tagging
+		if *previewNames && (*output == "" || *output == "table") {
+			printNamePreview(results, limitResources(untaggedResources, *limit))
+			flushResults()
+			return
+		}
+
+		if len(untaggedResources) == 0 {
+			if *output == "yaml" || *output == "json" {
+				if err := writeResources(results, untaggedResources, *output); err != nil {
+					fatal(ctx, err)
+				}
+				wroteResults(0)
+			} else {
+				fmt.Printf("%s All resources already have Name tags!\n", color("✅", qc.ColorGreen))
+			}
+			if scans > 0 {
+				return
+			}
+			writeReport()
+			reportStats()
+			os.Exit(exitNoResources)
+		}
+
+		untaggedResources = skipResumed(untaggedResources, resumed)
+		if len(untaggedResources) == 0 {
+			fmt.Printf("%s Nothing left to tag in run %s.\n", color("✅", qc.ColorGreen), runID)
+			return
+		}
+
+		infof("Found %d resources without Name tags:\n", len(untaggedResources))
+		untaggedResources = limitResources(untaggedResources, *limit)
+
+		// Report-only output modes
+		switch *output {
+		case "table":
+			printResourceTable(results, untaggedResources)
+			wroteResults(len(untaggedResources))
+			if !quiet {
+				printCostSummary(os.Stdout, untaggedResources)
+				printCallEstimate(os.Stdout, untaggedResources, *concurrency, *throttleThreshold)
+			}
+			return
+		case "yaml", "json":
+			if err := writeResources(results, untaggedResources, *output); err != nil {
+				fatal(ctx, err)
+			}
+			wroteResults(len(untaggedResources))
+			return
+		}
+
+		// Step 2: Display resources and allow selection
+		// --yes selects everything and applies without prompting
+		selectedResources, autoApply := untaggedResources, true
+		if !config.AssumeYes {
+			if *tui && stdoutIsTTY {
+				selectedResources, autoApply = selectResourcesTUI(ctx, untaggedResources)
+			} else {
+				selectedResources, autoApply = selectResources(ctx, untaggedResources)
+			}
+		}
+		if len(selectedResources) == 0 {
+			fmt.Println("No resources selected. Exiting.")
+			return
+		}
+
+		// Save the selection for review instead of tagging
+		if *planFile != "" {
+			if err := savePlan(*planFile, newTagPlan(*callerIdentity.Account, config.Region, selectedResources)); err != nil {
+				fatal(ctx, err)
+			}
+			fmt.Printf("%s Saved %d tag changes to %s. Review it, then run: quick-tag --apply %s\n",
+				color("✅", qc.ColorGreen), len(selectedResources), *planFile, *planFile)
+			if !quiet {
+				printCallEstimate(os.Stdout, selectedResources, *concurrency, *throttleThreshold)
+			}
+			return
+		}
+
+		// Step 3: Apply tags
+		applied, err := applyTags(ctx, config, selectedResources, *callerIdentity.Account, runID, autoApply)
+		// Rewritten after each re-scan round so the file always covers the whole run
+		writeReport()
+		finishTagging(ctx, applied, err)
+		nudgeCostTags(applied)
+
+		if config.AssumeYes || !promptRescan(ctx) {
+			return
+		}
+	}
+}
+
+// promptRescan asks whether to scan again for resources that are still untagged
+func promptRescan(ctx context.Context) bool {
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Printf("\n%s", color("Re-scan for remaining untagged resources? (y/N): ", qc.ColorYellow))
+	response, err := readLine(ctx, reader)
+	if err != nil {
+		return false
+	}
+	response = strings.TrimSpace(strings.ToLower(response))
+	return response == "y" || response == "yes"
+}
+
+// finishTagging reports the outcome of applying tags and exits non-zero on failure
+func finishTagging(ctx context.Context, applied int, err error) {
+	if err != nil {
+		if applied > 0 {
+			log.Print(err)
+			os.Exit(exitPartialFailure)
+		}
+		fatal(ctx, err)
+	}
+	logEvent(slog.LevelInfo, "tagging finished", "applied", applied)
+	if applied == 0 {
+		return
+	}
+
+	infof("\n%s Successfully completed tagging process!\n", color("✅", qc.ColorGreen))
+}
+
+// findUntaggedResources scans for EC2 instances, EBS volumes, ENIs, Elastic IPs, VPCs, subnets, route tables, NAT gateways, internet gateways, transit gateways, and KMS keys without Name tags
+func findUntaggedResources(ctx context.Context, config *Config) ([]*ResourceInfo, error) {
+	if len(config.Regions) > 1 {
+		return findUntaggedResourcesInRegions(ctx, config)
+	}
+
+	var resources []*ResourceInfo
+
+	// Narrow the describe scans to the candidates the Resource Groups Tagging API found
+	if config.Engine == "tagging-api" {
+		listCandidates := func() (map[string][]string, error) {
+			return findTaggingAPICandidates(ctx, config.TaggingClient, config.TargetIDs, config.TagPrefix, config.TagSuffix, config.NamePolicy, config.MaxPages)
+		}
+		var candidates map[string][]string
+		var err error
+		if config.NoSpinner {
+			candidates, err = listCandidates()
+		} else {
+			candidates, err = showProgressWithResult("Listing resources with the Resource Groups Tagging API...", listCandidates)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list resources with the tagging API: %v", err)
+		}
+		scoped := *config
+		scoped.TargetIDs = candidates
+		config = &scoped
+	}
+
+	// Find untagged instances
+	if shouldScanType(config, "instance") {
+		instances, err := scanWithProgress("Scanning EC2 instances...", config, func(config *Config) ([]*ResourceInfo, error) {
+			return findUntaggedInstances(ctx, config)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to find untagged instances: %v", err)
+		}
+		resources = append(resources, instances...)
+	}
+
+	// Find untagged volumes
+	if shouldScanType(config, "volume") {
+		volumes, err := scanWithProgress("Scanning EBS volumes...", config, func(config *Config) ([]*ResourceInfo, error) {
+			return findUntaggedVolumes(ctx, config)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to find untagged volumes: %v", err)
+		}
+		resources = append(resources, volumes...)
+	}
+
+	// Find untagged ENIs
+	if shouldScanType(config, "eni") {
+		enis, err := scanWithProgress("Scanning ENIs...", config, func(config *Config) ([]*ResourceInfo, error) {
+			return findUntaggedENIs(ctx, config)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to find untagged ENIs: %v", err)
+		}
+		resources = append(resources, enis...)
+	}
+
+	// Find untagged Elastic IPs
+	if shouldScanType(config, "eip") {
+		addresses, err := scanWithProgress("Scanning Elastic IPs...", config, func(config *Config) ([]*ResourceInfo, error) {
+			return findUntaggedAddresses(ctx, config)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to find untagged Elastic IPs: %v", err)
+		}
+		resources = append(resources, addresses...)
+	}
+
+	// Find untagged VPCs
+	if shouldScanType(config, "vpc") {
+		vpcs, err := scanWithProgress("Scanning VPCs...", config, func(config *Config) ([]*ResourceInfo, error) {
+			return findUntaggedVPCs(ctx, config)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to find untagged VPCs: %v", err)
+		}
+		resources = append(resources, vpcs...)
+	}
+
+	// Find untagged subnets
+	if shouldScanType(config, "subnet") {
+		subnets, err := scanWithProgress("Scanning subnets...", config, func(config *Config) ([]*ResourceInfo, error) {
+			return findUntaggedSubnets(ctx, config)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to find untagged subnets: %v", err)
+		}
+		resources = append(resources, subnets...)
+	}
+
+	// Find untagged route tables
+	if shouldScanType(config, "route-table") {
+		routeTables, err := scanWithProgress("Scanning route tables...", config, func(config *Config) ([]*ResourceInfo, error) {
+			return findUntaggedRouteTables(ctx, config)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to find untagged route tables: %v", err)
+		}
+		resources = append(resources, routeTables...)
+	}
+
+	// Find untagged NAT gateways
+	if shouldScanType(config, "nat-gateway") {
+		natGateways, err := scanWithProgress("Scanning NAT gateways...", config, func(config *Config) ([]*ResourceInfo, error) {
+			return findUntaggedNatGateways(ctx, config)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to find untagged NAT gateways: %v", err)
+		}
+		resources = append(resources, natGateways...)
+	}
+
+	// Find untagged internet gateways
+	if shouldScanType(config, "igw") {
+		internetGateways, err := scanWithProgress("Scanning internet gateways...", config, func(config *Config) ([]*ResourceInfo, error) {
+			return findUntaggedInternetGateways(ctx, config)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to find untagged internet gateways: %v", err)
+		}
+		resources = append(resources, internetGateways...)
+	}
+
+	// Find untagged transit gateways
+	if shouldScanType(config, "tgw") {
+		transitGateways, err := scanWithProgress("Scanning transit gateways...", config, func(config *Config) ([]*ResourceInfo, error) {
+			return findUntaggedTransitGateways(ctx, config)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to find untagged transit gateways: %v", err)
+		}
+		resources = append(resources, transitGateways...)
+	}
+
+	// Find untagged KMS keys
+	if shouldScanType(config, "kms-key") {
+		kmsKeys, err := scanWithProgress("Scanning KMS keys...", config, func(config *Config) ([]*ResourceInfo, error) {
+			return findUntaggedKMSKeys(ctx, config)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to find untagged KMS keys: %v", err)
+		}
+		resources = append(resources, kmsKeys...)
+	}
+
+	resources = filterByState(resources, config.States)
+	disambiguateNames(resources)
+	applyNameTemplate(resources, config.NameTemplate)
+	applyNameFromTag(resources, config.NameFromTag)
+	applyTagPrefix(resources, config.TagPrefix)
+	applyTagSuffix(resources, config.TagSuffix)
+	warnNonCompliantSuggestions(resources, config.NamePolicy)
+
+	sortResources(resources, config.SortBy, config.Reverse)
+
+	return resources, nil
+}
+
+// validSortKeys lists the supported --sort values
+var validSortKeys = []string{"type", "id", "state", "name", "suggested"}
+
+// sortResources orders resources by the --sort key, then by type and ID so the order is stable
+// between runs; reverse flips the whole order (--reverse). An empty key sorts by type.
+func sortResources(resources []*ResourceInfo, by string, reverse bool) {
+	key := func(resource *ResourceInfo) string {
+		switch by {
+		case "id":
+			return resource.ID
+		case "state":
+			return resource.State
+		case "name":
+			return resource.Name
+		case "suggested":
+			return resource.SuggestedName
+		}
+		return resource.Type
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		a, b := resources[i], resources[j]
+		if reverse {
+			a, b = b, a
+		}
+		if key(a) != key(b) {
+			return key(a) < key(b)
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.ID < b.ID
+	})
+}
+
+// collect adds scanned resources, named and ready to list, to a scan's results. When config.Emit
+// is set (--output ndjson) they're finished one page at a time and streamed instead, so even a
+// huge account never holds more than a page in memory.
+func (c *Config) collect(resources []*ResourceInfo, found ...*ResourceInfo) []*ResourceInfo {
+	if c.Emit == nil {
+		return append(resources, found...)
+	}
+	found = filterByState(found, c.States)
+	disambiguateNames(found)
+	applyNameTemplate(found, c.NameTemplate)
+	applyNameFromTag(found, c.NameFromTag)
+	applyTagPrefix(found, c.TagPrefix)
+	applyTagSuffix(found, c.TagSuffix)
+	warnNonCompliantSuggestions(found, c.NamePolicy)
+	for _, resource := range found {
+		c.Emit(resource)
+	}
+	return resources
+}
+
+// filterByState keeps the resources whose State is one of states (--state); no states keeps all
+func filterByState(resources []*ResourceInfo, states []string) []*ResourceInfo {
+	if len(states) == 0 {
+		return resources
+	}
+	return slices.DeleteFunc(resources, func(resource *ResourceInfo) bool {
+		return !slices.Contains(states, resource.State)
+	})
+}
+
+// splitList splits a comma-separated flag value, dropping blanks
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// matchesAttachment reports whether a volume or ENI with the given attachment info (from
+// naming.VolumeMountPoint or naming.ENIAttachmentInfo) passes --only-attached/--only-unattached
+func (c *Config) matchesAttachment(attachmentInfo string) bool {
+	switch c.Attachment {
+	case "attached":
+		return attachmentInfo != "unattached"
+	case "unattached":
+		return attachmentInfo == "unattached"
+	}
+	return true
+}
+
+// morePages reports whether a scan should fetch another page, stopping with a warning
+// once maxPages pages (--max-pages) have been fetched. A maxPages of 0 means no limit.
+func morePages(paginatorHasMore bool, pages, maxPages int, operation string) bool {
+	if !paginatorHasMore {
+		return false
+	}
+	if maxPages > 0 && pages >= maxPages {
+		warnf("%s: stopped after %d pages (--max-pages), so results are incomplete", operation, pages)
+		return false
+	}
+	return true
+}
+
+// pageSizeLimits are the MaxResults ranges AWS allows for the scans that use --page-size
+var pageSizeLimits = map[string][2]int32{
+	"DescribeInstances":         {5, 1000},
+	"DescribeVolumes":           {5, 500},
+	"DescribeNetworkInterfaces": {5, 1000},
+}
+
+// validatePageSize checks --page-size against the scans that use it. A size only some of
+// them allow is fine; pageSize caps it for the others, with a warning here.
+func validatePageSize(size int) error {
+	if size == 0 {
+		return nil
+	}
+	if size < 5 || size > 1000 {
+		return fmt.Errorf("invalid --page-size %d: must be between 5 and 1000", size)
+	}
+	for _, operation := range slices.Sorted(maps.Keys(pageSizeLimits)) {
+		if limit := pageSizeLimits[operation][1]; int32(size) > limit {
+			warnf("--page-size %d is more than %s allows, so it will use %d", size, operation, limit)
+		}
+	}
+	return nil
+}
+
+// pageSize returns MaxResults for an operation's scan, or nil for the API default. AWS
+// rejects MaxResults alongside explicit IDs, so it's left unset when ids are given.
+func (c *Config) pageSize(operation string, ids []string) *int32 {
+	if c.PageSize == 0 || len(ids) > 0 {
+		return nil
+	}
+	limits := pageSizeLimits[operation]
+	return aws.Int32(min(max(c.PageSize, limits[0]), limits[1]))
+}
+
+// reportProgress passes the running count of scanned resources to config.Progress, if set
+func (c *Config) reportProgress(seen int) {
+	if c.Progress != nil {
+		c.Progress(seen)
+	}
+}
+
+// scanWithProgress runs one resource type's scan behind a spinner whose message shows
+// how many resources have been seen so far, e.g. "Scanning EC2 instances... (1,234 seen)"
+func scanWithProgress(message string, config *Config, scan func(*Config) ([]*ResourceInfo, error)) ([]*ResourceInfo, error) {
+	if config.NoSpinner {
+		return scan(config)
+	}
+	update, stop := startProgress(message)
+	defer stop()
+
+	scoped := *config
+	scoped.Progress = func(seen int) {
+		update(fmt.Sprintf("%s (%s seen)", message, formatCount(seen)))
+	}
+	return scan(&scoped)
+}
+
+// formatCount formats a count with thousands separators, e.g. 1234 -> "1,234"
+func formatCount(n int) string {
+	if n < 0 {
+		return "-" + formatCount(-n)
+	}
+	digits := strconv.Itoa(n)
+	var b strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(digit)
+	}
+	return b.String()
+}
+
+// applyTagPrefix prepends --tag-prefix to suggested names. Names derived from an already
+// prefixed name (like a volume named after its instance) aren't prefixed twice.
+func applyTagPrefix(resources []*ResourceInfo, prefix string) {
+	if prefix == "" {
+		return
+	}
+	for _, resource := range resources {
+		if !strings.HasPrefix(resource.SuggestedName, prefix) {
+			resource.SuggestedName = naming.Truncate(prefix, resource.SuggestedName, "")
+		}
+	}
+}
+
+// applyNameTemplate replaces suggested names with --name-template expanded from each
+// resource's tags, warning once about resources missing a referenced tag
+func applyNameTemplate(resources []*ResourceInfo, template string) {
+	if template == "" {
+		return
+	}
+	var incomplete []string
+	for _, resource := range resources {
+		name, missing := naming.ExpandTemplate(template, resource.Tags)
+		resource.SuggestedName = name
+		resource.Source = "name template"
+		if len(missing) > 0 {
+			incomplete = append(incomplete, fmt.Sprintf("%s (%s)", resource.ID, strings.Join(missing, ", ")))
+		}
+	}
+	if len(incomplete) > 0 {
+		warnf("%d resources are missing tags used by --name-template: %s", len(incomplete), strings.Join(incomplete, "; "))
+	}
+}
+
+// applyNameFromTag replaces suggested names with the value of the --name-from-tag tag on the
+// resources that have it, so it wins over both the computed name and --name-template
+func applyNameFromTag(resources []*ResourceInfo, key string) {
+	if key == "" {
+		return
+	}
+	for _, resource := range resources {
+		if value := resource.Tags[key]; value != "" {
+			resource.SuggestedName = value
+			resource.Source = "tag " + key
+		}
+	}
+}
+
+// applyTagSuffix appends --tag-suffix to suggested names, the same way applyTagPrefix prepends
+// --tag-prefix. Names that already end with it aren't suffixed twice.
+func applyTagSuffix(resources []*ResourceInfo, suffix string) {
+	if suffix == "" {
+		return
+	}
+	for _, resource := range resources {
+		if !strings.HasSuffix(resource.SuggestedName, suffix) {
+			resource.SuggestedName = naming.Truncate("", resource.SuggestedName, suffix)
+		}
+	}
+}
+
+// baseName strips --tag-prefix, --tag-suffix, and the "-<id>" added by disambiguateNames from
+// a current Name, so generated names can be recognized by naming.IsQuickTagCreatedName
+func (c *Config) baseName(name, id string) string {
+	name = strings.TrimSuffix(strings.TrimPrefix(name, c.TagPrefix), c.TagSuffix)
+	return strings.TrimSuffix(name, "-"+id)
+}
+
+// disambiguateNames appends "-<resource ID>" to generic suggested names such as "unattached",
+// e.g. "unattached-vol-0abc", so names tell resources apart in the console. Every generic name
+// gets the ID, not just ones shared in this scan, so a name doesn't change with --limit or
+// --resume. It runs before --name-template and --name-from-tag, whose names are shared on purpose.
+func disambiguateNames(resources []*ResourceInfo) {
+	for _, resource := range resources {
+		if naming.IsGenericName(resource.SuggestedName) {
+			resource.SuggestedName = naming.Truncate("", resource.SuggestedName, "-"+resource.ID)
+		}
+	}
+}
+
+// willPrompt reports whether a run may ask questions on stdin: it isn't one of the read-only
+// listing modes, and it tags interactively or with --confirm-over, which asks above N tags
+// even with --yes
+func willPrompt(assumeYes bool, confirmOver int, output string, count, previewNames bool) bool {
+	if output != "" || count || previewNames {
+		return false
+	}
+	return !assumeYes || confirmOver > 0
+}
+
+// limitResources truncates the sorted resource list to at most limit entries.
+// A limit of zero or less means no limit.
+func limitResources(resources []*ResourceInfo, limit int) []*ResourceInfo {
+	if limit <= 0 || len(resources) <= limit {
+		return resources
+	}
+
+	infof("%s Showing first %d of %d untagged resources.\n", color("ℹ️", qc.ColorCyan), limit, len(resources))
+	return resources[:limit]
+}
+
+// createdTimeTypes are the resource types whose scans report when each resource was created,
+// so they're the only types --created-after can include
+var createdTimeTypes = []string{"instance", "volume", "nat-gateway", "tgw", "kms-key"}
+
+// parseCreatedAfter parses --created-after as a date (2006-01-02, midnight UTC) or an RFC 3339
+// time. An empty value means no filter.
+func parseCreatedAfter(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --created-after %q: use a date like 2025-01-31 or an RFC 3339 time", value)
+	}
+	return t, nil
+}
+
+// createdAfter reports whether a resource created at created passes --created-after. When it's
+// set, resources with no creation time are excluded.
+func (c *Config) createdAfter(created *time.Time) bool {
+	if c.CreatedAfter.IsZero() {
+		return true
+	}
+	return created != nil && created.After(c.CreatedAfter)
+}
+
+// shouldScanType reports whether a resource type is scanned; when explicit IDs were
+// given with --ids-from, only types with listed IDs are scanned. KMS keys cost two calls
+// each, so they're only scanned with --kms or when --ids-from lists some.
+func shouldScanType(config *Config, resourceType string) bool {
+	if resourceType == "kms-key" && !config.ScanKMS {
+		return false
+	}
+	// Types with no creation time can't pass --created-after
+	if !config.CreatedAfter.IsZero() && !slices.Contains(createdTimeTypes, resourceType) {
+		return false
+	}
+	if config.TargetIDs == nil {
+		return true
+	}
+	return len(config.TargetIDs[resourceType]) > 0
+}
+
+// resourceIDPrefixes maps resource ID prefixes to resource types
+var resourceIDPrefixes = map[string]string{
+	"i-":        "instance",
+	"vol-":      "volume",
+	"eni-":      "eni",
+	"eipalloc-": "eip",
+	"vpc-":      "vpc",
+	"subnet-":   "subnet",
+	"rtb-":      "route-table",
+	"nat-":      "nat-gateway",
+	"igw-":      "igw",
+	"tgw-":      "tgw",
+	"mrk-":      "kms-key",
+}
+
+// readResourceIDs reads resource IDs separated by whitespace, commas, or newlines,
+// skipping blank lines and # comments, and groups them by resource type
+func readResourceIDs(r io.Reader) (map[string][]string, error) {
+	targetIDs := make(map[string][]string)
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+
+		fields := strings.FieldsFunc(line, func(c rune) bool {
+			return c == ',' || c == ' ' || c == '\t'
+		})
+		for _, id := range fields {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			resourceType := ""
+			for prefix, t := range resourceIDPrefixes {
+				if strings.HasPrefix(id, prefix) {
+					resourceType = t
+					break
+				}
+			}
+			if resourceType == "" && isKMSKeyID(id) {
+				resourceType = "kms-key"
+			}
+			if resourceType == "" {
+				return nil, fmt.Errorf("unsupported resource ID %q (expected an EC2 resource ID like i-, vol-, or eni-, or a KMS key ID)", id)
+			}
+			targetIDs[resourceType] = append(targetIDs[resourceType], id)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(targetIDs) == 0 {
+		return nil, fmt.Errorf("no resource IDs found")
+	}
+	return targetIDs, nil
+}
+
+// loadTargetIDs reads resource IDs from a file, or from stdin when path is "-"
+func loadTargetIDs(path string) (map[string][]string, error) {
+	if path == "-" {
+		return readResourceIDs(os.Stdin)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return readResourceIDs(f)
+}
+
+// findUntaggedInstances finds EC2 instances without Name tags
+func findUntaggedInstances(ctx context.Context, config *Config) ([]*ResourceInfo, error) {
+	var instances []*ResourceInfo
+
+	paginator := ec2.NewDescribeInstancesPaginator(
+		config.EC2Client, &ec2.DescribeInstancesInput{
+			InstanceIds: config.TargetIDs["instance"],
+			Filters:     config.Filters,
+			MaxResults:  config.pageSize("DescribeInstances", config.TargetIDs["instance"]),
+		},
+	)
+
+	debugf("DescribeInstances: scanning all instances in %s", config.Region)
+	pages, scanned, needName := 0, 0, 0
+	for morePages(paginator.HasMorePages(), pages, config.MaxPages, "DescribeInstances") {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, wrapPermissionError(err, "ec2:DescribeInstances", "EC2 instances")
+		}
+		pages++
+		for _, reservation := range output.Reservations {
+			scanned += len(reservation.Instances)
+			// Share scanned instances with the volume and ENI name lookups
+			config.Describe.addInstances(reservation.Instances...)
+		}
+		debugf("DescribeInstances: page %d returned %d reservations", pages, len(output.Reservations))
+		config.reportProgress(scanned)
+
+		// Collect this page's AMI IDs to fetch their names in batch
+		var page []*ResourceInfo
+		amiIDs := make(map[string]bool)
+		// Instance type and platform by instance ID for --verbose-names
+		details := make(map[string]string)
+
+		for _, reservation := range output.Reservations {
+			for _, instance := range reservation.Instances {
+				// Malformed responses (e.g. from LocalStack) may omit the ID, which can't be tagged
+				if instance.InstanceId == nil {
+					warnf("Skipping an instance with no InstanceId in the DescribeInstances response")
+					continue
+				}
+
+				var state types.InstanceStateName
+				if instance.State != nil {
+					state = instance.State.Name
+				}
+
+				// Skip terminated instances unless they're only being listed
+				if state == types.InstanceStateNameTerminated && !config.IncludeTerminated {
+					continue
+				}
+				if !config.createdAfter(instance.LaunchTime) {
+					continue
+				}
+
+				// Check if instance has Name tag
+				currentName, hasNameTag := getNameTag(instance.Tags)
+				baseName := config.baseName(currentName, *instance.InstanceId) // Generated names may carry --tag-prefix and --tag-suffix
+
+				// Instances without an AMI reference still need a name; they fall back to their ID
+				imageID := aws.ToString(instance.ImageId)
+
+				// Include instances without Name tags, with empty Name tags, with invalid quick-tag created names,
+				// OR with names that break --name-policy
+				needsTagging := config.PreviewNames || !hasNameTag || currentName == "" || (isGeneratedName(baseName, "instance", instance.Tags) && !naming.GeneratedNameMatchesState(baseName, "instance", string(state), imageID)) || config.NamePolicy.violates("instance", currentName)
+				if needsTagging {
+					if imageID != "" {
+						amiIDs[imageID] = true
+					}
+					if config.VerboseNames {
+						details[*instance.InstanceId] = naming.InstanceDetails(string(instance.InstanceType), aws.ToString(instance.PlatformDetails))
+					}
+
+					page = append(page, &ResourceInfo{
+						ID:            *instance.InstanceId,
+						Type:          "instance",
+						Name:          currentName,
+						EmptyName:     hasNameTag && currentName == "",
+						Tags:          tagMap(instance.Tags),
+						SuggestedName: "", // Will be filled after AMI lookup
+						State:         string(state),
+						Extra:         imageID,
+						MonthlyCost:   config.instanceCost(instance.InstanceType, state),
+					})
+				}
+			}
+		}
+
+		// Name the page's instances after their AMIs before moving on, so they can be streamed
+		amiNames, err := getAMINames(ctx, config, amiIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get AMI names: %v", err)
+		}
+		for _, instance := range page {
+			instance.SuggestedName = naming.InstanceName(instance.ID, instance.Extra, amiNames[instance.Extra], details[instance.ID])
+			switch {
+			case amiNames[instance.Extra] != "":
+				instance.Source = "AMI"
+			case instance.Extra != "":
+				instance.Source = "AMI ID"
+			default:
+				instance.Source = "instance ID"
+			}
+		}
+		needName += len(page)
+		instances = config.collect(instances, page...)
+	}
+
+	debugf("DescribeInstances: %d pages, %d instances scanned, %d need tagging", pages, scanned, needName)
+	config.Stats.recordScan("DescribeInstances", "instance", pages, scanned, needName)
+	logEvent(slog.LevelInfo, "scan finished", "type", "instance", "scanned", scanned, "need_name", needName)
+
+	return instances, nil
+}
+
+// findUntaggedVolumes finds EBS volumes without Name tags
+func findUntaggedVolumes(ctx context.Context, config *Config) ([]*ResourceInfo, error) {
+	var volumes []*ResourceInfo
+
+	paginator := ec2.NewDescribeVolumesPaginator(
+		config.EC2Client, &ec2.DescribeVolumesInput{
+			VolumeIds:  config.TargetIDs["volume"],
+			Filters:    config.Filters,
+			MaxResults: config.pageSize("DescribeVolumes", config.TargetIDs["volume"]),
+		},
+	)
+
+	debugf("DescribeVolumes: scanning all volumes in %s", config.Region)
+	pages, scanned, needName := 0, 0, 0
+	for morePages(paginator.HasMorePages(), pages, config.MaxPages, "DescribeVolumes") {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, wrapPermissionError(err, "ec2:DescribeVolumes", "EBS volumes")
+		}
+		pages++
+		scanned += len(output.Volumes)
+		debugf("DescribeVolumes: page %d returned %d volumes", pages, len(output.Volumes))
+		config.reportProgress(scanned)
+
+		// Collect this page's instance IDs to fetch their names in batch
+		var page []*ResourceInfo
+		instanceIDs := make(map[string]bool)
+		// Volume type and size by volume ID for --verbose-names
+		details := make(map[string]string)
+
+		for _, volume := range output.Volumes {
+			if volume.VolumeId == nil {
+				warnf("Skipping a volume with no VolumeId in the DescribeVolumes response")
+				continue
+			}
+			if !config.createdAfter(volume.CreateTime) {
+				continue
+			}
+
+			// Check if volume has Name tag
+			currentName, hasNameTag := getNameTag(volume.Tags)
+			baseName := config.baseName(currentName, *volume.VolumeId)
+
+			// Include volumes without Name tags, with empty Name tags, OR with invalid quick-tag created names
+			needsTagging := config.PreviewNames || !hasNameTag || currentName == "" || (isGeneratedName(baseName, "volume", volume.Tags) && !naming.GeneratedNameMatchesState(baseName, "volume", string(volume.State), naming.VolumeMountPoint(volume))) || config.NamePolicy.violates("volume", currentName)
+			if needsTagging && config.matchesAttachment(naming.VolumeMountPoint(volume)) {
+				// Collect instance IDs for batch lookup
+				for _, attachment := range volume.Attachments {
+					if attachment.InstanceId != nil {
+						instanceIDs[*attachment.InstanceId] = true
+					}
+				}
+				if config.VerboseNames {
+					details[*volume.VolumeId] = naming.VolumeDetails(string(volume.VolumeType), aws.ToInt32(volume.Size))
+				}
+
+				page = append(page, &ResourceInfo{
+					ID:            *volume.VolumeId,
+					Type:          "volume",
+					Name:          currentName,
+					EmptyName:     hasNameTag && currentName == "",
+					Tags:          tagMap(volume.Tags),
+					SuggestedName: "", // Will be filled after instance lookup
+					State:         string(volume.State),
+					Extra:         naming.VolumeMountPoint(volume),
+					InstanceID:    getVolumeInstanceID(volume),
+					MonthlyCost:   config.volumeCost(volume),
+				})
+			}
+		}
+
+		// Name the page's volumes after their instances
+		instanceNames, err := getInstanceNames(ctx, config, instanceIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get instance names: %v", err)
+		}
+		for _, volume := range page {
+			volume.SuggestedName = naming.VolumeName(volume.InstanceID, instanceNames[volume.InstanceID], volume.Extra, details[volume.ID])
+			volume.Source = "attachment state"
+			if volume.InstanceID != "" {
+				volume.Source = "instance " + volume.InstanceID
+			}
+		}
+		needName += len(page)
+		volumes = config.collect(volumes, page...)
+	}
+
+	debugf("DescribeVolumes: %d pages, %d volumes scanned, %d need tagging", pages, scanned, needName)
+	config.Stats.recordScan("DescribeVolumes", "volume", pages, scanned, needName)
+	logEvent(slog.LevelInfo, "scan finished", "type", "volume", "scanned", scanned, "need_name", needName)
+
+	return volumes, nil
+}
+
+// findUntaggedENIs finds ENIs without Name tags
+func findUntaggedENIs(ctx context.Context, config *Config) ([]*ResourceInfo, error) {
+	paginator := ec2.NewDescribeNetworkInterfacesPaginator(
+		config.EC2Client, &ec2.DescribeNetworkInterfacesInput{
+			NetworkInterfaceIds: config.TargetIDs["eni"],
+			Filters:             config.Filters,
+			MaxResults:          config.pageSize("DescribeNetworkInterfaces", config.TargetIDs["eni"]),
+		},
+	)
+
+	var eniList []*ResourceInfo
+
+	debugf("DescribeNetworkInterfaces: scanning all ENIs in %s", config.Region)
+	pages, scanned, needName := 0, 0, 0
+	for morePages(paginator.HasMorePages(), pages, config.MaxPages, "DescribeNetworkInterfaces") {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, wrapPermissionError(err, "ec2:DescribeNetworkInterfaces", "ENIs")
+		}
+		pages++
+		scanned += len(output.NetworkInterfaces)
+		debugf("DescribeNetworkInterfaces: page %d returned %d ENIs", pages, len(output.NetworkInterfaces))
+		config.reportProgress(scanned)
+
+		// Collect this page's attachment IDs for batch lookup
+		var page []*ResourceInfo
+		attachmentIDs := make(map[string]bool)
+
+		for _, eni := range output.NetworkInterfaces {
+			if eni.NetworkInterfaceId == nil {
+				warnf("Skipping an ENI with no NetworkInterfaceId in the DescribeNetworkInterfaces response")
+				continue
+			}
+
+			// Check if ENI has Name tag
+			currentName, hasNameTag := getNameTag(eni.TagSet)
+			baseName := config.baseName(currentName, *eni.NetworkInterfaceId)
+
+			// Include ENIs without Name tags, with empty Name tags, OR with invalid quick-tag created names
+			needsTagging := config.PreviewNames || !hasNameTag || currentName == "" || (isGeneratedName(baseName, "eni", eni.TagSet) && !naming.GeneratedNameMatchesState(baseName, "eni", string(eni.Status), naming.ENIAttachmentInfo(eni))) || config.NamePolicy.violates("eni", currentName)
+			if config.SkipAWSDefaults && naming.IsAWSDefaultName(currentName, "eni") {
+				needsTagging = false
+			}
+			if needsTagging && config.matchesAttachment(naming.ENIAttachmentInfo(eni)) {
+				// Collect attachment IDs for batch lookup (only for EC2 instances)
+				if eni.Attachment != nil && eni.Attachment.InstanceId != nil {
+					attachmentIDs[*eni.Attachment.InstanceId] = true
+				}
+
+				page = append(page, &ResourceInfo{
+					ID:            *eni.NetworkInterfaceId,
+					Type:          "eni",
+					Name:          currentName,
+					EmptyName:     hasNameTag && currentName == "",
+					Tags:          tagMap(eni.TagSet),
+					SuggestedName: "", // Will be filled after attachment lookup
+					State:         string(eni.Status),
+					Extra:         naming.ENIAttachmentInfo(eni),
+					// Store the original ENI for later processing
+				})
+			}
+		}
+
+		// Name the page's ENIs after their attachments (only EC2 instances need a lookup)
+		attachmentNames, err := getAttachmentNames(ctx, config, attachmentIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get attachment names: %v", err)
+		}
+		for _, eni := range page {
+			eni.Source = eniNameSource(eni.Extra)
+			eni.SuggestedName, eni.Extra = naming.ENIName(eni.Extra, attachmentNames)
+		}
+		needName += len(page)
+		eniList = config.collect(eniList, page...)
+	}
+
+	debugf("DescribeNetworkInterfaces: %d pages, %d ENIs scanned, %d need tagging", pages, scanned, needName)
+	config.Stats.recordScan("DescribeNetworkInterfaces", "eni", pages, scanned, needName)
+	logEvent(slog.LevelInfo, "scan finished", "type", "eni", "scanned", scanned, "need_name", needName)
+
+	return eniList, nil
+}
+
+// eniNameSource describes what an ENI's suggested name comes from, given its ENIAttachmentInfo
+func eniNameSource(attachmentInfo string) string {
+	if instanceID, ok := strings.CutPrefix(attachmentInfo, "attached-to-"); ok {
+		return "instance " + instanceID
+	}
+	if service, ok := strings.CutPrefix(attachmentInfo, "attached-"); ok {
+		service, _, _ = strings.Cut(service, "-")
+		if service == "elb" {
+			return "ELB"
+		}
+		return service + " attachment"
+	}
+	return "attachment state"
+}
+
+// findUntaggedAddresses finds Elastic IPs without Name tags
+func findUntaggedAddresses(ctx context.Context, config *Config) ([]*ResourceInfo, error) {
+	// DescribeAddresses is not paginated and returns every address in one call
+	debugf("DescribeAddresses: scanning all Elastic IPs in %s", config.Region)
+	output, err := config.EC2Client.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{
+		AllocationIds: config.TargetIDs["eip"],
+		Filters:       config.Filters,
+	})
+	if err != nil {
+		return nil, wrapPermissionError(err, "ec2:DescribeAddresses", "Elastic IPs")
+	}
+
+	instanceIDs := make(map[string]bool)
+	var addresses []*ResourceInfo
+	for _, address := range output.Addresses {
+		// EC2-Classic addresses have no allocation ID and can't be tagged
+		if address.AllocationId == nil {
+			continue
+		}
+
+		currentName, hasNameTag := getNameTag(address.Tags)
+		baseName := config.baseName(currentName, *address.AllocationId)
+		state := getAddressState(address)
+		needsTagging := config.PreviewNames || !hasNameTag || currentName == "" || (isGeneratedName(baseName, "eip", address.Tags) && !naming.GeneratedNameMatchesState(baseName, "eip", state, "")) || config.NamePolicy.violates("eip", currentName)
+		if !needsTagging {
+			continue
+		}
 
-	// Find untagged volumes
-	volumes, err := showProgressWithResult("Scanning EBS volumes...", func() ([]*ResourceInfo, error) {
-		return findUntaggedVolumes(ctx, config)
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to find untagged volumes: %v", err)
+		resource := &ResourceInfo{
+			ID:        *address.AllocationId,
+			Type:      "eip",
+			Name:      currentName,
+			EmptyName: hasNameTag && currentName == "",
+			Tags:      tagMap(address.Tags),
+			State:     state,
+			Extra:     aws.ToString(address.PublicIp),
+		}
+		switch {
+		case address.InstanceId != nil:
+			resource.InstanceID = *address.InstanceId
+			resource.Source = "instance " + *address.InstanceId
+			instanceIDs[*address.InstanceId] = true
+		case address.NetworkInterfaceId != nil:
+			resource.SuggestedName = naming.EIPName("", "", *address.NetworkInterfaceId)
+			resource.Source = "ENI " + *address.NetworkInterfaceId
+		default:
+			resource.SuggestedName = naming.EIPName("", "", "")
+			resource.Source = "association state"
+		}
+		addresses = append(addresses, resource)
 	}
-	resources = append(resources, volumes...)
 
-	// Find untagged ENIs
-	enis, err := showProgressWithResult("Scanning ENIs...", func() ([]*ResourceInfo, error) {
-		return findUntaggedENIs(ctx, config)
-	})
+	debugf("DescribeAddresses: %d Elastic IPs scanned, %d need tagging", len(output.Addresses), len(addresses))
+	config.Stats.recordScan("DescribeAddresses", "eip", 1, len(output.Addresses), len(addresses))
+	logEvent(slog.LevelInfo, "scan finished", "type", "eip", "scanned", len(output.Addresses), "need_name", len(addresses))
+
+	instanceNames, err := getInstanceNames(ctx, config, instanceIDs)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find untagged ENIs: %v", err)
+		return nil, fmt.Errorf("failed to get instance names: %v", err)
 	}
-	resources = append(resources, enis...)
 
-	// Sort by type, then by ID
-	sort.Slice(resources, func(i, j int) bool {
-		if resources[i].Type != resources[j].Type {
-			return resources[i].Type < resources[j].Type
+	for _, address := range addresses {
+		if address.InstanceID != "" {
+			address.SuggestedName = naming.EIPName(address.InstanceID, instanceNames[address.InstanceID], "")
 		}
-		return resources[i].ID < resources[j].ID
-	})
+	}
 
-	return resources, nil
+	return config.collect(nil, addresses...), nil
 }
 
-// findUntaggedInstances finds EC2 instances without Name tags
-func findUntaggedInstances(ctx context.Context, config *Config) ([]*ResourceInfo, error) {
-	var instances []*ResourceInfo
+// getAddressState reports whether an Elastic IP is associated with an instance or ENI
+func getAddressState(address types.Address) string {
+	if address.AssociationId != nil || address.InstanceId != nil || address.NetworkInterfaceId != nil {
+		return "associated"
+	}
+	return "unassociated"
+}
 
-	paginator := ec2.NewDescribeInstancesPaginator(
-		config.EC2Client, &ec2.DescribeInstancesInput{},
+// findUntaggedVPCs finds VPCs without Name tags
+func findUntaggedVPCs(ctx context.Context, config *Config) ([]*ResourceInfo, error) {
+	paginator := ec2.NewDescribeVpcsPaginator(
+		config.EC2Client, &ec2.DescribeVpcsInput{
+			VpcIds:  config.TargetIDs["vpc"],
+			Filters: config.Filters,
+		},
 	)
 
-	// Collect all AMI IDs to fetch their names in batch
-	amiIDs := make(map[string]bool)
-
-	for paginator.HasMorePages() {
+	var vpcs []*ResourceInfo
+	debugf("DescribeVpcs: scanning all VPCs in %s", config.Region)
+	pages, scanned, needName := 0, 0, 0
+	for morePages(paginator.HasMorePages(), pages, config.MaxPages, "DescribeVpcs") {
 		output, err := paginator.NextPage(ctx)
 		if err != nil {
-			return nil, err
+			return nil, wrapPermissionError(err, "ec2:DescribeVpcs", "VPCs")
 		}
+		pages++
+		scanned += len(output.Vpcs)
+		debugf("DescribeVpcs: page %d returned %d VPCs", pages, len(output.Vpcs))
+		config.reportProgress(scanned)
+
+		for _, vpc := range output.Vpcs {
+			if vpc.VpcId == nil {
+				warnf("Skipping a VPC with no VpcId in the DescribeVpcs response")
+				continue
+			}
 
-		for _, reservation := range output.Reservations {
-			for _, instance := range reservation.Instances {
-				// Skip terminated instances
-				if instance.State.Name == types.InstanceStateNameTerminated {
-					continue
-				}
+			currentName, hasNameTag := getNameTag(vpc.Tags)
+			baseName := config.baseName(currentName, *vpc.VpcId)
+			cidrBlock := aws.ToString(vpc.CidrBlock)
+			needsTagging := config.PreviewNames || !hasNameTag || currentName == "" || (isGeneratedName(baseName, "vpc", vpc.Tags) && !naming.GeneratedNameMatchesState(baseName, "vpc", string(vpc.State), cidrBlock)) || config.NamePolicy.violates("vpc", currentName)
+			if !needsTagging {
+				continue
+			}
 
-				// Check if instance has Name tag
-				hasNameTag := false
-				var currentName string
-				for _, tag := range instance.Tags {
-					if tag.Key != nil && *tag.Key == "Name" && tag.Value != nil {
-						hasNameTag = true
-						currentName = *tag.Value
-						break
-					}
-				}
+			needName++
+			vpcs = config.collect(vpcs, &ResourceInfo{
+				ID:            *vpc.VpcId,
+				Type:          "vpc",
+				Name:          currentName,
+				EmptyName:     hasNameTag && currentName == "",
+				Tags:          tagMap(vpc.Tags),
+				SuggestedName: naming.VPCName(cidrBlock, aws.ToBool(vpc.IsDefault)),
+				State:         string(vpc.State),
+				Extra:         cidrBlock,
+				Source:        "CIDR block",
+			})
+		}
+	}
 
-				// Include instances without Name tags OR with invalid quick-tag created names
-				needsTagging := !hasNameTag || (hasNameTag && isQuickTagCreatedName(currentName, "instance") && !isQuickTagNameStillValid(currentName, "instance", string(instance.State.Name), *instance.ImageId))
-				if needsTagging && instance.ImageId != nil {
-					amiIDs[*instance.ImageId] = true
+	debugf("DescribeVpcs: %d pages, %d VPCs scanned, %d need tagging", pages, scanned, needName)
+	config.Stats.recordScan("DescribeVpcs", "vpc", pages, scanned, needName)
+	logEvent(slog.LevelInfo, "scan finished", "type", "vpc", "scanned", scanned, "need_name", needName)
 
-					instances = append(instances, &ResourceInfo{
-						ID:            *instance.InstanceId,
-						Type:          "instance",
-						Name:          currentName,
-						SuggestedName: "", // Will be filled after AMI lookup
-						State:         string(instance.State.Name),
-						Extra:         *instance.ImageId,
-					})
-				}
-			}
+	return vpcs, nil
+}
+
+// findUntaggedSubnets finds subnets without Name tags
+func findUntaggedSubnets(ctx context.Context, config *Config) ([]*ResourceInfo, error) {
+	paginator := ec2.NewDescribeSubnetsPaginator(
+		config.EC2Client, &ec2.DescribeSubnetsInput{
+			SubnetIds: config.TargetIDs["subnet"],
+			Filters:   config.Filters,
+		},
+	)
+
+	var subnets []*ResourceInfo
+	debugf("DescribeSubnets: scanning all subnets in %s", config.Region)
+	pages, scanned, needName := 0, 0, 0
+	for morePages(paginator.HasMorePages(), pages, config.MaxPages, "DescribeSubnets") {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, wrapPermissionError(err, "ec2:DescribeSubnets", "subnets")
 		}
-	}
+		pages++
+		scanned += len(output.Subnets)
+		debugf("DescribeSubnets: page %d returned %d subnets", pages, len(output.Subnets))
+		config.reportProgress(scanned)
+
+		for _, subnet := range output.Subnets {
+			if subnet.SubnetId == nil {
+				warnf("Skipping a subnet with no SubnetId in the DescribeSubnets response")
+				continue
+			}
 
-	// Fetch AMI names in batch
-	amiNames, err := getAMINames(ctx, config, amiIDs)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get AMI names: %v", err)
-	}
+			currentName, hasNameTag := getNameTag(subnet.Tags)
+			baseName := config.baseName(currentName, *subnet.SubnetId)
+			cidrBlock := aws.ToString(subnet.CidrBlock)
+			needsTagging := config.PreviewNames || !hasNameTag || currentName == "" || (isGeneratedName(baseName, "subnet", subnet.Tags) && !naming.GeneratedNameMatchesState(baseName, "subnet", string(subnet.State), cidrBlock)) || config.NamePolicy.violates("subnet", currentName)
+			if !needsTagging {
+				continue
+			}
 
-	// Update suggested names with actual AMI names
-	for _, instance := range instances {
-		if amiName, exists := amiNames[instance.Extra]; exists {
-			instance.SuggestedName = amiName
-		} else {
-			instance.SuggestedName = fmt.Sprintf("instance-%s", instance.Extra)
+			needName++
+			subnets = config.collect(subnets, &ResourceInfo{
+				ID:            *subnet.SubnetId,
+				Type:          "subnet",
+				Name:          currentName,
+				EmptyName:     hasNameTag && currentName == "",
+				Tags:          tagMap(subnet.Tags),
+				SuggestedName: naming.SubnetName(aws.ToString(subnet.VpcId), cidrBlock),
+				State:         string(subnet.State),
+				Extra:         cidrBlock,
+				Source:        "VPC and CIDR block",
+			})
 		}
 	}
 
-	return instances, nil
-}
+	debugf("DescribeSubnets: %d pages, %d subnets scanned, %d need tagging", pages, scanned, needName)
+	config.Stats.recordScan("DescribeSubnets", "subnet", pages, scanned, needName)
+	logEvent(slog.LevelInfo, "scan finished", "type", "subnet", "scanned", scanned, "need_name", needName)
 
-// findUntaggedVolumes finds EBS volumes without Name tags
-func findUntaggedVolumes(ctx context.Context, config *Config) ([]*ResourceInfo, error) {
-	var volumes []*ResourceInfo
+	return subnets, nil
+}
 
-	paginator := ec2.NewDescribeVolumesPaginator(
-		config.EC2Client, &ec2.DescribeVolumesInput{},
+// findUntaggedNatGateways finds NAT gateways without Name tags. Deleted gateways, which
+// DescribeNatGateways lists for about an hour, are skipped.
+func findUntaggedNatGateways(ctx context.Context, config *Config) ([]*ResourceInfo, error) {
+	paginator := ec2.NewDescribeNatGatewaysPaginator(
+		config.EC2Client, &ec2.DescribeNatGatewaysInput{
+			NatGatewayIds: config.TargetIDs["nat-gateway"],
+			Filter:        config.Filters,
+		},
 	)
 
-	// Collect all instance IDs to fetch their names in batch
-	instanceIDs := make(map[string]bool)
-
-	for paginator.HasMorePages() {
+	var natGateways []*ResourceInfo
+	debugf("DescribeNatGateways: scanning all NAT gateways in %s", config.Region)
+	pages, scanned, needName := 0, 0, 0
+	for morePages(paginator.HasMorePages(), pages, config.MaxPages, "DescribeNatGateways") {
 		output, err := paginator.NextPage(ctx)
 		if err != nil {
-			return nil, err
+			return nil, wrapPermissionError(err, "ec2:DescribeNatGateways", "NAT gateways")
 		}
+		pages++
+		scanned += len(output.NatGateways)
+		debugf("DescribeNatGateways: page %d returned %d NAT gateways", pages, len(output.NatGateways))
+		config.reportProgress(scanned)
+
+		for _, natGateway := range output.NatGateways {
+			if natGateway.NatGatewayId == nil {
+				warnf("Skipping a NAT gateway with no NatGatewayId in the DescribeNatGateways response")
+				continue
+			}
+			if natGateway.State == types.NatGatewayStateDeleting || natGateway.State == types.NatGatewayStateDeleted {
+				continue
+			}
+			if !config.createdAfter(natGateway.CreateTime) {
+				continue
+			}
 
-		for _, volume := range output.Volumes {
-			// Check if volume has Name tag
-			hasNameTag := false
-			var currentName string
-			for _, tag := range volume.Tags {
-				if tag.Key != nil && *tag.Key == "Name" && tag.Value != nil {
-					hasNameTag = true
-					currentName = *tag.Value
-					break
-				}
+			currentName, hasNameTag := getNameTag(natGateway.Tags)
+			baseName := config.baseName(currentName, *natGateway.NatGatewayId)
+			needsTagging := config.PreviewNames || !hasNameTag || currentName == "" || (isGeneratedName(baseName, "nat-gateway", natGateway.Tags) && !naming.GeneratedNameMatchesState(baseName, "nat-gateway", string(natGateway.State), "")) || config.NamePolicy.violates("nat-gateway", currentName)
+			if !needsTagging {
+				continue
 			}
 
-			// Include volumes without Name tags OR with invalid quick-tag created names
-			needsTagging := !hasNameTag || (hasNameTag && isQuickTagCreatedName(currentName, "volume") && !isQuickTagNameStillValid(currentName, "volume", string(volume.State), getVolumeMountPoint(volume)))
-			if needsTagging {
-				// Collect instance IDs for batch lookup
-				for _, attachment := range volume.Attachments {
-					if attachment.InstanceId != nil {
-						instanceIDs[*attachment.InstanceId] = true
-					}
+			var publicIP string
+			for _, address := range natGateway.NatGatewayAddresses {
+				if address.PublicIp != nil {
+					publicIP = *address.PublicIp
+					break
 				}
-
-				volumes = append(volumes, &ResourceInfo{
-					ID:            *volume.VolumeId,
-					Type:          "volume",
-					Name:          currentName,
-					SuggestedName: "", // Will be filled after instance lookup
-					State:         string(volume.State),
-					Extra:         getVolumeMountPoint(volume),
-				})
 			}
-		}
-	}
-
-	// Fetch instance names in batch
-	instanceNames, err := getInstanceNames(ctx, config, instanceIDs)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get instance names: %v", err)
-	}
-
-	// Update suggested names with actual instance names
-	for _, volume := range volumes {
-		// Find the attached instance ID for this volume
-		attachedInstanceID := getVolumeInstanceID(volume.ID, config.EC2Client, ctx)
-		if attachedInstanceID != "" {
-			if instanceName, exists := instanceNames[attachedInstanceID]; exists {
-				volume.SuggestedName = fmt.Sprintf("%s(%s) %s", attachedInstanceID, instanceName, volume.Extra)
-			} else {
-				volume.SuggestedName = fmt.Sprintf("%s %s", attachedInstanceID, volume.Extra)
+			var source string
+			switch {
+			case natGateway.SubnetId != nil:
+				source = "subnet " + *natGateway.SubnetId
+			case natGateway.VpcId != nil:
+				source = "VPC " + *natGateway.VpcId
+			case publicIP != "":
+				source = "public IP"
 			}
-		} else {
-			// For unattached volumes, just use "unattached" without duplicating
-			volume.SuggestedName = "unattached"
+
+			needName++
+			natGateways = config.collect(natGateways, &ResourceInfo{
+				ID:            *natGateway.NatGatewayId,
+				Type:          "nat-gateway",
+				Name:          currentName,
+				EmptyName:     hasNameTag && currentName == "",
+				Tags:          tagMap(natGateway.Tags),
+				SuggestedName: naming.NATGatewayName(aws.ToString(natGateway.SubnetId), aws.ToString(natGateway.VpcId), publicIP),
+				State:         string(natGateway.State),
+				Extra:         publicIP,
+				Source:        source,
+			})
 		}
 	}
 
-	return volumes, nil
+	debugf("DescribeNatGateways: %d pages, %d NAT gateways scanned, %d need tagging", pages, scanned, needName)
+	config.Stats.recordScan("DescribeNatGateways", "nat-gateway", pages, scanned, needName)
+	logEvent(slog.LevelInfo, "scan finished", "type", "nat-gateway", "scanned", scanned, "need_name", needName)
+
+	return natGateways, nil
 }
 
-// findUntaggedENIs finds ENIs without Name tags
-func findUntaggedENIs(ctx context.Context, config *Config) ([]*ResourceInfo, error) {
-	paginator := ec2.NewDescribeNetworkInterfacesPaginator(
-		config.EC2Client, &ec2.DescribeNetworkInterfacesInput{},
+// findUntaggedInternetGateways finds internet gateways without Name tags, naming them after
+// the VPC they're attached to
+func findUntaggedInternetGateways(ctx context.Context, config *Config) ([]*ResourceInfo, error) {
+	paginator := ec2.NewDescribeInternetGatewaysPaginator(
+		config.EC2Client, &ec2.DescribeInternetGatewaysInput{
+			InternetGatewayIds: config.TargetIDs["igw"],
+			Filters:            config.Filters,
+		},
 	)
 
-	// Collect all attachment IDs for batch lookup
-	attachmentIDs := make(map[string]bool)
-	var eniList []*ResourceInfo
-
-	for paginator.HasMorePages() {
+	var internetGateways []*ResourceInfo
+	debugf("DescribeInternetGateways: scanning all internet gateways in %s", config.Region)
+	pages, scanned, needName := 0, 0, 0
+	for morePages(paginator.HasMorePages(), pages, config.MaxPages, "DescribeInternetGateways") {
 		output, err := paginator.NextPage(ctx)
 		if err != nil {
-			return nil, err
+			return nil, wrapPermissionError(err, "ec2:DescribeInternetGateways", "internet gateways")
 		}
+		pages++
+		scanned += len(output.InternetGateways)
+		debugf("DescribeInternetGateways: page %d returned %d internet gateways", pages, len(output.InternetGateways))
+		config.reportProgress(scanned)
+
+		for _, internetGateway := range output.InternetGateways {
+			if internetGateway.InternetGatewayId == nil {
+				warnf("Skipping an internet gateway with no InternetGatewayId in the DescribeInternetGateways response")
+				continue
+			}
 
-		for _, eni := range output.NetworkInterfaces {
-			// Check if ENI has Name tag
-			hasNameTag := false
-			var currentName string
-			for _, tag := range eni.TagSet {
-				if tag.Key != nil && *tag.Key == "Name" && tag.Value != nil {
-					hasNameTag = true
-					currentName = *tag.Value
+			// An internet gateway attaches to at most one VPC
+			var vpcID string
+			state := "detached"
+			for _, attachment := range internetGateway.Attachments {
+				if attachment.VpcId != nil && attachment.State != types.AttachmentStatusDetached {
+					vpcID = *attachment.VpcId
+					state = "attached"
 					break
 				}
 			}
+			suggestedName := naming.InternetGatewayName(vpcID)
+			source := "attachment state"
+			if vpcID != "" {
+				source = "VPC " + vpcID
+			}
 
-			// Include ENIs without Name tags OR with invalid quick-tag created names
-			needsTagging := !hasNameTag || (hasNameTag && isQuickTagCreatedName(currentName, "eni") && !isQuickTagNameStillValid(currentName, "eni", string(eni.Status), getENIAttachmentInfo(eni)))
-			if needsTagging {
-				// Collect attachment IDs for batch lookup (only for EC2 instances)
-				if eni.Attachment != nil && eni.Attachment.InstanceId != nil {
-					attachmentIDs[*eni.Attachment.InstanceId] = true
-				}
-
-				eniList = append(eniList, &ResourceInfo{
-					ID:            *eni.NetworkInterfaceId,
-					Type:          "eni",
-					Name:          currentName,
-					SuggestedName: "", // Will be filled after attachment lookup
-					State:         string(eni.Status),
-					Extra:         getENIAttachmentInfo(eni),
-					// Store the original ENI for later processing
-				})
+			currentName, hasNameTag := getNameTag(internetGateway.Tags)
+			baseName := config.baseName(currentName, *internetGateway.InternetGatewayId)
+			needsTagging := config.PreviewNames || !hasNameTag || currentName == "" || (isGeneratedName(baseName, "igw", internetGateway.Tags) && !naming.GeneratedNameMatchesState(baseName, "igw", state, suggestedName)) || config.NamePolicy.violates("igw", currentName)
+			if !needsTagging {
+				continue
 			}
+
+			needName++
+			internetGateways = config.collect(internetGateways, &ResourceInfo{
+				ID:            *internetGateway.InternetGatewayId,
+				Type:          "igw",
+				Name:          currentName,
+				EmptyName:     hasNameTag && currentName == "",
+				Tags:          tagMap(internetGateway.Tags),
+				SuggestedName: suggestedName,
+				State:         state,
+				Extra:         vpcID,
+				Source:        source,
+			})
 		}
 	}
 
-	// Fetch attachment names in batch (only for EC2 instances)
-	attachmentNames, err := getAttachmentNames(ctx, config, attachmentIDs)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get attachment names: %v", err)
-	}
-
-	// Update suggested names with actual attachment names
-	for _, eni := range eniList {
-		// Check if this ENI is attached to an EC2 instance
-		if strings.HasPrefix(eni.Extra, "attached-to-") {
-			instanceID := strings.TrimPrefix(eni.Extra, "attached-to-")
-			if attachmentName, exists := attachmentNames[instanceID]; exists {
-				eni.SuggestedName = fmt.Sprintf("%s-eni", attachmentName)
-				// Format the Extra field to show "ID (name)"
-				eni.Extra = fmt.Sprintf("%s (%s)", instanceID, attachmentName)
-			} else {
-				eni.SuggestedName = fmt.Sprintf("%s-eni", instanceID)
-				// Just show the instance ID if no name found
-				eni.Extra = instanceID
-			}
-		} else if strings.HasPrefix(eni.Extra, "attached-") {
-			// For service attachments (NAT, RDS, ElastiCache, ELB, Lambda, etc.), extract the type and ID
-			parts := strings.SplitN(eni.Extra, "-", 3) // attached-type-id or attached-elb-name
-			if len(parts) >= 3 {
-				attachmentType := parts[1] // nat, rds, elasticache, elb, lambda, etc.
-				attachmentID := parts[2]   // the actual attachment ID or name
-
-				// Special handling for ELB with extracted name
-				if attachmentType == "elb" {
-					eni.SuggestedName = fmt.Sprintf("%s-eni", attachmentID)
-					eni.Extra = fmt.Sprintf("elb-%s", attachmentID)
-				} else {
-					// For other service types, use the standard naming
-					eni.SuggestedName = fmt.Sprintf("%s-%s-eni", attachmentType, attachmentID)
-					eni.Extra = fmt.Sprintf("%s-attachment-%s", attachmentType, attachmentID)
+	debugf("DescribeInternetGateways: %d pages, %d internet gateways scanned, %d need tagging", pages, scanned, needName)
+	config.Stats.recordScan("DescribeInternetGateways", "igw", pages, scanned, needName)
+	logEvent(slog.LevelInfo, "scan finished", "type", "igw", "scanned", scanned, "need_name", needName)
+
+	return internetGateways, nil
+}
+
+// findUntaggedTransitGateways finds transit gateways without Name tags, naming them after their
+// VPC attachments. Deleted gateways are skipped.
+func findUntaggedTransitGateways(ctx context.Context, config *Config) ([]*ResourceInfo, error) {
+	paginator := ec2.NewDescribeTransitGatewaysPaginator(
+		config.EC2Client, &ec2.DescribeTransitGatewaysInput{
+			TransitGatewayIds: config.TargetIDs["tgw"],
+			Filters:           config.Filters,
+		},
+	)
+
+	var transitGateways []*ResourceInfo
+	var attachedVPCs map[string][]string // Looked up on the first transit gateway found
+	debugf("DescribeTransitGateways: scanning all transit gateways in %s", config.Region)
+	pages, scanned, needName := 0, 0, 0
+	for morePages(paginator.HasMorePages(), pages, config.MaxPages, "DescribeTransitGateways") {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, wrapPermissionError(err, "ec2:DescribeTransitGateways", "transit gateways")
+		}
+		pages++
+		scanned += len(output.TransitGateways)
+		debugf("DescribeTransitGateways: page %d returned %d transit gateways", pages, len(output.TransitGateways))
+		config.reportProgress(scanned)
+
+		for _, transitGateway := range output.TransitGateways {
+			if transitGateway.TransitGatewayId == nil {
+				warnf("Skipping a transit gateway with no TransitGatewayId in the DescribeTransitGateways response")
+				continue
+			}
+			if transitGateway.State == types.TransitGatewayStateDeleting || transitGateway.State == types.TransitGatewayStateDeleted {
+				continue
+			}
+			if !config.createdAfter(transitGateway.CreationTime) {
+				continue
+			}
+
+			if attachedVPCs == nil {
+				attachedVPCs, err = transitGatewayVPCs(ctx, config.EC2Client, config)
+				if err != nil {
+					return nil, err
 				}
-			} else {
-				// Fallback for unexpected format
-				attachmentID := strings.TrimPrefix(eni.Extra, "attached-")
-				eni.SuggestedName = fmt.Sprintf("service-%s-eni", attachmentID)
-				eni.Extra = fmt.Sprintf("service-attachment-%s", attachmentID)
 			}
-		} else {
-			// Unattached ENI
-			eni.SuggestedName = "unattached-eni"
-			eni.Extra = "unattached"
+			vpcIDs := attachedVPCs[*transitGateway.TransitGatewayId]
+			suggestedName := naming.TransitGatewayName(vpcIDs)
+
+			currentName, hasNameTag := getNameTag(transitGateway.Tags)
+			baseName := config.baseName(currentName, *transitGateway.TransitGatewayId)
+			needsTagging := config.PreviewNames || !hasNameTag || currentName == "" || (isGeneratedName(baseName, "tgw", transitGateway.Tags) && !naming.GeneratedNameMatchesState(baseName, "tgw", string(transitGateway.State), suggestedName)) || config.NamePolicy.violates("tgw", currentName)
+			if !needsTagging {
+				continue
+			}
+
+			needName++
+			transitGateways = config.collect(transitGateways, &ResourceInfo{
+				ID:            *transitGateway.TransitGatewayId,
+				Type:          "tgw",
+				Name:          currentName,
+				EmptyName:     hasNameTag && currentName == "",
+				Tags:          tagMap(transitGateway.Tags),
+				SuggestedName: suggestedName,
+				State:         string(transitGateway.State),
+				Extra:         strings.Join(vpcIDs, ","),
+				Source:        "VPC attachments",
+			})
 		}
 	}
 
-	return eniList, nil
+	debugf("DescribeTransitGateways: %d pages, %d transit gateways scanned, %d need tagging", pages, scanned, needName)
+	config.Stats.recordScan("DescribeTransitGateways", "tgw", pages, scanned, needName)
+	logEvent(slog.LevelInfo, "scan finished", "type", "tgw", "scanned", scanned, "need_name", needName)
+
+	return transitGateways, nil
 }
 
-// getAMINames fetches AMI names for the given AMI IDs
-func getAMINames(ctx context.Context, config *Config, amiIDs map[string]bool) (map[string]string, error) {
-	if len(amiIDs) == 0 {
-		return make(map[string]string), nil
-	}
+// transitGatewayVPCs maps transit gateway IDs to the sorted IDs of the VPCs attached to them,
+// honoring --max-pages and counting the pages in --stats
+func transitGatewayVPCs(ctx context.Context, client ec2.DescribeTransitGatewayAttachmentsAPIClient, config *Config) (map[string][]string, error) {
+	paginator := ec2.NewDescribeTransitGatewayAttachmentsPaginator(
+		client, &ec2.DescribeTransitGatewayAttachmentsInput{
+			Filters: []types.Filter{{Name: aws.String("resource-type"), Values: []string{"vpc"}}},
+		},
+	)
 
-	// Convert map keys to slice
-	var amiIDSlice []string
-	for amiID := range amiIDs {
-		amiIDSlice = append(amiIDSlice, amiID)
+	vpcs := make(map[string][]string)
+	pages := 0
+	for morePages(paginator.HasMorePages(), pages, config.MaxPages, "DescribeTransitGatewayAttachments") {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, wrapPermissionError(err, "ec2:DescribeTransitGatewayAttachments", "transit gateway attachments")
+		}
+		pages++
+		debugf("DescribeTransitGatewayAttachments: page %d returned %d attachments", pages, len(output.TransitGatewayAttachments))
+		for _, attachment := range output.TransitGatewayAttachments {
+			if attachment.TransitGatewayId == nil || attachment.ResourceId == nil {
+				continue
+			}
+			switch attachment.State {
+			case types.TransitGatewayAttachmentStateDeleting, types.TransitGatewayAttachmentStateDeleted,
+				types.TransitGatewayAttachmentStateFailed, types.TransitGatewayAttachmentStateRejected:
+				continue
+			}
+			vpcs[*attachment.TransitGatewayId] = append(vpcs[*attachment.TransitGatewayId], *attachment.ResourceId)
+		}
 	}
+	for _, ids := range vpcs {
+		slices.Sort(ids)
+	}
+	config.Stats.recordScan("DescribeTransitGatewayAttachments", "tgw", pages, 0, 0)
+	return vpcs, nil
+}
 
-	// Describe AMIs in batches (AWS limit is 200 per request)
-	amiNames := make(map[string]string)
-	batchSize := 200
-
-	for i := 0; i < len(amiIDSlice); i += batchSize {
-		end := min(i+batchSize, len(amiIDSlice))
-		batch := amiIDSlice[i:end]
+// findUntaggedRouteTables finds route tables without Name tags
+func findUntaggedRouteTables(ctx context.Context, config *Config) ([]*ResourceInfo, error) {
+	paginator := ec2.NewDescribeRouteTablesPaginator(
+		config.EC2Client, &ec2.DescribeRouteTablesInput{
+			RouteTableIds: config.TargetIDs["route-table"],
+			Filters:       config.Filters,
+		},
+	)
 
-		output, err := config.EC2Client.DescribeImages(ctx, &ec2.DescribeImagesInput{
-			ImageIds: batch,
-		})
+	var routeTables []*ResourceInfo
+	debugf("DescribeRouteTables: scanning all route tables in %s", config.Region)
+	pages, scanned, needName := 0, 0, 0
+	for morePages(paginator.HasMorePages(), pages, config.MaxPages, "DescribeRouteTables") {
+		output, err := paginator.NextPage(ctx)
 		if err != nil {
-			return nil, err
+			return nil, wrapPermissionError(err, "ec2:DescribeRouteTables", "route tables")
 		}
+		pages++
+		scanned += len(output.RouteTables)
+		debugf("DescribeRouteTables: page %d returned %d route tables", pages, len(output.RouteTables))
+		config.reportProgress(scanned)
+
+		for _, routeTable := range output.RouteTables {
+			if routeTable.RouteTableId == nil {
+				warnf("Skipping a route table with no RouteTableId in the DescribeRouteTables response")
+				continue
+			}
 
-		for _, image := range output.Images {
-			if image.ImageId != nil && image.Name != nil {
-				amiNames[*image.ImageId] = *image.Name
+			currentName, hasNameTag := getNameTag(routeTable.Tags)
+			baseName := config.baseName(currentName, *routeTable.RouteTableId)
+			association := naming.RouteTableAssociation(routeTable)
+			state := getRouteTableState(association)
+			needsTagging := config.PreviewNames || !hasNameTag || currentName == "" || (isGeneratedName(baseName, "route-table", routeTable.Tags) && !naming.GeneratedNameMatchesState(baseName, "route-table", state, association)) || config.NamePolicy.violates("route-table", currentName)
+			if !needsTagging {
+				continue
 			}
+
+			source := "subnet " + association
+			if association == "main" || association == "unassociated" {
+				source = "VPC " + aws.ToString(routeTable.VpcId)
+			}
+
+			needName++
+			routeTables = config.collect(routeTables, &ResourceInfo{
+				ID:            *routeTable.RouteTableId,
+				Type:          "route-table",
+				Name:          currentName,
+				EmptyName:     hasNameTag && currentName == "",
+				Tags:          tagMap(routeTable.Tags),
+				SuggestedName: naming.RouteTableName(aws.ToString(routeTable.VpcId), association),
+				State:         state,
+				Extra:         association,
+				Source:        source,
+			})
 		}
 	}
 
-	return amiNames, nil
+	debugf("DescribeRouteTables: %d pages, %d route tables scanned, %d need tagging", pages, scanned, needName)
+	config.Stats.recordScan("DescribeRouteTables", "route-table", pages, scanned, needName)
+	logEvent(slog.LevelInfo, "scan finished", "type", "route-table", "scanned", scanned, "need_name", needName)
+
+	return routeTables, nil
 }
 
-// getInstanceNames fetches instance names for the given instance IDs
-func getInstanceNames(ctx context.Context, config *Config, instanceIDs map[string]bool) (map[string]string, error) {
-	if len(instanceIDs) == 0 {
-		return make(map[string]string), nil
+// getRouteTableState summarizes a route table association as "main", "associated", or "unassociated"
+func getRouteTableState(association string) string {
+	if association == "main" || association == "unassociated" {
+		return association
 	}
+	return "associated"
+}
 
-	// Convert map keys to slice
-	var instanceIDSlice []string
-	for instanceID := range instanceIDs {
-		instanceIDSlice = append(instanceIDSlice, instanceID)
+// tagMap converts EC2 tags to a key/value map
+func tagMap(tags []types.Tag) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		if tag.Key != nil {
+			m[*tag.Key] = aws.ToString(tag.Value)
+		}
 	}
+	return m
+}
 
-	// Describe instances in batches (AWS limit is 1000 per request)
-	instanceNames := make(map[string]string)
-	batchSize := 1000
+// getNameTag returns the Name tag value and whether the tag exists, even with an empty value
+func getNameTag(tags []types.Tag) (string, bool) {
+	for _, tag := range tags {
+		if tag.Key != nil && *tag.Key == "Name" {
+			return aws.ToString(tag.Value), true
+		}
+	}
+	return "", false
+}
 
-	for i := 0; i < len(instanceIDSlice); i += batchSize {
-		end := min(i+batchSize, len(instanceIDSlice))
-		batch := instanceIDSlice[i:end]
+// currentNameLabel returns the current name for display, distinguishing a missing Name
+// tag ("untagged") from one with an empty value ("empty")
+func currentNameLabel(resource *ResourceInfo) string {
+	switch {
+	case resource.EmptyName:
+		return "empty"
+	case resource.Name == "":
+		return "untagged"
+	}
+	return resource.Name
+}
 
-		output, err := config.EC2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
-			InstanceIds: batch,
-		})
-		if err != nil {
-			return nil, err
-		}
+// nameSourceNote returns a " [from ...]" note saying where a suggested name came from, or ""
+// when the scan didn't record a source
+func nameSourceNote(resource *ResourceInfo) string {
+	if resource.Source == "" {
+		return ""
+	}
+	return " " + color("[from "+resource.Source+"]", qc.ColorBlue)
+}
 
-		for _, reservation := range output.Reservations {
-			for _, instance := range reservation.Instances {
-				if instance.InstanceId != nil {
-					// Look for Name tag
-					for _, tag := range instance.Tags {
-						if tag.Key != nil && *tag.Key == "Name" && tag.Value != nil {
-							instanceNames[*instance.InstanceId] = *tag.Value
-							break
-						}
-					}
-					// If no Name tag found, use instance ID
-					if _, exists := instanceNames[*instance.InstanceId]; !exists {
-						instanceNames[*instance.InstanceId] = *instance.InstanceId
-					}
-				}
-			}
+// colorCurrentName colors the current name label: yellow when there is no name, red otherwise
+func colorCurrentName(resource *ResourceInfo) string {
+	if resource.Name == "" {
+		return color(currentNameLabel(resource), qc.ColorYellow)
+	}
+	return color(resource.Name, qc.ColorRed)
+}
+
+// accessDeniedCodes are AWS error codes returned when the caller lacks an IAM permission
+var accessDeniedCodes = map[string]bool{
+	"UnauthorizedOperation": true,
+	"AccessDenied":          true,
+	"AccessDeniedException": true,
+	"UnauthorizedAccess":    true,
+}
+
+// throttlingCodes are AWS error codes returned when requests are being rate limited
+var throttlingCodes = map[string]bool{
+	"Throttling":                true,
+	"ThrottlingException":       true,
+	"RequestLimitExceeded":      true,
+	"TooManyRequestsException":  true,
+	"RequestThrottled":          true,
+	"RequestThrottledException": true,
+}
+
+// awsErrorKind is the broad category of an AWS API error
+type awsErrorKind int
+
+const (
+	awsErrorOther        awsErrorKind = iota
+	awsErrorNotFound                  // The resource doesn't exist, e.g. InvalidInstanceID.NotFound
+	awsErrorThrottling                // Rate limited; retrying later may succeed
+	awsErrorAccessDenied              // The caller lacks an IAM permission
+	awsErrorTimeout                   // No response within --call-timeout, even after retrying
+)
+
+// classifyAWSError categorizes an error by its AWS error code rather than its message text
+func classifyAWSError(err error) awsErrorKind {
+	if errors.Is(err, errCallTimeout) {
+		return awsErrorTimeout
+	}
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return awsErrorOther
+	}
+	code := apiErr.ErrorCode()
+	switch {
+	case accessDeniedCodes[code]:
+		return awsErrorAccessDenied
+	case throttlingCodes[code]:
+		return awsErrorThrottling
+	case strings.HasSuffix(code, ".NotFound") || code == "NotFound" || code == "ResourceNotFoundException" || code == "NotFoundException":
+		return awsErrorNotFound
+	}
+	return awsErrorOther
+}
+
+// wrapPermissionError turns access-denied API errors into an actionable message
+// naming the missing IAM action; other errors are returned unchanged
+func wrapPermissionError(err error, action, resourceType string) error {
+	var apiErr smithy.APIError
+	if classifyAWSError(err) == awsErrorAccessDenied && errors.As(err, &apiErr) {
+		return fmt.Errorf("missing permission %s for %s - ask your admin to grant it (%s)", action, resourceType, apiErr.ErrorCode())
+	}
+	return err
+}
+
+// getAMINames fetches AMI names for the given AMI IDs
+func getAMINames(ctx context.Context, config *Config, amiIDs map[string]bool) (map[string]string, error) {
+	images, err := config.Describe.describeImages(ctx, mapKeys(amiIDs))
+	if err != nil {
+		return nil, wrapPermissionError(err, "ec2:DescribeImages", "AMIs")
+	}
+
+	amiNames := make(map[string]string)
+	for amiID, image := range images {
+		if image.Name != nil {
+			amiNames[amiID] = *image.Name
 		}
 	}
 
-	return instanceNames, nil
+	return amiNames, nil
 }
 
-// getVolumeMountPoint extracts the mount point from volume attachments
-func getVolumeMountPoint(volume types.Volume) string {
-	if len(volume.Attachments) == 0 {
-		return "unattached"
+// getInstanceNames fetches instance names for the given instance IDs
+func getInstanceNames(ctx context.Context, config *Config, instanceIDs map[string]bool) (map[string]string, error) {
+	instances, err := config.Describe.describeInstances(ctx, mapKeys(instanceIDs))
+	if err != nil {
+		return nil, wrapPermissionError(err, "ec2:DescribeInstances", "EC2 instances")
 	}
 
-	attachment := volume.Attachments[0]
-	if attachment.Device != nil {
-		return *attachment.Device
+	instanceNames := make(map[string]string)
+	for instanceID, instance := range instances {
+		// Look for Name tag
+		for _, tag := range instance.Tags {
+			if tag.Key != nil && *tag.Key == "Name" && tag.Value != nil {
+				instanceNames[instanceID] = *tag.Value
+				break
+			}
+		}
+		// If no Name tag found, use instance ID
+		if _, exists := instanceNames[instanceID]; !exists {
+			instanceNames[instanceID] = instanceID
+		}
 	}
 
-	return "unknown"
+	return instanceNames, nil
 }
 
-// getVolumeInstanceID gets the instance ID for a volume
-func getVolumeInstanceID(volumeID string, ec2Client *ec2.Client, ctx context.Context) string {
-	output, err := ec2Client.DescribeVolumes(ctx, &ec2.DescribeVolumesInput{
-		VolumeIds: []string{volumeID},
-	})
-	if err != nil || len(output.Volumes) == 0 {
-		return ""
+// mapKeys returns the keys of an ID set as a slice
+func mapKeys(ids map[string]bool) []string {
+	keys := make([]string, 0, len(ids))
+	for id := range ids {
+		keys = append(keys, id)
 	}
+	return keys
+}
 
-	volume := output.Volumes[0]
+// getVolumeInstanceID returns the ID of the instance a volume is attached to, if any
+func getVolumeInstanceID(volume types.Volume) string {
 	if len(volume.Attachments) == 0 {
 		return ""
 	}
@@ -553,126 +2627,150 @@ func getVolumeInstanceID(volumeID string, ec2Client *ec2.Client, ctx context.Con
 	return ""
 }
 
-// extractELBName extracts the load balancer name from ENI description
-func extractELBName(description string) string {
-	// ELB descriptions typically look like: "ELB app/canvas-lb-sbx/35b9ec36d721abfe"
-	// or "ELB net/my-lb/1234567890abcdef"
-	// We want to extract the load balancer name (canvas-lb-sbx or my-lb)
+// getAttachmentNames fetches names for attached resources (instances, etc.)
+func getAttachmentNames(ctx context.Context, config *Config, attachmentIDs map[string]bool) (map[string]string, error) {
+	return getInstanceNames(ctx, config, attachmentIDs)
+}
 
-	// Split by spaces and look for the ELB pattern
-	parts := strings.Fields(description)
-	for i, part := range parts {
-		if strings.ToLower(part) == "elb" && i+1 < len(parts) {
-			// The next part should be the ELB ARN or name
-			elbPart := parts[i+1]
-			// Split by '/' to get the name part
-			elbParts := strings.Split(elbPart, "/")
-			if len(elbParts) >= 2 {
-				// Return the load balancer name (second part after the type)
-				return elbParts[1]
-			}
-		}
+// Helper functions
+
+// color wraps a string with the specified color code
+func color(text, colorCode string) string {
+	if noColor {
+		return text
 	}
-	return ""
+	return qc.Color(text, colorCode)
 }
 
-// getENIAttachmentInfo extracts attachment information from ENI
-func getENIAttachmentInfo(eni types.NetworkInterface) string {
-	if eni.Attachment == nil {
-		return "unattached"
-	}
-
-	// Handle EC2 instance attachments
-	if eni.Attachment.InstanceId != nil {
-		return fmt.Sprintf("attached-to-%s", *eni.Attachment.InstanceId)
-	}
-
-	// Handle service attachments (NAT, RDS, ElastiCache, ELB, Lambda, etc.)
-	if eni.Attachment.AttachmentId != nil {
-		// Try to determine the attachment type from the description
-		attachmentType := "service"
-		if eni.Description != nil {
-			desc := strings.ToLower(*eni.Description)
-			if strings.Contains(desc, "lambda") {
-				attachmentType = "lambda"
-			} else if strings.Contains(desc, "rds") {
-				attachmentType = "rds"
-			} else if strings.Contains(desc, "elasticache") || strings.Contains(desc, "cache") {
-				attachmentType = "elasticache"
-			} else if strings.Contains(desc, "elb") || strings.Contains(desc, "load balancer") {
-				attachmentType = "elb"
-				// For ELB, try to extract the load balancer name
-				if elbName := extractELBName(*eni.Description); elbName != "" {
-					return fmt.Sprintf("attached-elb-%s", elbName)
-				}
-			} else if strings.Contains(desc, "nat") {
-				attachmentType = "nat"
-			}
-		}
-		return fmt.Sprintf("attached-%s-%s", attachmentType, *eni.Attachment.AttachmentId)
+// shouldDisableColor reports whether colors should be off, honoring --no-color,
+// non-terminal output, and the NO_COLOR convention (https://no-color.org)
+func shouldDisableColor(noColorFlag, isTTY bool) bool {
+	return noColorFlag || !isTTY || os.Getenv("NO_COLOR") != ""
+}
+
+// infof prints progress output that --quiet suppresses; errors and summaries use fmt directly
+func infof(format string, args ...any) {
+	if !quiet {
+		fmt.Printf(format, args...)
 	}
+}
 
-	return "attached-unknown"
+// warnf prints a warning to stderr, even with --quiet
+func warnf(format string, args ...any) {
+	if logger != nil {
+		logger.Warn(fmt.Sprintf(format, args...))
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s %s\n", color("⚠️", qc.ColorYellow), fmt.Sprintf(format, args...))
 }
 
-// getAttachmentNames fetches names for attached resources (instances, etc.)
-func getAttachmentNames(ctx context.Context, config *Config, attachmentIDs map[string]bool) (map[string]string, error) {
-	if len(attachmentIDs) == 0 {
-		return make(map[string]string), nil
+// debugf logs a debug message to stderr when verbose mode is enabled
+func debugf(format string, args ...any) {
+	if !verbose {
+		return
+	}
+	if logger != nil {
+		logger.Debug(fmt.Sprintf(format, args...))
+		return
 	}
+	log.Printf("[verbose] "+format, args...)
+}
 
-	// Convert map keys to slice
-	var attachmentIDSlice []string
-	for attachmentID := range attachmentIDs {
-		attachmentIDSlice = append(attachmentIDSlice, attachmentID)
+// logEvent records an operational event (scan counts, tagging outcomes) for --log-format json;
+// the text output reports these through the interactive UI instead
+func logEvent(level slog.Level, msg string, args ...any) {
+	if logger != nil {
+		logger.Log(context.Background(), level, msg, args...)
 	}
+}
 
-	instanceNames := make(map[string]string)
-	batchSize := 1000
+// validLogFormats lists the supported --log-format values
+var validLogFormats = []string{"text", "json"}
+
+// newJSONLogger creates the --log-format json logger, including debug records with --verbose
+func newJSONLogger(w io.Writer, verbose bool) *slog.Logger {
+	level := slog.LevelInfo
+	if verbose {
+		level = slog.LevelDebug
+	}
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level}))
+}
 
-	for i := 0; i < len(attachmentIDSlice); i += batchSize {
-		end := min(i+batchSize, len(attachmentIDSlice))
-		batch := attachmentIDSlice[i:end]
+// readLine reads a line of user input, returning early if ctx is cancelled
+// so Ctrl+C and --timeout still take effect while waiting at a prompt
+func readLine(ctx context.Context, reader *bufio.Reader) (string, error) {
+	if !stdinIsTTY {
+		return "", errStdinNotTerminal
+	}
+	if promptAbandoned.Load() {
+		return "", errPromptTimeout
+	}
 
-		output, err := config.EC2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
-			InstanceIds: batch,
-		})
-		if err != nil {
-			return nil, err
-		}
+	type result struct {
+		line string
+		err  error
+	}
 
-		for _, reservation := range output.Reservations {
-			for _, instance := range reservation.Instances {
-				if instance.InstanceId != nil {
-					// Look for Name tag
-					for _, tag := range instance.Tags {
-						if tag.Key != nil && *tag.Key == "Name" && tag.Value != nil {
-							instanceNames[*instance.InstanceId] = *tag.Value
-							break
-						}
-					}
-					// If no Name tag found, use instance ID
-					if _, exists := instanceNames[*instance.InstanceId]; !exists {
-						instanceNames[*instance.InstanceId] = *instance.InstanceId
-					}
-				}
-			}
-		}
+	ch := make(chan result, 1)
+	go func() {
+		line, err := reader.ReadString('\n')
+		ch <- result{line, err}
+	}()
+
+	var expired <-chan time.Time
+	if promptTimeout > 0 {
+		timer := time.NewTimer(promptTimeout)
+		defer timer.Stop()
+		expired = timer.C
 	}
 
-	return instanceNames, nil
+	select {
+	case <-ctx.Done():
+		promptAbandoned.Store(true)
+		fmt.Println()
+		return "", ctx.Err()
+	case <-expired:
+		promptAbandoned.Store(true)
+		fmt.Println()
+		return "", errPromptTimeout
+	case res := <-ch:
+		return res.line, res.err
+	}
 }
 
-// Helper functions
-
-// color wraps a string with the specified color code
-func color(text, colorCode string) string { return qc.Color(text, colorCode) }
+// promptAbandoned is set once a prompt stops waiting with its read of stdin still pending.
+// That read would swallow the next line typed and race any new one, so later prompts don't
+// read at all and get errPromptTimeout, which callers already treat as the safe choice.
+var promptAbandoned atomic.Bool
+
+// errStdinNotTerminal is returned instead of waiting at a prompt nobody can answer
+var errStdinNotTerminal = errors.New("stdin is not a terminal, so quick-tag can't prompt; use --yes to tag without prompting or --output table to only list")
+
+// errPromptTimeout is returned when a prompt gets no answer within --prompt-timeout;
+// callers treat it as the safe choice (select nothing, cancel)
+var errPromptTimeout = errors.New("no answer within --prompt-timeout")
+
+// fatal exits non-zero, giving a clear message when the run was interrupted or timed out
+func fatal(ctx context.Context, err error) {
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		log.Fatalf("Timed out before completing: %v", err)
+	case context.Canceled:
+		log.Fatalf("Interrupted, stopping: %v", err)
+	}
+	log.Fatal(err)
+}
 
 // Progress indicator functions
 
+// spinnerEnabled reports whether progress spinners should be drawn
+func spinnerEnabled() bool {
+	return stdoutIsTTY && !quiet && len(spinner.Frames) > 0
+}
+
 // showProgress runs a throbber animation while executing a function
 func showProgress(message string, fn func() error) error {
-	_, err := qc.WithProgress(os.Stdout, message, 100*time.Millisecond, func() (struct{}, error) {
+	_, err := showProgressWithResult(message, func() (struct{}, error) {
 		return struct{}{}, fn()
 	})
 	return err
@@ -680,18 +2778,34 @@ func showProgress(message string, fn func() error) error {
 
 // showProgressWithResult runs a throbber animation while executing a function that returns a result
 func showProgressWithResult[T any](message string, fn func() (T, error)) (T, error) {
-	return qc.WithProgress(os.Stdout, message, 100*time.Millisecond, fn)
+	stop := startThrobber(message)
+	defer stop()
+	return fn()
 }
 
 // startThrobber provides a simple spinner wrapper for tests expecting this symbol.
 func startThrobber(message string) (stop func()) {
-	sp := qc.NewSpinner(os.Stdout, message, 100*time.Millisecond)
-	sp.Start()
-	return func() { sp.Stop() }
+	_, stop = startProgress(message)
+	return stop
+}
+
+// startProgress starts a spinner whose message can be updated while it runs
+func startProgress(message string) (update func(string), stop func()) {
+	if !spinnerEnabled() {
+		return func(string) {}, func() {}
+	}
+	t := newThrobber(os.Stdout, message, spinner)
+	t.Start()
+	return t.SetMessage, t.Stop
 }
 
 // colorBold wraps a string with color and bold codes (compat for tests)
-func colorBold(text, colorCode string) string { return qc.ColorizeBold(text, colorCode) }
+func colorBold(text, colorCode string) string {
+	if noColor {
+		return text
+	}
+	return qc.ColorizeBold(text, colorCode)
+}
 
 // colorResourceState returns a qc color for a given resource state (compat for tests)
 func colorResourceState(state string) string {
@@ -738,13 +2852,106 @@ func resolveVersion() string {
 	return fmt.Sprintf("v%d.%d.%s", versionpkg.Major, versionpkg.Minor, "unknown")
 }
 
+// versionInfo returns the --version output: the version, git commit, and build date on separate lines
+func versionInfo() string {
+	commit, buildDate := versionpkg.Commit, versionpkg.BuildDate
+	// Fall back to the VCS details the go tool stamps into builds from a checkout
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && commit == "":
+				commit = setting.Value
+			case setting.Key == "vcs.time" && buildDate == "":
+				buildDate = setting.Value
+			}
+		}
+	}
+	if commit == "" {
+		commit = "unknown"
+	}
+	if buildDate == "" {
+		buildDate = "unknown"
+	}
+	return fmt.Sprintf("quick-tag %s\ncommit: %s\nbuilt: %s", resolveVersion(), commit, buildDate)
+}
+
+// fallbackRegion is used when neither --region nor the AWS config sets a region
+const fallbackRegion = "us-east-1"
+
+// loadAWSConfig loads the shared AWS config, using the named profile when set. An explicit region wins; otherwise the
+// region from the environment or profile is used, falling back to fallbackRegion.
+func loadAWSConfig(ctx context.Context, region, profile string) (aws.Config, error) {
+	var optFns []func(*config.LoadOptions) error
+	if region != "" {
+		optFns = append(optFns, config.WithRegion(region))
+	}
+	if profile != "" {
+		optFns = append(optFns, config.WithSharedConfigProfile(profile))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, optFns...)
+	if err != nil {
+		return cfg, err
+	}
+	if cfg.Region == "" {
+		cfg.Region = fallbackRegion
+	}
+	return cfg, nil
+}
+
+// resolveEndpointURL returns the custom AWS endpoint from the flag, falling back to AWS_ENDPOINT_URL
+func resolveEndpointURL(flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	return os.Getenv("AWS_ENDPOINT_URL")
+}
+
+// newEC2Client creates an EC2 client, pointing it at a custom endpoint if one is set
+func newEC2Client(cfg aws.Config, endpointURL string) *ec2.Client {
+	return ec2.NewFromConfig(cfg, func(o *ec2.Options) {
+		if endpointURL != "" {
+			debugf("EC2: using endpoint %s", endpointURL)
+			o.BaseEndpoint = stringPtr(endpointURL)
+		}
+	})
+}
+
+// newTaggingClient creates a Resource Groups Tagging API client, honoring a custom endpoint URL
+func newTaggingClient(cfg aws.Config, endpointURL string) *resourcegroupstaggingapi.Client {
+	return resourcegroupstaggingapi.NewFromConfig(cfg, func(o *resourcegroupstaggingapi.Options) {
+		if endpointURL != "" {
+			debugf("Resource Groups Tagging API: using endpoint %s", endpointURL)
+			o.BaseEndpoint = stringPtr(endpointURL)
+		}
+	})
+}
+
+// newSTSClient creates an STS client, pointing it at a custom endpoint if one is set
+func newSTSClient(cfg aws.Config, endpointURL string) *sts.Client {
+	return sts.NewFromConfig(cfg, func(o *sts.Options) {
+		if endpointURL != "" {
+			debugf("STS: using endpoint %s", endpointURL)
+			o.BaseEndpoint = stringPtr(endpointURL)
+		}
+	})
+}
+
 // stringPtr returns a pointer to a string value
 func stringPtr(s string) *string {
 	return &s
 }
 
-// getHistoryFilePath returns the path to the history file
-func getHistoryFilePath() string {
+// getHistoryFilePath returns the path to the history file. An explicit override
+// (from --history-file) wins, then the QUICK_TAG_HISTORY environment variable,
+// then ~/.quick-tag.yml.
+func getHistoryFilePath(override string) string {
+	if override != "" {
+		return override
+	}
+	if envPath := os.Getenv("QUICK_TAG_HISTORY"); envPath != "" {
+		return envPath
+	}
+
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return ""
@@ -752,9 +2959,40 @@ func getHistoryFilePath() string {
 	return filepath.Join(home, ".quick-tag.yml")
 }
 
+// getHistoryLockPath returns the path to the lock file guarding the history file
+func getHistoryLockPath(historyPath string) string {
+	if historyPath == "" {
+		return ""
+	}
+	return strings.TrimSuffix(historyPath, filepath.Ext(historyPath)) + ".lock"
+}
+
+// lockHistory serializes history updates across concurrent quick_tag runs.
+// The returned function releases the lock.
+func lockHistory(historyPath string) (func(), error) {
+	lockPath := getHistoryLockPath(historyPath)
+	if lockPath == "" {
+		return nil, fmt.Errorf("unable to determine home directory")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0755); err != nil {
+		return nil, err
+	}
+
+	release, err := acquireFileLock(lockPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock history file: %v", err)
+	}
+
+	return func() {
+		if err := release(); err != nil {
+			fmt.Printf("Warning: Failed to release history lock: %v\n", err)
+		}
+	}, nil
+}
+
 // loadHistory loads the tagging history from the YAML file
-func loadHistory() (*TagHistory, error) {
-	historyPath := getHistoryFilePath()
+func loadHistory(historyPath string) (*TagHistory, error) {
 	if historyPath == "" {
 		return &TagHistory{Actions: []TagHistoryEntry{}}, nil
 	}
@@ -773,19 +3011,26 @@ func loadHistory() (*TagHistory, error) {
 		return nil, err
 	}
 
-	// Note: The Undone field will be false by default due to Go's zero value for bool
-	// This ensures backward compatibility with existing history files that don't have this field
+	// Older files are upgraded in memory; they're written in the current version the next
+	// time a run saves history
+	migrated, err := migrateHistory(&history)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", historyPath, err)
+	}
+	if migrated {
+		debugf("History: read %s in an older version, upgraded to version %d", historyPath, historyVersion)
+	}
 
 	return &history, nil
 }
 
 // saveHistory saves the tagging history to the YAML file
-func saveHistory(history *TagHistory) error {
-	historyPath := getHistoryFilePath()
+func saveHistory(historyPath string, history *TagHistory) error {
 	if historyPath == "" {
 		return fmt.Errorf("unable to determine home directory")
 	}
 
+	history.Version = historyVersion
 	data, err := yaml.Marshal(history)
 	if err != nil {
 		return err
@@ -797,7 +3042,83 @@ func saveHistory(history *TagHistory) error {
 		return err
 	}
 
-	return os.WriteFile(historyPath, data, 0644)
+	return writeFileAtomic(historyPath, data, 0644)
+}
+
+// writeFileAtomic writes data to a temp file in the same directory and renames it
+// into place, so readers never observe a partially written file
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	return streamFileAtomic(path, perm, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// streamFileAtomic is writeFileAtomic for output too big to hold in memory: write streams
+// it into the temp file, which is only renamed into place if write succeeds
+func streamFileAtomic(path string, perm os.FileMode, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	// Clean up the temp file on any failure before the rename
+	success := false
+	defer func() {
+		if !success {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if err := write(tmp); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+
+	success = true
+	return nil
+}
+
+// resumedResources returns the resources already tagged in runID for an account (--resume),
+// so restarting an interrupted run skips them. Undone actions don't count.
+func resumedResources(historyPath, runID, accountID string) (map[string]bool, error) {
+	history, err := loadHistory(historyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load history: %v", err)
+	}
+	done := make(map[string]bool)
+	for _, action := range history.Actions {
+		if action.RunID == runID && action.Account == accountID && !action.Undone {
+			done[action.Resource] = true
+		}
+	}
+	if len(done) == 0 {
+		return nil, fmt.Errorf("no tagging history for run %s in account %s; see --history for run IDs", runID, accountID)
+	}
+	return done, nil
+}
+
+// skipResumed drops resources already tagged in the run being resumed
+func skipResumed(resources []*ResourceInfo, done map[string]bool) []*ResourceInfo {
+	if len(done) == 0 {
+		return resources
+	}
+	return slices.DeleteFunc(resources, func(resource *ResourceInfo) bool {
+		return done[resource.ID]
+	})
 }
 
 // generateRunID creates a unique identifier for this execution run
@@ -810,91 +3131,412 @@ func generateRunID() string {
 	return fmt.Sprintf("run-%s", hex.EncodeToString(bytes))
 }
 
-// addToHistory adds a new tagging action to the history
-func addToHistory(account, resource, oldValue, newValue, runID string) error {
-	history, err := loadHistory()
+// addToHistory adds a new tagging action to the history, stamping it with the current time
+func addToHistory(historyPath string, maxRuns int, entry TagHistoryEntry) error {
+	unlock, err := lockHistory(historyPath)
 	if err != nil {
 		return err
 	}
+	defer unlock()
 
-	entry := TagHistoryEntry{
-		Account:   account,
-		Resource:  resource,
-		OldValue:  oldValue,
-		NewValue:  newValue,
-		Timestamp: time.Now().Format(time.RFC3339),
-		RunID:     runID,
-		Undone:    false,
+	history, err := loadHistory(historyPath)
+	if err != nil {
+		return err
 	}
 
+	entry.Timestamp = time.Now().Format(time.RFC3339)
+	entry.Undone = false
 	history.Actions = append(history.Actions, entry)
-	return saveHistory(history)
+	history.Prune(maxRuns)
+	return saveHistory(historyPath, history)
 }
 
-// undoLastRun finds the last run that hasn't been undone and reverts all its actions
-func undoLastRun() error {
-	history, err := loadHistory()
+// isHistoryEntryAfter reports whether an entry's timestamp is after the cutoff.
+// A zero cutoff matches everything; unparseable timestamps never match a cutoff.
+func isHistoryEntryAfter(entry TagHistoryEntry, cutoff time.Time) bool {
+	if cutoff.IsZero() {
+		return true
+	}
+	timestamp, err := time.Parse(time.RFC3339, entry.Timestamp)
+	if err != nil {
+		return false
+	}
+	return timestamp.After(cutoff)
+}
+
+// filterHistorySince returns the entries recorded after the cutoff
+func filterHistorySince(actions []TagHistoryEntry, cutoff time.Time) []TagHistoryEntry {
+	var filtered []TagHistoryEntry
+	for _, action := range actions {
+		if isHistoryEntryAfter(action, cutoff) {
+			filtered = append(filtered, action)
+		}
+	}
+	return filtered
+}
+
+// listHistory prints history entries, optionally only those from within since
+func listHistory(w io.Writer, historyPath string, since time.Duration) error {
+	history, err := loadHistory(historyPath)
 	if err != nil {
 		return fmt.Errorf("failed to load history: %v", err)
 	}
 
-	if len(history.Actions) == 0 {
-		return fmt.Errorf("no tagging history found")
+	var cutoff time.Time
+	if since > 0 {
+		cutoff = time.Now().Add(-since)
+	}
+	actions := filterHistorySince(history.Actions, cutoff)
+
+	if len(actions) == 0 {
+		fmt.Fprintln(w, "No tagging history found.")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Timestamp\tRun\tAccount\tRegion\tResource\tChange\tUndone\tBy")
+	for _, action := range actions {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t'%s' -> '%s'\t%t\t%s\n",
+			action.Timestamp, action.RunID, action.Account, action.Region,
+			action.Resource, action.OldValue, action.NewValue, action.Undone, historyActor(action))
+	}
+	return tw.Flush()
+}
+
+// historyActor describes who made a history entry, e.g. "alice (arn:aws:iam::123456789012:user/alice)",
+// or "-" for entries recorded before this was tracked
+func historyActor(action TagHistoryEntry) string {
+	switch {
+	case action.User != "" && action.Principal != "":
+		return fmt.Sprintf("%s (%s)", action.User, action.Principal)
+	case action.User != "":
+		return action.User
+	case action.Principal != "":
+		return action.Principal
+	}
+	return "-"
+}
+
+// localUsername returns the OS username running quick-tag, or "" if it can't be determined
+func localUsername() string {
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
+
+// pruneHistory trims the history file to at most maxRuns runs
+func pruneHistory(historyPath string, maxRuns int) (int, error) {
+	unlock, err := lockHistory(historyPath)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	history, err := loadHistory(historyPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load history: %v", err)
+	}
+
+	removed := history.Prune(maxRuns)
+	if removed == 0 {
+		return 0, nil
+	}
+
+	if err := saveHistory(historyPath, history); err != nil {
+		return 0, fmt.Errorf("failed to save pruned history: %v", err)
+	}
+	return removed, nil
+}
+
+// importHistory merges the actions in importPath, another quick-tag history file, into the
+// history file and returns how many were new
+func importHistory(historyPath, importPath string) (int, error) {
+	data, err := os.ReadFile(importPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read history to import: %v", err)
+	}
+	var imported TagHistory
+	if err := yaml.Unmarshal(data, &imported); err != nil {
+		return 0, fmt.Errorf("failed to parse history to import: %v", err)
+	}
+	// Bring older files up to date and refuse newer ones, whose unknown fields the merge would drop
+	if _, err := migrateHistory(&imported); err != nil {
+		return 0, fmt.Errorf("%s: %v", importPath, err)
+	}
+
+	unlock, err := lockHistory(historyPath)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	history, err := loadHistory(historyPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load history: %v", err)
+	}
+
+	added := history.Merge(&imported)
+	if added == 0 {
+		return 0, nil
+	}
+	if err := saveHistory(historyPath, history); err != nil {
+		return 0, fmt.Errorf("failed to save merged history: %v", err)
+	}
+	return added, nil
+}
+
+// printUndoPreview lists what undoing a run will change, with the value being removed in red
+// and the value restored in green
+func printUndoPreview(w io.Writer, runID string, actions []TagHistoryEntry) {
+	fmt.Fprintf(w, "🔄 Undoing run %s (%d actions):\n", runID, len(actions))
+	resources := make([]string, len(actions))
+	longestID := 0
+	for i, action := range actions {
+		resources[i] = action.Resource
+		if action.Region != "" {
+			resources[i] = fmt.Sprintf("%s (%s)", action.Resource, action.Region)
+		}
+		longestID = max(longestID, len(resources[i]))
+	}
+	for i, action := range actions {
+		fmt.Fprintf(w, "  %-*s %s -> %s\n", longestID+1, resources[i]+":",
+			color(fmt.Sprintf("'%s'", action.NewValue), qc.ColorRed), color(fmt.Sprintf("'%s'", action.OldValue), qc.ColorGreen))
+		restore, remove := revertExtraTags(action)
+		if len(restore) > 0 {
+			var pairs []string
+			for _, tag := range restore {
+				pairs = append(pairs, aws.ToString(tag.Key)+"="+aws.ToString(tag.Value))
+			}
+			fmt.Fprintf(w, "    and restore tags: %s\n", strings.Join(pairs, ", "))
+		}
+		if len(remove) > 0 {
+			fmt.Fprintf(w, "    and remove tags: %s\n", strings.Join(remove, ", "))
+		}
+	}
+}
+
+// priorExtraTags returns the values a resource already had for --extra-tag keys, which
+// tagging is about to overwrite, or nil when it had none of them
+func priorExtraTags(current map[string]string, extra []types.Tag) map[string]string {
+	var prior map[string]string
+	for _, tag := range extra {
+		value, ok := current[aws.ToString(tag.Key)]
+		if !ok {
+			continue
+		}
+		if prior == nil {
+			prior = make(map[string]string)
+		}
+		prior[aws.ToString(tag.Key)] = value
+	}
+	return prior
+}
+
+// revertExtraTags splits an action's --extra-tag keys into the tags to set back to their
+// values from before the run and the keys the run added, which undo removes
+func revertExtraTags(action TagHistoryEntry) (restore []types.Tag, remove []string) {
+	for _, key := range action.ExtraTags {
+		if value, ok := action.PriorTags[key]; ok {
+			restore = append(restore, types.Tag{Key: stringPtr(key), Value: stringPtr(value)})
+			continue
+		}
+		remove = append(remove, key)
+	}
+	return restore, remove
+}
+
+// revertTags returns what undoing an action does to a resource's tags, the same for every
+// resource type: the tags to set, the old Name and overwritten --extra-tag values, and the
+// keys to remove, Name when the resource had none and --extra-tag keys the run added
+func revertTags(action TagHistoryEntry) (restore []types.Tag, remove []string) {
+	restore, remove = revertExtraTags(action)
+	if action.OldValue == "" {
+		return restore, append([]string{"Name"}, remove...)
+	}
+	return append([]types.Tag{{Key: stringPtr("Name"), Value: stringPtr(action.OldValue)}}, restore...), remove
+}
+
+// revertEC2Resource undoes one history action on an EC2 resource, as revertTags describes
+func revertEC2Resource(ctx context.Context, client *ec2.Client, action TagHistoryEntry) error {
+	restore, remove := revertTags(action)
+	if len(restore) > 0 {
+		debugf("CreateTags: %s (%d restored tags)", action.Resource, len(restore))
+		if _, err := client.CreateTags(ctx, &ec2.CreateTagsInput{Resources: []string{action.Resource}, Tags: restore}); err != nil {
+			return wrapPermissionError(err, "ec2:CreateTags", action.Resource)
+		}
 	}
+	if len(remove) == 0 {
+		return nil
+	}
+	keys := make([]types.Tag, len(remove))
+	for i, key := range remove {
+		keys[i] = types.Tag{Key: stringPtr(key)}
+	}
+	debugf("DeleteTags: %s %s", action.Resource, strings.Join(remove, ","))
+	if _, err := client.DeleteTags(ctx, &ec2.DeleteTagsInput{Resources: []string{action.Resource}, Tags: keys}); err != nil {
+		return fmt.Errorf("failed to remove tags: %w", wrapPermissionError(err, "ec2:DeleteTags", action.Resource))
+	}
+	return nil
+}
+
+// selectUndoActions lists a run's actions by number and returns the ones picked
+func selectUndoActions(ctx context.Context, reader *bufio.Reader, actions []TagHistoryEntry) ([]TagHistoryEntry, error) {
+	for i, action := range actions {
+		fmt.Printf("%3d. %s '%s' -> '%s'\n", i+1, action.Resource, action.NewValue, action.OldValue)
+	}
+	fmt.Printf("%s", color("Select actions to undo (comma-separated numbers): ", qc.ColorYellow))
+	input, err := readLine(ctx, reader)
+	if errors.Is(err, errPromptTimeout) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user input: %v", err)
+	}
+
+	var selected []TagHistoryEntry
+	for _, idx := range parseSelection(os.Stdout, strings.TrimSpace(input), len(actions)) {
+		selected = append(selected, actions[idx])
+	}
+	return selected, nil
+}
 
+// findLastRun returns the most recent run for the account that still has actions
+// to undo, along with those actions. A non-zero cutoff only considers runs with
+// actions newer than the cutoff.
+func findLastRun(history *TagHistory, account string, cutoff time.Time) (string, []TagHistoryEntry) {
 	// Find the last run ID that hasn't been undone
 	var lastRunID string
 	for i := len(history.Actions) - 1; i >= 0; i-- {
 		action := history.Actions[i]
-		if !action.Undone {
+		if !action.Undone && action.Account == account && isHistoryEntryAfter(action, cutoff) {
 			lastRunID = action.RunID
 			break
 		}
 	}
 
 	if lastRunID == "" {
-		return fmt.Errorf("no undone runs found")
+		return "", nil
 	}
 
 	// Find all actions for this run
 	var actionsToUndo []TagHistoryEntry
 	for _, action := range history.Actions {
-		if action.RunID == lastRunID && !action.Undone {
+		if action.RunID == lastRunID && action.Account == account && !action.Undone {
 			actionsToUndo = append(actionsToUndo, action)
 		}
 	}
 
-	if len(actionsToUndo) == 0 {
-		return fmt.Errorf("no actions found for run %s", lastRunID)
+	return lastRunID, actionsToUndo
+}
+
+// undoLastRun finds the last run that hasn't been undone and reverts all its actions
+// Entries without a recorded region are reverted in defaultRegion, or the configured region when empty.
+// A non-zero since only considers runs from within that duration. Non-empty resourceIDs only
+// reverts those resources from the run; the rest of the run stays available to --undo.
+func undoLastRun(ctx context.Context, historyPath, endpointURL, defaultRegion, profile string, since time.Duration, force bool, resourceIDs []string) error {
+	history, err := loadHistory(historyPath)
+	if err != nil {
+		return fmt.Errorf("failed to load history: %v", err)
 	}
 
-	// Show what will be undone
-	fmt.Printf("🔄 Undoing run %s (%d actions):\n", lastRunID, len(actionsToUndo))
-	for _, action := range actionsToUndo {
-		fmt.Printf("  %s: '%s' -> '%s'\n", action.Resource, action.NewValue, action.OldValue)
+	if len(history.Actions) == 0 {
+		return fmt.Errorf("no tagging history found")
 	}
 
-	// Ask for confirmation
-	reader := bufio.NewReader(os.Stdin)
-	fmt.Printf("Are you sure you want to undo these changes? (y/N): ")
-	response, err := reader.ReadString('\n')
+	// Initialize AWS clients for undo operations
+	cfg, err := loadAWSConfig(ctx, defaultRegion, profile)
 	if err != nil {
-		return fmt.Errorf("failed to read user input: %v", err)
+		return fmt.Errorf("failed to load AWS config: %v", err)
 	}
+	defaultRegion = cfg.Region
 
-	response = strings.TrimSpace(strings.ToLower(response))
-	if response != "y" && response != "yes" {
-		fmt.Println("Undo cancelled.")
-		return nil
+	// Revert each action in the region it was tagged in, reusing one client per region
+	ec2Clients := make(map[string]*ec2.Client)
+	ec2ClientFor := func(region string) *ec2.Client {
+		if region == "" {
+			region = defaultRegion
+		}
+		if client, exists := ec2Clients[region]; exists {
+			return client
+		}
+		regionCfg := cfg.Copy()
+		regionCfg.Region = region
+		ec2Clients[region] = newEC2Client(regionCfg, endpointURL)
+		return ec2Clients[region]
+	}
+	kmsClients := make(map[string]kmsAPI)
+	kmsClientFor := func(region string) kmsAPI {
+		if region == "" {
+			region = defaultRegion
+		}
+		if client, exists := kmsClients[region]; exists {
+			return client
+		}
+		regionCfg := cfg.Copy()
+		regionCfg.Region = region
+		kmsClients[region] = newKMSClient(regionCfg, endpointURL)
+		return kmsClients[region]
 	}
 
-	// Initialize AWS client for undo operations
-	ctx := context.Background()
-	cfg, err := config.LoadDefaultConfig(ctx)
+	// Only undo runs made against the currently authenticated account
+	callerIdentity, err := newSTSClient(cfg, endpointURL).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
 	if err != nil {
-		return fmt.Errorf("failed to load AWS config: %v", err)
+		return fmt.Errorf("failed to authenticate with aws: %v", err)
+	}
+	account := *callerIdentity.Account
+
+	var cutoff time.Time
+	if since > 0 {
+		cutoff = time.Now().Add(-since)
+	}
+
+	lastRunID, actionsToUndo := findLastRun(history, account, cutoff)
+	if lastRunID == "" {
+		if since > 0 {
+			return fmt.Errorf("no undone runs found for account %s in the last %s", account, since)
+		}
+		return fmt.Errorf("no undone runs found for account %s", account)
+	}
+
+	if len(resourceIDs) > 0 {
+		actionsToUndo = slices.DeleteFunc(actionsToUndo, func(action TagHistoryEntry) bool {
+			return !slices.Contains(resourceIDs, action.Resource)
+		})
+		if len(actionsToUndo) == 0 {
+			return fmt.Errorf("none of %s were tagged in run %s", strings.Join(resourceIDs, ", "), lastRunID)
+		}
+	}
+
+	printUndoPreview(os.Stdout, lastRunID, actionsToUndo)
+
+	// Ask for confirmation unless --force
+	if !force {
+		reader := bufio.NewReader(os.Stdin)
+		fmt.Printf("Are you sure you want to undo these changes? (y/N, or s to pick which): ")
+		response, err := readLine(ctx, reader)
+		if err != nil && !errors.Is(err, errPromptTimeout) {
+			return fmt.Errorf("failed to read user input: %v", err)
+		}
+
+		response = strings.TrimSpace(strings.ToLower(response))
+		if response == "s" {
+			actionsToUndo, err = selectUndoActions(ctx, reader, actionsToUndo)
+			if err != nil {
+				return err
+			}
+			if len(actionsToUndo) == 0 {
+				fmt.Println("Undo cancelled.")
+				return nil
+			}
+		} else if response != "y" && response != "yes" {
+			fmt.Println("Undo cancelled.")
+			return nil
+		}
 	}
-	ec2Client := ec2.NewFromConfig(cfg)
 
 	// Perform the undo operations
 	successCount := 0
@@ -902,38 +3544,22 @@ func undoLastRun() error {
 	errorCount := 0
 
 	for _, action := range actionsToUndo {
+		// Stop before marking anything as undone if interrupted or timed out
+		if ctx.Err() != nil {
+			return fmt.Errorf("undo stopped after %d of %d actions: %v", successCount+notFoundCount+errorCount, len(actionsToUndo), ctx.Err())
+		}
+
 		fmt.Printf("Reverting %s: '%s' -> '%s'...\n", action.Resource, action.NewValue, action.OldValue)
 
-		// Create tags input - if OldValue is empty, we need to delete the Name tag
-		var input *ec2.CreateTagsInput
-		if action.OldValue == "" {
-			// Delete the Name tag by setting it to empty (AWS will remove it)
-			input = &ec2.CreateTagsInput{
-				Resources: []string{action.Resource},
-				Tags: []types.Tag{
-					{
-						Key:   stringPtr("Name"),
-						Value: stringPtr(""),
-					},
-				},
-			}
+		var err error
+		if isKMSKeyID(action.Resource) {
+			err = revertKMSKey(ctx, kmsClientFor(action.Region), action)
 		} else {
-			// Set the tag back to the old value
-			input = &ec2.CreateTagsInput{
-				Resources: []string{action.Resource},
-				Tags: []types.Tag{
-					{
-						Key:   stringPtr("Name"),
-						Value: stringPtr(action.OldValue),
-					},
-				},
-			}
+			err = revertEC2Resource(ctx, ec2ClientFor(action.Region), action)
 		}
-
-		_, err := ec2Client.CreateTags(ctx, input)
 		if err != nil {
 			// Check if the error is because the resource doesn't exist
-			if strings.Contains(err.Error(), "NotFound") || strings.Contains(err.Error(), "does not exist") {
+			if classifyAWSError(err) == awsErrorNotFound {
 				fmt.Printf("Info: Resource %s no longer exists (likely deleted) - skipping\n", action.Resource)
 				notFoundCount++
 			} else {
@@ -943,18 +3569,31 @@ func undoLastRun() error {
 			continue
 		}
 
-		successCount++
+		successCount++
+	}
+
+	// Reload the history under lock so entries added by concurrent runs are kept
+	unlock, err := lockHistory(historyPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	history, err = loadHistory(historyPath)
+	if err != nil {
+		return fmt.Errorf("failed to reload history: %v", err)
 	}
 
-	// Mark all actions in this run as undone
+	// Mark the reverted actions as undone
 	for i := range history.Actions {
-		if history.Actions[i].RunID == lastRunID && !history.Actions[i].Undone {
+		if history.Actions[i].RunID == lastRunID && history.Actions[i].Account == account && !history.Actions[i].Undone &&
+			slices.ContainsFunc(actionsToUndo, func(action TagHistoryEntry) bool { return action.Resource == history.Actions[i].Resource }) {
 			history.Actions[i].Undone = true
 		}
 	}
 
 	// Save the updated history
-	if err := saveHistory(history); err != nil {
+	if err := saveHistory(historyPath, history); err != nil {
 		return fmt.Errorf("failed to save updated history: %v", err)
 	}
 
@@ -979,99 +3618,124 @@ func undoLastRun() error {
 	return nil
 }
 
-// isQuickTagCreatedName checks if a name was created by quick-tag
-func isQuickTagCreatedName(name, resourceType string) bool {
-	switch resourceType {
-	case "instance":
-		// Check for quick-tag created instance names like "instance-ami-12345678"
-		return strings.HasPrefix(name, "instance-ami-") ||
-			strings.HasPrefix(name, "unknown-instance")
-	case "volume":
-		// Check for quick-tag created volume names like "unattached", "unattached-/dev/xvda1"
-		return name == "unattached" ||
-			strings.HasPrefix(name, "unattached-") ||
-			strings.HasPrefix(name, "unknown-")
-	case "eni":
-		// Check for quick-tag created ENI names like "unattached-eni", "service-123-eni"
-		return name == "unattached-eni" ||
-			strings.HasPrefix(name, "service-") && strings.HasSuffix(name, "-eni") ||
-			strings.HasPrefix(name, "attached-") && strings.HasSuffix(name, "-eni") ||
-			strings.HasPrefix(name, "lambda-") && strings.HasSuffix(name, "-eni") ||
-			strings.HasPrefix(name, "rds-") && strings.HasSuffix(name, "-eni") ||
-			strings.HasPrefix(name, "elasticache-") && strings.HasSuffix(name, "-eni") ||
-			strings.HasPrefix(name, "elb-") && strings.HasSuffix(name, "-eni") ||
-			strings.HasPrefix(name, "nat-") && strings.HasSuffix(name, "-eni") ||
-			// Check for service attachment patterns (rds-eni-attach-, elasticache-eni-attach-, etc.)
-			strings.HasPrefix(name, "rds-eni-attach-") ||
-			strings.HasPrefix(name, "elasticache-eni-attach-") ||
-			strings.HasPrefix(name, "elb-eni-attach-") ||
-			strings.HasPrefix(name, "lambda-eni-attach-") ||
-			strings.HasPrefix(name, "nat-eni-attach-") ||
-			strings.HasPrefix(name, "service-eni-attach-") ||
-			// Check for alternative service attachment patterns (nat-ela-attach-, etc.)
-			strings.HasPrefix(name, "rds-ela-attach-") ||
-			strings.HasPrefix(name, "elasticache-ela-attach-") ||
-			strings.HasPrefix(name, "elb-ela-attach-") ||
-			strings.HasPrefix(name, "lambda-ela-attach-") ||
-			strings.HasPrefix(name, "nat-ela-attach-") ||
-			strings.HasPrefix(name, "service-ela-attach-") ||
-			// Check for any service-attach pattern ending in -eni
-			strings.Contains(name, "-attach-") && strings.HasSuffix(name, "-eni") ||
-			// Check for AWS default patterns
-			strings.HasPrefix(name, "eni-") && len(name) >= 21 && len(name) <= 25 || // Just the ENI ID itself (eni- + variable length ID)
-			name == "" || // Empty name
-			strings.HasPrefix(name, "Network interface") || // AWS default description-based names
-			strings.Contains(name, "primary") && strings.Contains(name, "interface") // Primary network interface
+// markerTagKey is the tag written alongside Name by --marker-tag so quick-tag can
+// recognize its own names without relying on name heuristics
+const markerTagKey = "quick_tag:generated"
+
+// hasMarkerTag reports whether the tags include the quick-tag marker
+func hasMarkerTag(tags []types.Tag) bool {
+	for _, tag := range tags {
+		if aws.ToString(tag.Key) == markerTagKey && aws.ToString(tag.Value) == "true" {
+			return true
+		}
 	}
 	return false
 }
 
-// isQuickTagNameStillValid checks if a quick-tag created name is still valid for the current resource state
-func isQuickTagNameStillValid(name, resourceType, currentState, extraInfo string) bool {
-	if !isQuickTagCreatedName(name, resourceType) {
-		return true // Not a quick-tag created name, so it's valid
+// isGeneratedName reports whether a resource's Name was written by quick-tag. The marker
+// tag is authoritative; resources tagged without it fall back to naming.IsQuickTagCreatedName.
+func isGeneratedName(name, resourceType string, tags []types.Tag) bool {
+	return hasMarkerTag(tags) || naming.IsQuickTagCreatedName(name, resourceType)
+}
+
+// printResourceTable prints resources as an aligned, bordered table
+func printResourceTable(w io.Writer, resources []*ResourceInfo) {
+	withRegion := slices.ContainsFunc(resources, func(resource *ResourceInfo) bool { return resource.Region != "" })
+	withCost := hasCostEstimates(resources)
+
+	header := []string{"Type", "ID", "Current", "Suggested", "State"}
+	if withRegion {
+		header = append([]string{"Region"}, header...)
+	}
+	if withCost {
+		header = append(header, "Cost/mo")
 	}
 
-	switch resourceType {
-	case "instance":
-		// For instances, quick-tag names are generally still valid unless the AMI changed
-		// This is a simple check - in practice, you might want to verify the AMI matches
-		return true
-	case "volume":
-		// For volumes, check if the attachment state matches the name
-		if name == "unattached" {
-			// Name says unattached, check if it's actually unattached
-			return currentState == "available" || extraInfo == "unattached"
-		} else if strings.HasPrefix(name, "unattached-") {
-			// Name says unattached with mount point, check if it's actually unattached
-			return currentState == "available" || extraInfo == "unattached"
-		}
-		// For attached volumes, the name should match the current attachment
-		return true // For now, assume attached volume names are still valid
-	case "eni":
-		// For ENIs, check if the attachment state matches the name
-		if name == "unattached-eni" {
-			// Name says unattached, check if it's actually unattached
-			return extraInfo == "unattached"
-		} else if strings.HasPrefix(name, "unattached-") {
-			// Name says unattached, check if it's actually unattached
-			return extraInfo == "unattached"
-		} else if strings.Contains(name, "-eni") {
-			// For attached ENIs, check if the attachment info matches
-			// This is a simplified check - in practice, you might want more detailed validation
-			return extraInfo != "unattached"
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', tabwriter.Debug)
+	writeRow := func(cells []string) {
+		for _, cell := range cells {
+			fmt.Fprintf(tw, " %s\t", cell)
 		}
+		fmt.Fprintln(tw)
 	}
-	return true
+	writeRow(header)
+	separator := make([]string, len(header))
+	for i, column := range header {
+		separator[i] = strings.Repeat("-", len(column))
+	}
+	writeRow(separator)
+
+	for _, resource := range resources {
+		row := []string{resource.Type, resource.ID, currentNameLabel(resource), resource.SuggestedName, resource.State}
+		if withRegion {
+			row = append([]string{resource.Region}, row...)
+		}
+		if withCost {
+			cost := "-"
+			if resource.MonthlyCost != nil {
+				cost = formatDollars(*resource.MonthlyCost)
+			}
+			row = append(row, cost)
+		}
+		writeRow(row)
+	}
+	tw.Flush()
 }
 
-// isGenericName is a compatibility wrapper for older tests.
-func isGenericName(name, resourceType string) bool { return isQuickTagCreatedName(name, resourceType) }
+// printNamePreview prints every scanned resource's current and suggested name for
+// --preview-names, with whether tagging would keep, set, or rename it
+func printNamePreview(w io.Writer, resources []*ResourceInfo) {
+	if len(resources) == 0 {
+		fmt.Fprintln(w, "No resources found.")
+		return
+	}
+	withRegion := slices.ContainsFunc(resources, func(resource *ResourceInfo) bool { return resource.Region != "" })
+
+	header := []string{"Type", "ID", "Current", "Suggested", "Change"}
+	if withRegion {
+		header = append([]string{"Region"}, header...)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', tabwriter.Debug)
+	writeRow := func(cells []string) {
+		for _, cell := range cells {
+			fmt.Fprintf(tw, " %s\t", cell)
+		}
+		fmt.Fprintln(tw)
+	}
+	writeRow(header)
+	separator := make([]string, len(header))
+	for i, column := range header {
+		separator[i] = strings.Repeat("-", len(column))
+	}
+	writeRow(separator)
 
-// selectResources displays resources and allows user to select which ones to tag
-func selectResources(resources []*ResourceInfo) ([]*ResourceInfo, bool) {
-	fmt.Printf("\n%s\n", color("Resources without Name tags:", qc.ColorBlue))
+	kept, set, renamed := 0, 0, 0
+	for _, resource := range resources {
+		change := "rename"
+		switch {
+		case resource.Name == "":
+			change = "set"
+			set++
+		case resource.Name == resource.SuggestedName:
+			change = "keep"
+			kept++
+		default:
+			renamed++
+		}
+		row := []string{resource.Type, resource.ID, currentNameLabel(resource), resource.SuggestedName, change}
+		if withRegion {
+			row = append([]string{resource.Region}, row...)
+		}
+		writeRow(row)
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\n%d resources: %d already match, %d unnamed, %d named differently\n", len(resources), kept, set, renamed)
+}
 
+// printSelectionList prints the numbered selection list, with a header before each run of
+// one resource type. Numbering is continuous across groups so selection still works by number.
+func printSelectionList(w io.Writer, resources []*ResourceInfo) {
 	longestID := 0
 	for _, resource := range resources {
 		if len(resource.ID) > longestID {
@@ -1080,6 +3744,18 @@ func selectResources(resources []*ResourceInfo) ([]*ResourceInfo, bool) {
 	}
 
 	for i, resource := range resources {
+		if i == 0 || resource.Type != resources[i-1].Type {
+			count := 0
+			for _, other := range resources[i:] {
+				if other.Type != resource.Type {
+					break
+				}
+				count++
+			}
+			label := resourceTypeLabel(resource.Type, 2)
+			fmt.Fprintf(w, "%s\n", color(fmt.Sprintf("%s%s (%d):", strings.ToUpper(label[:1]), label[1:], count), qc.ColorBlue))
+		}
+
 		// Alternate row colors for better readability
 		var rowColor string
 		if i%2 == 0 {
@@ -1088,28 +3764,175 @@ func selectResources(resources []*ResourceInfo) ([]*ResourceInfo, bool) {
 			rowColor = qc.ColorCyan
 		}
 
-		// Show current name (or "untagged" if empty) and suggested name with color styling
-		var currentNameDisplay string
-		if resource.Name == "" {
-			currentNameDisplay = color("untagged", qc.ColorYellow)
-		} else {
-			currentNameDisplay = color(resource.Name, qc.ColorRed)
-		}
+		// Show current name (or "untagged"/"empty") and suggested name with color styling
+		currentNameDisplay := colorCurrentName(resource)
 
 		suggestedNameDisplay := color(resource.SuggestedName, qc.ColorGreen)
 
 		entry := fmt.Sprintf(
-			"%3d. %-*s %s -> %s",
-			i+1, longestID, resource.ID, currentNameDisplay, suggestedNameDisplay,
+			"%3d. %-*s %s -> %s%s%s",
+			i+1, longestID, resource.ID, currentNameDisplay, suggestedNameDisplay, nameSourceNote(resource), costNote(resource),
 		)
-		fmt.Println(color(entry, rowColor))
+		fmt.Fprintln(w, color(entry, rowColor))
+	}
+}
+
+// validOutputFormats lists the supported --output values
+var validOutputFormats = []string{"table", "yaml", "json", "ndjson"}
+
+// writeOutputFile replaces the --output-file with what write produces, making its parent
+// directories the way saveHistory does. The file is only replaced if write succeeds, and
+// write's own error, e.g. from a failed scan, is returned as is.
+func writeOutputFile(path string, write func(io.Writer) error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for --output-file: %v", err)
+	}
+	var writeErr error
+	err := streamFileAtomic(path, 0644, func(w io.Writer) error {
+		writeErr = write(w)
+		return writeErr
+	})
+	if writeErr != nil {
+		return writeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write --output-file: %v", err)
+	}
+	return nil
+}
+
+// writeResources writes resources as a YAML document laid out like the history file, or the
+// same document as indented JSON
+func writeResources(w io.Writer, resources []*ResourceInfo, format string) error {
+	document := struct {
+		Resources []*ResourceInfo `yaml:"resources" json:"resources"`
+	}{Resources: resources}
+	if document.Resources == nil {
+		document.Resources = []*ResourceInfo{}
+	}
+
+	var data []byte
+	var err error
+	if format == "json" {
+		data, err = json.MarshalIndent(document, "", "  ")
+		data = append(data, '\n')
+	} else {
+		data, err = yaml.Marshal(document)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s output: %v", format, err)
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// streamResources scans with each resource written to w as one line of JSON as soon as it's
+// named (--output ndjson), returning how many were written. Unlike writeResources, output
+// isn't sorted and shared names aren't disambiguated.
+func streamResources(ctx context.Context, config *Config, w io.Writer) (int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	encoder := json.NewEncoder(w)
+	streamed := 0
+	var writeErr error
+	scoped := *config
+	scoped.Emit = func(resource *ResourceInfo) {
+		if writeErr != nil {
+			return
+		}
+		// Stop scanning once the reader goes away, e.g. when piped into head
+		if writeErr = encoder.Encode(resource); writeErr != nil {
+			cancel()
+			return
+		}
+		streamed++
+	}
+
+	_, err := findUntaggedResources(ctx, &scoped)
+	if writeErr != nil {
+		return streamed, fmt.Errorf("failed to write ndjson output: %v", writeErr)
+	}
+	return streamed, err
+}
+
+// countResources counts resources by type for --count, including zeros for every scanned type
+func countResources(config *Config, resources []*ResourceInfo) map[string]int {
+	counts := make(map[string]int)
+	for _, resourceType := range resourceTypeOrder {
+		if shouldScanType(config, resourceType) {
+			counts[resourceType] = 0
+		}
+	}
+	for _, resource := range resources {
+		counts[resource.Type]++
+	}
+	return counts
+}
+
+// writeCounts writes --count output: one line like "instance=3 volume=0 ... total=3", or a
+// JSON object with the same keys for --output json
+func writeCounts(w io.Writer, counts map[string]int, format string) error {
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+
+	if format == "json" {
+		document := maps.Clone(counts)
+		document["total"] = total
+		data, err := json.Marshal(document)
+		if err != nil {
+			return fmt.Errorf("failed to marshal counts: %v", err)
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	}
+
+	var parts []string
+	for _, resourceType := range resourceTypeOrder {
+		if n, ok := counts[resourceType]; ok {
+			parts = append(parts, fmt.Sprintf("%s=%d", resourceType, n))
+		}
+	}
+	parts = append(parts, fmt.Sprintf("total=%d", total))
+	_, err := fmt.Fprintln(w, strings.Join(parts, " "))
+	return err
+}
+
+// parseSelection parses comma-separated 1-based numbers into 0-based indexes below count,
+// warning on w about entries that aren't valid
+func parseSelection(w io.Writer, input string, count int) []int {
+	var indexes []int
+	for _, part := range strings.Split(input, ",") {
+		part = strings.TrimSpace(part)
+		if idx, err := strconv.Atoi(part); err == nil {
+			if idx >= 1 && idx <= count {
+				indexes = append(indexes, idx-1)
+			} else {
+				fmt.Fprintf(w, "%s Invalid selection: %d (valid range: 1-%d)\n", color("⚠️", qc.ColorYellow), idx, count)
+			}
+		} else {
+			fmt.Fprintf(w, "%s Invalid input: '%s' (expected number)\n", color("⚠️", qc.ColorYellow), part)
+		}
 	}
+	return indexes
+}
+
+// selectResources displays resources and allows user to select which ones to tag
+func selectResources(ctx context.Context, resources []*ResourceInfo) ([]*ResourceInfo, bool) {
+	fmt.Printf("\n%s\n", color("Resources without Name tags:", qc.ColorBlue))
+	printSelectionList(os.Stdout, resources)
+	printCostSummary(os.Stdout, resources)
 
 	reader := bufio.NewReader(os.Stdin)
 	fmt.Printf("%s", color("Select resources to tag (comma-separated numbers, or 'all' for all). Enter for all resources: ", qc.ColorYellow))
-	input, err := reader.ReadString('\n')
+	input, err := readLine(ctx, reader)
+	if errors.Is(err, errPromptTimeout) {
+		return nil, false
+	}
 	if err != nil {
-		log.Fatal(err)
+		fatal(ctx, err)
 	}
 	input = strings.TrimSpace(input)
 	if input == "" {
@@ -1122,20 +3945,9 @@ func selectResources(resources []*ResourceInfo) ([]*ResourceInfo, bool) {
 		return resources, true // true = auto-apply all tags
 	}
 
-	// Parse comma-separated numbers
 	var selected []*ResourceInfo
-	parts := strings.Split(input, ",")
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		if idx, err := strconv.Atoi(part); err == nil {
-			if idx >= 1 && idx <= len(resources) {
-				selected = append(selected, resources[idx-1])
-			} else {
-				fmt.Printf("%s Invalid selection: %d (valid range: 1-%d)\n", color("⚠️", qc.ColorYellow), idx, len(resources))
-			}
-		} else {
-			fmt.Printf("%s Invalid input: '%s' (expected number)\n", color("⚠️", qc.ColorYellow), part)
-		}
+	for _, idx := range parseSelection(os.Stdout, input, len(resources)) {
+		selected = append(selected, resources[idx])
 	}
 
 	if len(selected) == 0 {
@@ -1145,73 +3957,482 @@ func selectResources(resources []*ResourceInfo) ([]*ResourceInfo, bool) {
 	return selected, false // false = prompt for each tag
 }
 
-// applyTags applies Name tags to the selected resources
-func applyTags(ctx context.Context, config *Config, resources []*ResourceInfo, accountID, runID string, autoApply bool) error {
+// confirmBulkApply lists every pending old -> new name change and asks once for confirmation
+func confirmBulkApply(ctx context.Context, resources []*ResourceInfo) (bool, error) {
+	fmt.Printf("\n%s\n", color("Pending tag changes:", qc.ColorBlue))
+
+	// Size the columns to the longest type and ID, so types like route-table stay aligned
+	longestType, longestID := 0, 0
+	for _, resource := range resources {
+		longestType = max(longestType, len(resource.Type))
+		longestID = max(longestID, len(resource.ID))
+	}
+
+	for _, resource := range resources {
+		fmt.Printf("  %-*s %-*s %s -> %s\n",
+			longestType, resource.Type, longestID, resource.ID, colorCurrentName(resource), color(resource.SuggestedName, qc.ColorGreen))
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Printf("%s", color(fmt.Sprintf("Apply these %d tags? (y/N): ", len(resources)), qc.ColorYellow))
+	response, err := readLine(ctx, reader)
+	if errors.Is(err, errPromptTimeout) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read user input: %v", err)
+	}
+
+	response = strings.TrimSpace(strings.ToLower(response))
+	return response == "y" || response == "yes", nil
+}
+
+// needsBulkConfirm reports whether auto-applying count tags should stop at confirmBulkApply:
+// with --confirm-over, only above the threshold; otherwise unless --yes
+func (c *Config) needsBulkConfirm(count int) bool {
+	if c.ConfirmOver > 0 {
+		return count > c.ConfirmOver
+	}
+	return !c.AssumeYes
+}
+
+// applyTags applies Name tags to the selected resources and returns how many tags were applied
+func applyTags(ctx context.Context, config *Config, resources []*ResourceInfo, accountID, runID string, autoApply bool) (int, error) {
+	resources = skipUntaggable(resources)
+	if len(resources) == 0 {
+		return 0, nil
+	}
+
+	// Give one final look at the whole batch before auto-applying
+	if autoApply && config.needsBulkConfirm(len(resources)) {
+		confirmed, err := confirmBulkApply(ctx, resources)
+		if err != nil {
+			return 0, err
+		}
+		if !confirmed {
+			fmt.Println("Tagging cancelled.")
+			return 0, nil
+		}
+	}
+
 	successCount := 0
+	typeCounts := make(map[string]int)
+
+	// stopTagging reports the first failure along with what was tagged before it
+	stopTagging := func(err error) (int, error) {
+		fmt.Printf("%s Failed to apply tag: %v\n", color("❌", qc.ColorRed), err)
+		switch classifyAWSError(err) {
+		case awsErrorNotFound:
+			fmt.Printf("%s The resource was deleted after the scan; re-run to pick up the current resources.\n", color("ℹ️", qc.ColorCyan))
+		case awsErrorThrottling:
+			fmt.Printf("%s AWS is rate limiting CreateTags; try again with a lower --concurrency.\n", color("ℹ️", qc.ColorCyan))
+		case awsErrorTimeout:
+			fmt.Printf("%s AWS stopped responding; check the network and try again, or raise --call-timeout.\n", color("ℹ️", qc.ColorCyan))
+		}
+		fmt.Printf("%s Stopping tagging process after %d successful applications.\n", color("⚠️", qc.ColorYellow), successCount)
+		if successCount > 0 {
+			fmt.Printf("%s %s\n", color("📊", qc.ColorBlue), formatTagSummary(typeCounts))
+			if !config.NoHistory {
+				fmt.Printf("%s To pick up where this stopped, re-run with --resume %s\n", color("ℹ️", qc.ColorCyan), runID)
+			}
+		}
+		return successCount, err
+	}
+
+	// applyRemaining hands the rest of an auto-applied batch to the batched worker pool
+	applyRemaining := func(pending []*ResourceInfo) error {
+		if len(pending) == 0 {
+			return nil
+		}
+		applied, err := applyTagBatches(ctx, config, pending, accountID, runID, typeCounts)
+		successCount += applied
+		return err
+	}
 
 	for i, resource := range resources {
+		if autoApply {
+			pending := resources[i:]
+			if !config.Overwrite {
+				pending = skipOverwrites(pending, config.Report)
+			}
+			if err := applyRemaining(pending); err != nil {
+				return stopTagging(err)
+			}
+			break
+		}
+
 		// Show the resource to be tagged
-		fmt.Printf("\n%s Tag %d of %d:\n", color("🏷️", qc.ColorBlue), i+1, len(resources))
-		fmt.Printf("  Resource: %s %s\n", resource.Type, resource.ID)
+		infof("\n%s Tag %d of %d:\n", color("🏷️", qc.ColorBlue), i+1, len(resources))
+		infof("  Resource: %s %s\n", resource.Type, resource.ID)
+		if config.ShowLinks {
+			region := resource.Region
+			if region == "" {
+				region = config.Region
+			}
+			if link := consoleURL(resource.Type, resource.ID, region); link != "" {
+				infof("  Console: %s\n", hyperlink(color(link, qc.ColorCyan), link))
+			}
+		}
 
 		// Display current name with color styling
-		if resource.Name == "" {
-			fmt.Printf("  Current: %s\n", color("untagged", qc.ColorYellow))
-		} else {
-			fmt.Printf("  Current: %s\n", color(resource.Name, qc.ColorRed))
+		infof("  Current: %s\n", colorCurrentName(resource))
+		if resource.Name != "" {
+			infof("  %s This replaces the existing name\n", color("⚠️", qc.ColorYellow))
 		}
 
 		// Display new name with color styling
-		fmt.Printf("  New: %s\n", color(resource.SuggestedName, qc.ColorGreen))
+		infof("  New: %s\n", color(resource.SuggestedName, qc.ColorGreen))
 
 		// Prompt user to continue (unless auto-applying)
 		if !autoApply {
 			reader := bufio.NewReader(os.Stdin)
-			fmt.Printf("%s Press Enter to apply this tag (or Ctrl+C to cancel): ", color("→", qc.ColorYellow))
-			_, err := reader.ReadString('\n')
-			if err != nil {
-				return fmt.Errorf("failed to read user input: %v", err)
-			}
-		} else {
-			fmt.Printf("%s Auto-applying tag...\n", color("→", qc.ColorYellow))
-		}
-
-		// Apply the tag with progress indicator
-		err := showProgress(fmt.Sprintf("Applying tag to %s %s...", resource.Type, resource.ID), func() error {
-			input := &ec2.CreateTagsInput{
-				Resources: []string{resource.ID},
-				Tags: []types.Tag{
-					{
-						Key:   stringPtr("Name"),
-						Value: stringPtr(resource.SuggestedName),
-					},
-				},
+			fmt.Printf("%s Press Enter to apply this tag, 'a' to apply this and all remaining without asking again (or Ctrl+C to cancel): ", color("→", qc.ColorYellow))
+			response, err := readLine(ctx, reader)
+			if errors.Is(err, errPromptTimeout) {
+				fmt.Printf("%s No answer within %s, leaving the remaining %d resources untagged.\n", color("ℹ️", qc.ColorCyan), promptTimeout, len(resources)-i)
+				break
 			}
-
-			_, err := config.EC2Client.CreateTags(ctx, input)
 			if err != nil {
-				return fmt.Errorf("failed to tag %s %s: %v", resource.Type, resource.ID, err)
+				return successCount, fmt.Errorf("failed to read user input: %v", err)
 			}
 
-			// Log the tagging action to history
-			if err := addToHistory(accountID, resource.ID, resource.Name, resource.SuggestedName, runID); err != nil {
-				// Don't fail the tagging operation if history logging fails, just log a warning
-				fmt.Printf("Warning: Failed to log tagging action to history: %v\n", err)
+			// Yes to all from here: this resource was just confirmed, so it's applied even if it
+			// replaces a name; the rest follow the auto-apply rules. 'c' (continue) is the old spelling.
+			if answer := strings.TrimSpace(strings.ToLower(response)); answer == "a" || answer == "c" {
+				fmt.Printf("%s Applying this and the remaining %d tags without asking again.\n", color("ℹ️", qc.ColorCyan), len(resources)-i-1)
+				remaining := resources[i+1:]
+				if !config.Overwrite {
+					remaining = skipOverwrites(remaining, config.Report)
+				}
+				if err := applyRemaining(append([]*ResourceInfo{resource}, remaining...)); err != nil {
+					return stopTagging(err)
+				}
+				break
 			}
+		}
 
-			return nil
+		// Apply the tag with progress indicator
+		err := showProgress(fmt.Sprintf("Applying tag to %s %s...", resource.Type, resource.ID), func() error {
+			return tagResources(ctx, config, []*ResourceInfo{resource}, accountID, runID)
 		})
 
 		if err != nil {
-			// Stop on first failure
-			fmt.Printf("%s Failed to apply tag: %v\n", color("❌", qc.ColorRed), err)
-			fmt.Printf("%s Stopping tagging process after %d successful applications.\n", color("⚠️", qc.ColorYellow), successCount)
-			return err
+			return stopTagging(err)
 		}
 
 		successCount++
-		fmt.Printf("%s Successfully tagged %s %s\n", color("✅", qc.ColorGreen), resource.Type, resource.ID)
+		typeCounts[resource.Type]++
+		infof("%s Successfully tagged %s %s\n", color("✅", qc.ColorGreen), resource.Type, resource.ID)
+	}
+
+	infof("\n")
+	fmt.Printf("%s %s\n", color("📊", qc.ColorBlue), colorBold(formatTagSummary(typeCounts), qc.ColorGreen))
+	return successCount, nil
+}
+
+// skipOverwrites drops resources that already have a non-empty Name, listing each old -> new
+// change and recording it in the --report-file report, so auto-applied tags only replace
+// existing names with --overwrite
+func skipOverwrites(resources []*ResourceInfo, report *RunReport) []*ResourceInfo {
+	var kept []*ResourceInfo
+	for _, resource := range resources {
+		if resource.Name == "" {
+			kept = append(kept, resource)
+			continue
+		}
+		fmt.Printf("%s Skipping %s %s: would overwrite %s with %s (pass --overwrite to allow)\n", color("⚠️", qc.ColorYellow),
+			resource.Type, resource.ID, color(fmt.Sprintf("'%s'", resource.Name), qc.ColorRed), color(fmt.Sprintf("'%s'", resource.SuggestedName), qc.ColorGreen))
+		report.recordSkipped(resource, "would overwrite an existing name (pass --overwrite to allow)")
+	}
+	return kept
+}
+
+// maxCreateTagsResources is the most resource IDs sent in a single CreateTags call
+const maxCreateTagsResources = 1000
+
+// defaultThrottleThreshold is the default --throttle-threshold. It's a rough heuristic: EC2
+// refills its tagging request budget at a few calls per second, so bursts past this tend
+// to see RequestLimitExceeded.
+const defaultThrottleThreshold = 100
+
+// estimateTagCalls returns how many tagging API calls applying resources would take: one
+// CreateTags per batch of EC2 resources sharing a Name, and one TagResource per KMS key
+func estimateTagCalls(resources []*ResourceInfo) int {
+	var ec2Resources []*ResourceInfo
+	calls := 0
+	for _, resource := range resources {
+		if resource.Type == "kms-key" {
+			calls++
+			continue
+		}
+		ec2Resources = append(ec2Resources, resource)
+	}
+	return calls + len(groupByTagValue(ec2Resources, maxCreateTagsResources))
+}
+
+// printCallEstimate prints how many tagging API calls applying resources would take, with a
+// warning when that's more than threshold
+func printCallEstimate(w io.Writer, resources []*ResourceInfo, concurrency, threshold int) {
+	calls := estimateTagCalls(resources)
+	fmt.Fprintf(w, "%s Applying these would take %d tagging API calls for %d resources.\n", color("ℹ️", qc.ColorCyan), calls, len(resources))
+	if threshold <= 0 || calls <= threshold {
+		return
+	}
+	advice := "split the run with --limit or --ids-from"
+	if concurrency > 1 {
+		advice = fmt.Sprintf("lower --concurrency (currently %d) or %s", concurrency, advice)
+	}
+	fmt.Fprintf(w, "%s That's more than %d calls, so AWS may throttle it (RequestLimitExceeded); %s.\n", color("⚠️", qc.ColorYellow), threshold, advice)
+}
+
+// groupByTagValue groups resources of the same type that get the same Name so each group can
+// be tagged with one call by that type's tagger. Groups keep first-appearance order and hold at
+// most maxBatch resources.
+func groupByTagValue(resources []*ResourceInfo, maxBatch int) [][]*ResourceInfo {
+	var batches [][]*ResourceInfo
+	open := make(map[string]int) // type and Name -> index of its batch that still has room
+	for _, resource := range resources {
+		key := resource.Type + "\x00" + resource.SuggestedName
+		if idx, ok := open[key]; ok && len(batches[idx]) < maxBatch {
+			batches[idx] = append(batches[idx], resource)
+			continue
+		}
+		open[key] = len(batches)
+		batches = append(batches, []*ResourceInfo{resource})
+	}
+	return batches
+}
+
+// tagResources sets the same Name tag on a batch of resources with one CreateTags call
+// and records each resource in the history file
+func tagResources(ctx context.Context, config *Config, batch []*ResourceInfo, accountID, runID string) error {
+	first := batch[0]
+	ids := make([]string, len(batch))
+	for i, resource := range batch {
+		ids[i] = resource.ID
+	}
+
+	tags := append([]types.Tag{
+		{
+			Key:   stringPtr("Name"),
+			Value: stringPtr(first.SuggestedName),
+		},
+	}, config.ExtraTags...)
+
+	tagger, ok := taggers[first.Type]
+	if !ok {
+		return fmt.Errorf("failed to tag %s %s: quick-tag doesn't know how to tag this type", first.Type, strings.Join(ids, ", "))
+	}
+	err := tagger.tag(ctx, config, ids, tags)
+	config.Report.recordTagging(batch, err)
+	if err != nil {
+		logEvent(slog.LevelError, "tagging failed", "type", first.Type, "resources", ids, "name", first.SuggestedName, "error", err.Error())
+		return fmt.Errorf("failed to tag %s %s: %w", first.Type, strings.Join(ids, ", "), wrapPermissionError(err, tagger.action, first.Type+"s"))
+	}
+
+	logEvent(slog.LevelInfo, "tagged", "type", first.Type, "resources", ids, "name", first.SuggestedName)
+
+	if config.NoHistory {
+		return nil
+	}
+
+	// Log each tagging action to history; addToHistory takes the history file lock,
+	// so concurrent workers append safely
+	for _, resource := range batch {
+		entry := TagHistoryEntry{
+			Account:   accountID,
+			Region:    config.Region,
+			Resource:  resource.ID,
+			OldValue:  resource.Name,
+			NewValue:  resource.SuggestedName,
+			RunID:     runID,
+			ExtraTags: tagKeys(config.ExtraTags),
+			PriorTags: priorExtraTags(resource.Tags, config.ExtraTags),
+			Principal: config.Principal,
+			User:      config.User,
+		}
+		if err := addToHistory(config.HistoryFile, config.HistoryMax, entry); err != nil {
+			// Don't fail the tagging operation if history logging fails, just log a warning
+			fmt.Printf("Warning: Failed to log tagging action to history: %v\n", err)
+		}
 	}
 
 	return nil
 }
+
+// tagger tags a batch of resources of one type through the API of the service that owns them
+type tagger struct {
+	action string // IAM action named in permission errors
+	tag    func(ctx context.Context, config *Config, ids []string, tags []types.Tag) error
+}
+
+// ec2Tagger tags EC2 resources with a single CreateTags call
+var ec2Tagger = tagger{
+	action: "ec2:CreateTags",
+	tag: func(ctx context.Context, config *Config, ids []string, tags []types.Tag) error {
+		debugf("CreateTags: %s Name=%q (+%d extra tags)", strings.Join(ids, ","), aws.ToString(tags[0].Value), len(tags)-1)
+		config.Stats.recordCreateTags()
+		_, err := config.EC2Client.CreateTags(ctx, &ec2.CreateTagsInput{Resources: ids, Tags: tags})
+		return err
+	},
+}
+
+// taggers maps each resource type to its tagger. Resources of a type missing here are skipped
+// with a warning by skipUntaggable, so a new scan can land before its tagging support does.
+var taggers = map[string]tagger{
+	"instance":    ec2Tagger,
+	"volume":      ec2Tagger,
+	"eni":         ec2Tagger,
+	"eip":         ec2Tagger,
+	"vpc":         ec2Tagger,
+	"subnet":      ec2Tagger,
+	"route-table": ec2Tagger,
+	"nat-gateway": ec2Tagger,
+	"igw":         ec2Tagger,
+	"tgw":         ec2Tagger,
+	"kms-key": {
+		action: "kms:TagResource",
+		tag: func(ctx context.Context, config *Config, ids []string, tags []types.Tag) error {
+			return tagKMSKeys(ctx, config.KMSClient, ids, kmsTags(tags))
+		},
+	},
+}
+
+// skipUntaggable drops resources whose type has no tagger, warning once per type
+func skipUntaggable(resources []*ResourceInfo) []*ResourceInfo {
+	skipped := make(map[string]int)
+	var kept []*ResourceInfo
+	for _, resource := range resources {
+		if _, ok := taggers[resource.Type]; ok {
+			kept = append(kept, resource)
+		} else {
+			skipped[resource.Type]++
+		}
+	}
+	for _, resourceType := range slices.Sorted(maps.Keys(skipped)) {
+		warnf("Skipping %d %s resources: quick-tag can't tag this type yet", skipped[resourceType], resourceType)
+	}
+	return kept
+}
+
+// applyTagBatches tags resources that share a Name together, using config.Concurrency workers,
+// and adds to typeCounts. After the first failure no new batches are started; the first error is returned.
+func applyTagBatches(ctx context.Context, config *Config, resources []*ResourceInfo, accountID, runID string, typeCounts map[string]int) (int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := max(config.Concurrency, 1)
+	batches := groupByTagValue(resources, maxCreateTagsResources)
+	infof("\n%s Applying %d tags in %d CreateTags calls with %d workers...\n", color("→", qc.ColorYellow), len(resources), len(batches), workers)
+
+	work := make(chan []*ResourceInfo)
+	var (
+		mu           sync.Mutex // serializes output, counts, and firstErr
+		wg           sync.WaitGroup
+		successCount int
+		firstErr     error
+	)
+
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range work {
+				err := tagResources(ctx, config, batch, accountID, runID)
+
+				mu.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+						cancel()
+					}
+				} else {
+					for _, resource := range batch {
+						successCount++
+						typeCounts[resource.Type]++
+						infof("%s Tagged %s %s as %s (%d of %d)\n", color("✅", qc.ColorGreen), resource.Type, resource.ID,
+							color(resource.SuggestedName, qc.ColorGreen), successCount, len(resources))
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+dispatch:
+	for _, batch := range batches {
+		select {
+		case work <- batch:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	// Report an interrupt or timeout of the parent context if no tag failed first
+	if firstErr == nil && ctx.Err() != nil && successCount < len(resources) {
+		firstErr = ctx.Err()
+	}
+	return successCount, firstErr
+}
+
+// resourceTypeOrder is the display order for resource types in summaries
+var resourceTypeOrder = []string{"instance", "volume", "eni", "eip", "vpc", "subnet", "route-table", "nat-gateway", "igw", "tgw", "kms-key"}
+
+// resourceTypeLabel returns a human-readable, pluralized label for a resource type
+func resourceTypeLabel(resourceType string, count int) string {
+	label := resourceType
+	switch resourceType {
+	case "eni":
+		label = "ENI"
+	case "eip":
+		label = "EIP"
+	case "vpc":
+		label = "VPC"
+	case "route-table":
+		label = "route table"
+	case "nat-gateway":
+		label = "NAT gateway"
+	case "igw":
+		label = "internet gateway"
+	case "tgw":
+		label = "transit gateway"
+	case "kms-key":
+		label = "KMS key"
+	}
+	if count != 1 {
+		label += "s"
+	}
+	return label
+}
+
+// formatTagSummary builds a per-type summary like "Tagged 2 instances, 1 volume, 3 ENIs (6 total)."
+func formatTagSummary(typeCounts map[string]int) string {
+	// Known types first in display order, then any others alphabetically
+	types := append([]string{}, resourceTypeOrder...)
+	var others []string
+	for resourceType := range typeCounts {
+		if !slices.Contains(resourceTypeOrder, resourceType) {
+			others = append(others, resourceType)
+		}
+	}
+	sort.Strings(others)
+	types = append(types, others...)
+
+	var parts []string
+	total := 0
+	for _, resourceType := range types {
+		count := typeCounts[resourceType]
+		if count == 0 {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%d %s", count, resourceTypeLabel(resourceType, count)))
+		total += count
+	}
+
+	if total == 0 {
+		return "Tagged 0 resources."
+	}
+	return fmt.Sprintf("Tagged %s (%d total).", strings.Join(parts, ", "), total)
+}
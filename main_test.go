@@ -1,14 +1,48 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+	"unicode/utf8"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	cetypes "github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
+	taggingtypes "github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi/types"
+	"github.com/aws/smithy-go"
 	qc "github.com/bevelwork/quick_color"
+	"github.com/bevelwork/quick_tag/naming"
+	versionpkg "github.com/bevelwork/quick_tag/version"
+	"gopkg.in/yaml.v3"
 )
 
 // TestMain runs before all tests
@@ -19,12 +53,27 @@ func TestMain(m *testing.M) {
 	os.Unsetenv("AWS_SECRET_ACCESS_KEY")
 	os.Unsetenv("AWS_SESSION_TOKEN")
 	os.Unsetenv("AWS_PROFILE")
+	os.Unsetenv("QUICK_TAG_HISTORY")
+	os.Unsetenv("QUICK_TAG_CONFIG")
+	os.Unsetenv("NO_COLOR")
 
 	// Run tests
 	code := m.Run()
 	os.Exit(code)
 }
 
+// TestVersionInfo tests that --version prints the ldflags-injected commit and build date on their own lines
+func TestVersionInfo(t *testing.T) {
+	defer func(commit, buildDate string) { versionpkg.Commit, versionpkg.BuildDate = commit, buildDate }(versionpkg.Commit, versionpkg.BuildDate)
+	versionpkg.Commit, versionpkg.BuildDate = "0123abc", "2026-01-02T03:04:05Z"
+
+	lines := strings.Split(versionInfo(), "\n")
+	expected := []string{"quick-tag " + resolveVersion(), "commit: 0123abc", "built: 2026-01-02T03:04:05Z"}
+	if !slices.Equal(lines, expected) {
+		t.Errorf("versionInfo() = %q, want %q", lines, expected)
+	}
+}
+
 // TestVersionFlag tests that the version flag works without AWS credentials
 func TestVersionFlag(t *testing.T) {
 	// This test verifies the version flag works without AWS setup
@@ -50,7 +99,7 @@ func TestVersionFlag(t *testing.T) {
 	}
 }
 
-// TestQuickTagCreatedNameDetection tests the isQuickTagCreatedName function
+// TestQuickTagCreatedNameDetection tests the naming.IsQuickTagCreatedName function
 func TestQuickTagCreatedNameDetection(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -90,22 +139,31 @@ func TestQuickTagCreatedNameDetection(t *testing.T) {
 		{"lambda-ela-attach-abc", "eni", true},
 		{"nat-ela-attach-def", "eni", true},
 		{"service-ela-attach-ghi", "eni", true},
-		{"eni-1234567890123456789", "eni", true}, // 21 character ENI ID
-		{"", "eni", true},                        // Empty name
+		{"eni-0123456789abcdef0", "eni", true}, // Long ENI ID
+		{"eni-0abc1234", "eni", true},          // Short ENI ID
+		{"rds-ela-attach-0abc1234-eni", "eni", true},
+		{"", "eni", true}, // Empty name
 		{"Network interface", "eni", true},
 		{"primary network interface", "eni", true},
 		{"my-custom-eni", "eni", false},
+		// Human-chosen names that only resemble generated ones
+		{"payments-attach-service-eni", "eni", false},
+		{"my-service-eni-attach-1-eni", "eni", false},
+		{"rds-primary-db-eni", "eni", false},
+		{"rds-ela-attach-payments-api-eni", "eni", false},
+		{"eni-payments-gateway1", "eni", false},
+		{"eni-1234567890123456789", "eni", false}, // Too long to be an ENI ID
 	}
 
 	for _, test := range tests {
-		result := isQuickTagCreatedName(test.name, test.resourceType)
+		result := naming.IsQuickTagCreatedName(test.name, test.resourceType)
 		if result != test.expected {
-			t.Errorf("isQuickTagCreatedName(%q, %q) = %v, expected %v", test.name, test.resourceType, result, test.expected)
+			t.Errorf("naming.IsQuickTagCreatedName(%q, %q) = %v, expected %v", test.name, test.resourceType, result, test.expected)
 		}
 	}
 }
 
-// TestQuickTagNameStillValid tests the isQuickTagNameStillValid function
+// TestQuickTagNameStillValid tests the naming.IsQuickTagNameStillValid function
 func TestQuickTagNameStillValid(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -133,15 +191,42 @@ func TestQuickTagNameStillValid(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		result := isQuickTagNameStillValid(test.name, test.resourceType, test.currentState, test.extraInfo)
+		result := naming.IsQuickTagNameStillValid(test.name, test.resourceType, test.currentState, test.extraInfo)
 		if result != test.expected {
-			t.Errorf("isQuickTagNameStillValid(%q, %q, %q, %q) = %v, expected %v",
+			t.Errorf("naming.IsQuickTagNameStillValid(%q, %q, %q, %q) = %v, expected %v",
 				test.name, test.resourceType, test.currentState, test.extraInfo, result, test.expected)
 		}
 	}
 }
 
-// TestGenericNameDetection tests the isGenericName function (backward compatibility)
+// TestMarkerTag tests that the marker tag identifies generated names the heuristics miss
+func TestMarkerTag(t *testing.T) {
+	marked := []types.Tag{
+		{Key: stringPtr("Name"), Value: stringPtr("my-lb-eni")},
+		{Key: stringPtr(markerTagKey), Value: stringPtr("true")},
+	}
+	unmarked := marked[:1]
+
+	if !hasMarkerTag(marked) || hasMarkerTag(unmarked) {
+		t.Error("hasMarkerTag should only match the marker tag")
+	}
+	if !isGeneratedName("my-lb-eni", "eni", marked) {
+		t.Error("A marked name should be treated as generated")
+	}
+	if isGeneratedName("my-lb-eni", "eni", unmarked) {
+		t.Error("An unmarked custom name should not be treated as generated")
+	}
+	if !isGeneratedName("unattached-eni", "eni", nil) {
+		t.Error("Unmarked names should fall back to the heuristics")
+	}
+
+	// A marked ENI name that no longer matches its attachment is stale
+	if naming.GeneratedNameMatchesState("my-lb-eni", "eni", "available", "unattached") {
+		t.Error("A generated attached-ENI name should be stale once the ENI is unattached")
+	}
+}
+
+// TestGenericNameDetection tests naming.IsQuickTagCreatedName with generic and human-chosen names
 func TestGenericNameDetection(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -183,8 +268,8 @@ func TestGenericNameDetection(t *testing.T) {
 		{"lambda-ela-attach-1234567890abcdef-eni", "eni", true},
 		{"nat-ela-attach-01cacbdcc2dd3038b-eni", "eni", true}, // The actual pattern from terminal
 		{"service-ela-attach-1234567890abcdef-eni", "eni", true},
-		// Generic service-attach pattern
-		{"some-service-attach-1234567890abcdef-eni", "eni", true},
+		// Unknown prefixes with an attach segment are human-chosen, not generated
+		{"some-service-attach-1234567890abcdef-eni", "eni", false},
 		{"eni-1234567890abcdef0", "eni", true}, // Just ENI ID
 		{"Network interface for instance", "eni", true},
 		{"primary network interface", "eni", true},
@@ -194,9 +279,9 @@ func TestGenericNameDetection(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := isGenericName(tt.name, tt.resourceType)
+			result := naming.IsQuickTagCreatedName(tt.name, tt.resourceType)
 			if result != tt.expected {
-				t.Errorf("isGenericName(%q, %q) = %v, want %v", tt.name, tt.resourceType, result, tt.expected)
+				t.Errorf("naming.IsQuickTagCreatedName(%q, %q) = %v, want %v", tt.name, tt.resourceType, result, tt.expected)
 			}
 		})
 	}
@@ -226,6 +311,38 @@ func TestColorFunctions(t *testing.T) {
 	}
 }
 
+// TestNoColor tests that color helpers emit plain text when colors are disabled
+func TestNoColor(t *testing.T) {
+	noColor = true
+	defer func() { noColor = false }()
+
+	if got := color("test", qc.ColorRed); got != "test" {
+		t.Errorf("color with noColor = %q, want %q", got, "test")
+	}
+	if got := colorBold("test", qc.ColorRed); got != "test" {
+		t.Errorf("colorBold with noColor = %q, want %q", got, "test")
+	}
+}
+
+// TestShouldDisableColor tests the --no-color flag, TTY detection, and NO_COLOR handling
+func TestShouldDisableColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	if shouldDisableColor(false, true) {
+		t.Error("Colors should be enabled on a terminal by default")
+	}
+	if !shouldDisableColor(true, true) {
+		t.Error("--no-color should disable colors")
+	}
+	if !shouldDisableColor(false, false) {
+		t.Error("Non-terminal output should disable colors")
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	if !shouldDisableColor(false, true) {
+		t.Error("NO_COLOR should disable colors")
+	}
+}
+
 // TestTagDisplayColors tests the color styling for tag displays
 func TestTagDisplayColors(t *testing.T) {
 	// Test untagged display (should be yellow)
@@ -281,6 +398,273 @@ func TestResourceStateColors(t *testing.T) {
 	}
 }
 
+// TestDebugf tests that debug logging only emits output in verbose mode
+func TestDebugf(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	defer func() { verbose = false }()
+
+	verbose = false
+	debugf("DescribeVolumes: page %d", 1)
+	if buf.Len() != 0 {
+		t.Errorf("debugf should not log when verbose is off, got %q", buf.String())
+	}
+
+	verbose = true
+	debugf("DescribeVolumes: page %d", 1)
+	if !strings.Contains(buf.String(), "[verbose] DescribeVolumes: page 1") {
+		t.Errorf("debugf should log when verbose is on, got %q", buf.String())
+	}
+}
+
+// TestResolveEndpointURL tests the --endpoint-url flag and AWS_ENDPOINT_URL fallback
+func TestResolveEndpointURL(t *testing.T) {
+	t.Setenv("AWS_ENDPOINT_URL", "http://localhost:4566")
+
+	if url := resolveEndpointURL("http://localhost:9999"); url != "http://localhost:9999" {
+		t.Errorf("Flag value should win, got %s", url)
+	}
+	if url := resolveEndpointURL(""); url != "http://localhost:4566" {
+		t.Errorf("AWS_ENDPOINT_URL should be used without a flag, got %s", url)
+	}
+
+	t.Setenv("AWS_ENDPOINT_URL", "")
+	if url := resolveEndpointURL(""); url != "" {
+		t.Errorf("No endpoint should be set by default, got %s", url)
+	}
+}
+
+// TestReadLineCancelled tests that prompts return promptly when the context is cancelled
+func TestReadLineCancelled(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	defer promptAbandoned.Store(false)
+	_, err := readLine(ctx, bufio.NewReader(pr))
+	if err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	// The abandoned read is still pending, so later prompts don't start another
+	if _, err := readLine(context.Background(), bufio.NewReader(strings.NewReader("yes\n"))); !errors.Is(err, errPromptTimeout) {
+		t.Errorf("Expected errPromptTimeout after an abandoned prompt, got %v", err)
+	}
+	if _, err := waitTUIKey(context.Background(), bufio.NewReader(strings.NewReader(" "))); !errors.Is(err, errPromptTimeout) {
+		t.Errorf("Expected errPromptTimeout from the TUI after an abandoned prompt, got %v", err)
+	}
+
+	promptAbandoned.Store(false)
+	line, err := readLine(context.Background(), bufio.NewReader(strings.NewReader("yes\n")))
+	if err != nil {
+		t.Errorf("readLine should not error: %v", err)
+	}
+	if line != "yes\n" {
+		t.Errorf("Expected %q, got %q", "yes\n", line)
+	}
+}
+
+// TestPromptRescan tests the re-scan prompt shown after tagging
+func TestPromptRescan(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"y\n", true},
+		{"YES\n", true},
+		{"n\n", false},
+		{"\n", false},
+		{"", false}, // EOF
+	}
+
+	original := os.Stdin
+	defer func() { os.Stdin = original }()
+
+	for _, tt := range tests {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatalf("os.Pipe should not error: %v", err)
+		}
+		w.WriteString(tt.input)
+		w.Close()
+		os.Stdin = r
+
+		if got := promptRescan(context.Background()); got != tt.expected {
+			t.Errorf("promptRescan(%q) = %v, want %v", tt.input, got, tt.expected)
+		}
+		r.Close()
+	}
+}
+
+// TestInfofQuiet tests that --quiet suppresses progress output
+func TestInfofQuiet(t *testing.T) {
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe should not error: %v", err)
+	}
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	quiet = true
+	infof("hidden %d\n", 1)
+	quiet = false
+	infof("shown %d\n", 2)
+
+	w.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Reading captured output should not error: %v", err)
+	}
+	if string(data) != "shown 2\n" {
+		t.Errorf("Expected only non-quiet output, got %q", string(data))
+	}
+}
+
+// TestSpinnerConfig tests --spinner style and interval validation and drawing
+func TestSpinnerConfig(t *testing.T) {
+	for _, style := range []string{"braille", "dots", "line", "none"} {
+		if _, err := newSpinnerConfig(style, 100*time.Millisecond); err != nil {
+			t.Errorf("newSpinnerConfig(%q) should not error: %v", style, err)
+		}
+	}
+	if _, err := newSpinnerConfig("stars", 100*time.Millisecond); err == nil {
+		t.Error("Unknown spinner styles should error")
+	}
+	if _, err := newSpinnerConfig("line", 0); err == nil {
+		t.Error("A non-positive spinner interval should error")
+	}
+
+	config, _ := newSpinnerConfig("line", time.Millisecond)
+	var buf safeBuffer
+	throbber := newThrobber(&buf, "Scanning...", config)
+	throbber.Start()
+	time.Sleep(20 * time.Millisecond)
+	throbber.Stop()
+	if !strings.Contains(buf.String(), "| Scanning...") {
+		t.Errorf("Expected line spinner frames, got %q", buf.String())
+	}
+	if !strings.HasSuffix(buf.String(), "\r\033[K") {
+		t.Error("Stopping the spinner should clear the line")
+	}
+
+	// Updated messages show up on later frames
+	buf = safeBuffer{}
+	throbber = newThrobber(&buf, "Scanning...", config)
+	throbber.Start()
+	throbber.SetMessage("Scanning... (1,234 seen)")
+	time.Sleep(20 * time.Millisecond)
+	throbber.Stop()
+	if !strings.Contains(buf.String(), "Scanning... (1,234 seen)") {
+		t.Errorf("Expected the updated message, got %q", buf.String())
+	}
+
+	// The none style disables spinners entirely
+	original := spinner
+	defer func() { spinner = original }()
+	spinner, _ = newSpinnerConfig("none", 100*time.Millisecond)
+	if spinnerEnabled() {
+		t.Error("The none style should disable spinners")
+	}
+}
+
+// safeBuffer is a bytes.Buffer that can be written from a spinner goroutine
+type safeBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *safeBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *safeBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// TestLoadAWSConfigRegion tests region precedence: flag, then AWS config, then the fallback
+func TestLoadAWSConfigRegion(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_DEFAULT_REGION", "")
+
+	t.Setenv("AWS_REGION", "eu-west-2")
+	if cfg, err := loadAWSConfig(ctx, "ap-south-1", ""); err != nil || cfg.Region != "ap-south-1" {
+		t.Errorf("Explicit region should win, got %q (err %v)", cfg.Region, err)
+	}
+	if cfg, err := loadAWSConfig(ctx, "", ""); err != nil || cfg.Region != "eu-west-2" {
+		t.Errorf("Configured region should be used without --region, got %q (err %v)", cfg.Region, err)
+	}
+
+	// A profile region is used when the environment doesn't set one
+	t.Setenv("AWS_REGION", "")
+	if err := os.WriteFile(filepath.Join(dir, "config"), []byte("[default]\nregion = sa-east-1\n"), 0644); err != nil {
+		t.Fatalf("Writing AWS config should not error: %v", err)
+	}
+	if cfg, err := loadAWSConfig(ctx, "", ""); err != nil || cfg.Region != "sa-east-1" {
+		t.Errorf("Profile region should be used without --region, got %q (err %v)", cfg.Region, err)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "config")); err != nil {
+		t.Fatalf("Removing AWS config should not error: %v", err)
+	}
+	if cfg, err := loadAWSConfig(ctx, "", ""); err != nil || cfg.Region != fallbackRegion {
+		t.Errorf("Expected fallback region %q, got %q (err %v)", fallbackRegion, cfg.Region, err)
+	}
+}
+
+// TestDefaults tests saving last-used settings and flag precedence over them
+func TestDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".quick-tag-config.yml")
+
+	defaults, err := loadDefaults(path)
+	if err != nil || *defaults != (Defaults{}) {
+		t.Errorf("A missing defaults file should load empty defaults, got %+v (err %v)", defaults, err)
+	}
+
+	if err := saveDefaults(path, &Defaults{Region: "eu-west-1", Profile: "prod"}); err != nil {
+		t.Fatalf("saveDefaults should not error: %v", err)
+	}
+	defaults, err = loadDefaults(path)
+	if err != nil || defaults.Region != "eu-west-1" || defaults.Profile != "prod" {
+		t.Errorf("Defaults did not round-trip, got %+v (err %v)", defaults, err)
+	}
+
+	tests := []struct {
+		name      string
+		flagValue string
+		explicit  bool
+		saved     string
+		expected  string
+	}{
+		{"explicit flag wins", "us-west-2", true, "eu-west-1", "us-west-2"},
+		{"explicit empty flag wins", "", true, "eu-west-1", ""},
+		{"saved default used", "", false, "eu-west-1", "eu-west-1"},
+		{"flag default without saved", "", false, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveDefault(tt.flagValue, tt.explicit, tt.saved); got != tt.expected {
+				t.Errorf("resolveDefault(%q, %v, %q) = %q, want %q", tt.flagValue, tt.explicit, tt.saved, got, tt.expected)
+			}
+		})
+	}
+
+	t.Setenv("QUICK_TAG_CONFIG", path)
+	if getDefaultsFilePath() != path {
+		t.Errorf("QUICK_TAG_CONFIG should override the defaults path, got %q", getDefaultsFilePath())
+	}
+}
+
 // TestAWSConfigFailure tests that AWS config fails predictably without credentials
 func TestAWSConfigFailure(t *testing.T) {
 	// This test verifies that AWS config loading fails predictably
@@ -310,7 +694,7 @@ func TestAWSConfigFailure(t *testing.T) {
 // TestHistoryFunctions tests the history management functions
 func TestHistoryFunctions(t *testing.T) {
 	// Test getHistoryFilePath
-	path := getHistoryFilePath()
+	path := getHistoryFilePath("")
 	if path == "" {
 		t.Error("History file path should not be empty")
 	}
@@ -318,11 +702,11 @@ func TestHistoryFunctions(t *testing.T) {
 		t.Error("History file path should contain .quick-tag.yml")
 	}
 
-	// Clean up any existing history file for clean testing
-	os.Remove(path)
+	// Use a temp history file for clean testing
+	path = filepath.Join(t.TempDir(), ".quick-tag.yml")
 
 	// Test loading non-existent history (should return empty history)
-	history, err := loadHistory()
+	history, err := loadHistory(path)
 	if err != nil {
 		t.Errorf("Loading non-existent history should not error: %v", err)
 	}
@@ -334,13 +718,13 @@ func TestHistoryFunctions(t *testing.T) {
 	}
 
 	// Test adding to history
-	err = addToHistory("123456789012", "i-1234567890abcdef0", "old-name", "new-name", "run-test123")
+	err = addToHistory(path, 0, TagHistoryEntry{Account: "123456789012", Region: "us-east-1", Resource: "i-1234567890abcdef0", OldValue: "old-name", NewValue: "new-name", RunID: "run-test123"})
 	if err != nil {
 		t.Errorf("Adding to history should not error: %v", err)
 	}
 
 	// Test loading history after adding
-	history, err = loadHistory()
+	history, err = loadHistory(path)
 	if err != nil {
 		t.Errorf("Loading history after adding should not error: %v", err)
 	}
@@ -352,6 +736,9 @@ func TestHistoryFunctions(t *testing.T) {
 	if action.Account != "123456789012" {
 		t.Errorf("Expected account 123456789012, got %s", action.Account)
 	}
+	if action.Region != "us-east-1" {
+		t.Errorf("Expected region us-east-1, got %s", action.Region)
+	}
 	if action.Resource != "i-1234567890abcdef0" {
 		t.Errorf("Expected resource i-1234567890abcdef0, got %s", action.Resource)
 	}
@@ -369,6 +756,204 @@ func TestHistoryFunctions(t *testing.T) {
 	}
 }
 
+// TestHistoryFilePathOverride tests the --history-file flag and QUICK_TAG_HISTORY precedence
+func TestHistoryFilePathOverride(t *testing.T) {
+	t.Setenv("QUICK_TAG_HISTORY", "/tmp/env-history.yml")
+
+	if path := getHistoryFilePath("/tmp/flag-history.yml"); path != "/tmp/flag-history.yml" {
+		t.Errorf("Flag override should win, got %s", path)
+	}
+	if path := getHistoryFilePath(""); path != "/tmp/env-history.yml" {
+		t.Errorf("QUICK_TAG_HISTORY should be used without a flag, got %s", path)
+	}
+
+	t.Setenv("QUICK_TAG_HISTORY", "")
+	if path := getHistoryFilePath(""); !strings.HasSuffix(path, ".quick-tag.yml") {
+		t.Errorf("Default path should end with .quick-tag.yml, got %s", path)
+	}
+}
+
+// TestConcurrentAddToHistory tests that concurrent history updates are not lost
+func TestConcurrentAddToHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".quick-tag.yml")
+
+	const writers = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- addToHistory(path, 0, TagHistoryEntry{Account: "123456789012", Region: "us-east-1", Resource: fmt.Sprintf("i-%017d", i), NewValue: "new-name", RunID: "run-concurrent"})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Adding to history should not error: %v", err)
+		}
+	}
+
+	history, err := loadHistory(path)
+	if err != nil {
+		t.Fatalf("Loading history should not error: %v", err)
+	}
+	if len(history.Actions) != writers {
+		t.Errorf("Expected %d actions after concurrent writes, got %d", writers, len(history.Actions))
+	}
+}
+
+// TestHistoryPrune tests which runs are dropped when the history is pruned
+func TestHistoryPrune(t *testing.T) {
+	entry := func(runID string, undone bool) TagHistoryEntry {
+		return TagHistoryEntry{Resource: "i-" + runID, RunID: runID, Undone: undone}
+	}
+	runIDs := func(h *TagHistory) []string {
+		var ids []string
+		for _, action := range h.Actions {
+			if len(ids) == 0 || ids[len(ids)-1] != action.RunID {
+				ids = append(ids, action.RunID)
+			}
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name     string
+		actions  []TagHistoryEntry
+		maxRuns  int
+		removed  int
+		expected []string
+	}{
+		{
+			name:     "disabled",
+			actions:  []TagHistoryEntry{entry("run-1", false), entry("run-2", false)},
+			maxRuns:  0,
+			removed:  0,
+			expected: []string{"run-1", "run-2"},
+		},
+		{
+			name:     "under limit",
+			actions:  []TagHistoryEntry{entry("run-1", false), entry("run-2", false)},
+			maxRuns:  5,
+			removed:  0,
+			expected: []string{"run-1", "run-2"},
+		},
+		{
+			name:     "oldest runs dropped first",
+			actions:  []TagHistoryEntry{entry("run-1", false), entry("run-2", false), entry("run-2", false), entry("run-3", false)},
+			maxRuns:  2,
+			removed:  1,
+			expected: []string{"run-2", "run-3"},
+		},
+		{
+			name:     "undone runs dropped before older active runs",
+			actions:  []TagHistoryEntry{entry("run-1", false), entry("run-2", true), entry("run-3", false), entry("run-4", true)},
+			maxRuns:  2,
+			removed:  2,
+			expected: []string{"run-1", "run-3"},
+		},
+		{
+			name:     "oldest undone run dropped first",
+			actions:  []TagHistoryEntry{entry("run-1", false), entry("run-2", true), entry("run-3", true), entry("run-4", false)},
+			maxRuns:  3,
+			removed:  1,
+			expected: []string{"run-1", "run-3", "run-4"},
+		},
+		{
+			name:     "partially undone runs are not fully undone",
+			actions:  []TagHistoryEntry{entry("run-1", false), entry("run-2", true), entry("run-2", false), entry("run-3", false)},
+			maxRuns:  2,
+			removed:  1,
+			expected: []string{"run-2", "run-3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history := &TagHistory{Actions: tt.actions}
+			removed := history.Prune(tt.maxRuns)
+			if removed != tt.removed {
+				t.Errorf("Prune(%d) removed %d runs, want %d", tt.maxRuns, removed, tt.removed)
+			}
+			got := runIDs(history)
+			if strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Prune(%d) kept runs %v, want %v", tt.maxRuns, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestHistoryImport tests merging another machine's history, skipping actions already present
+func TestHistoryImport(t *testing.T) {
+	dir := t.TempDir()
+	historyPath := filepath.Join(dir, ".quick-tag.yml")
+	local := &TagHistory{Actions: []TagHistoryEntry{
+		{Account: "123456789012", Resource: "i-1", NewValue: "web", RunID: "run-1", Timestamp: "2025-01-01T10:00:00Z"},
+		{Account: "123456789012", Resource: "i-3", NewValue: "cache", RunID: "run-3", Timestamp: "2025-01-03T10:00:00Z"},
+	}}
+	if err := saveHistory(historyPath, local); err != nil {
+		t.Fatal(err)
+	}
+
+	importPath := filepath.Join(dir, "colleague.yml")
+	colleague := &TagHistory{Actions: []TagHistoryEntry{
+		{Account: "123456789012", Resource: "i-1", NewValue: "web", RunID: "run-1", Timestamp: "2025-01-01T10:00:00Z", Undone: true},
+		{Account: "123456789012", Resource: "i-2", NewValue: "db", RunID: "run-2", Timestamp: "2025-01-02T10:00:00Z"},
+		{Account: "123456789012", Resource: "i-4", NewValue: "queue", RunID: "run-4", Timestamp: "2025-01-04T10:00:00Z"},
+	}}
+	if err := saveHistory(importPath, colleague); err != nil {
+		t.Fatal(err)
+	}
+
+	added, err := importHistory(historyPath, importPath)
+	if err != nil {
+		t.Fatalf("importHistory should not error: %v", err)
+	}
+	if added != 2 {
+		t.Errorf("importHistory() added %d actions, want 2", added)
+	}
+
+	history, err := loadHistory(historyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var runs []string
+	for _, action := range history.Actions {
+		runs = append(runs, action.RunID)
+	}
+	if !slices.Equal(runs, []string{"run-1", "run-2", "run-3", "run-4"}) {
+		t.Errorf("Merged runs = %v, want them in timestamp order", runs)
+	}
+	if !history.Actions[0].Undone {
+		t.Error("An action undone in the imported history should stay undone")
+	}
+	if lastRunID, _ := findLastRun(history, "123456789012", time.Time{}); lastRunID != "run-4" {
+		t.Errorf("findLastRun() = %q, want the imported run-4", lastRunID)
+	}
+
+	// Importing again adds nothing
+	if added, err := importHistory(historyPath, importPath); err != nil || added != 0 {
+		t.Errorf("Second importHistory() = %d, %v, want 0, nil", added, err)
+	}
+	if _, err := importHistory(historyPath, filepath.Join(dir, "missing.yml")); err == nil {
+		t.Error("Expected an error importing a missing file")
+	}
+
+	// A file from a newer quick-tag is refused instead of merged without its unknown fields
+	before, _ := os.ReadFile(historyPath)
+	newer := filepath.Join(dir, "newer.yml")
+	os.WriteFile(newer, []byte("version: 2\nactions:\n  - Account: \"123456789012\"\n    Resource: i-new\n    NewValue: web\n    RunID: run-new\n    Future: field\n"), 0644)
+	if _, err := importHistory(historyPath, newer); err == nil || !strings.Contains(err.Error(), "version 2") {
+		t.Errorf("Expected importing a version 2 file to be refused, got %v", err)
+	}
+	if after, _ := os.ReadFile(historyPath); !bytes.Equal(before, after) {
+		t.Error("A refused import should leave the history file unchanged")
+	}
+}
+
 // TestGenerateRunID tests the run ID generation function
 func TestGenerateRunID(t *testing.T) {
 	runID1 := generateRunID()
@@ -398,12 +983,11 @@ func TestGenerateRunID(t *testing.T) {
 
 // TestUndoFunctionality tests the undo functionality
 func TestUndoFunctionality(t *testing.T) {
-	// Clean up any existing history file for clean testing
-	path := getHistoryFilePath()
-	os.Remove(path)
+	// Use a temp history file for clean testing
+	path := filepath.Join(t.TempDir(), ".quick-tag.yml")
 
 	// Test undo with no history
-	err := undoLastRun()
+	err := undoLastRun(context.Background(), path, "", "us-east-1", "", 0, false, nil)
 	if err == nil {
 		t.Error("Undo should fail with no history")
 	}
@@ -412,21 +996,21 @@ func TestUndoFunctionality(t *testing.T) {
 	}
 
 	// Add some test history
-	err = addToHistory("123456789012", "i-1234567890abcdef0", "old-name-1", "new-name-1", "run-test1")
+	err = addToHistory(path, 0, TagHistoryEntry{Account: "123456789012", Region: "us-east-1", Resource: "i-1234567890abcdef0", OldValue: "old-name-1", NewValue: "new-name-1", RunID: "run-test1"})
 	if err != nil {
 		t.Errorf("Adding to history should not error: %v", err)
 	}
-	err = addToHistory("123456789012", "i-0987654321fedcba0", "old-name-2", "new-name-2", "run-test1")
+	err = addToHistory(path, 0, TagHistoryEntry{Account: "123456789012", Region: "us-east-1", Resource: "i-0987654321fedcba0", OldValue: "old-name-2", NewValue: "new-name-2", RunID: "run-test1"})
 	if err != nil {
 		t.Errorf("Adding to history should not error: %v", err)
 	}
-	err = addToHistory("123456789012", "i-1111111111111111", "old-name-3", "new-name-3", "run-test2")
+	err = addToHistory(path, 0, TagHistoryEntry{Account: "123456789012", Region: "us-east-1", Resource: "i-1111111111111111", OldValue: "old-name-3", NewValue: "new-name-3", RunID: "run-test2"})
 	if err != nil {
 		t.Errorf("Adding to history should not error: %v", err)
 	}
 
 	// Load history and verify structure
-	history, err := loadHistory()
+	history, err := loadHistory(path)
 	if err != nil {
 		t.Errorf("Loading history should not error: %v", err)
 	}
@@ -462,12 +1046,12 @@ func TestUndoFunctionality(t *testing.T) {
 	}
 
 	// Save and reload to test persistence
-	err = saveHistory(history)
+	err = saveHistory(path, history)
 	if err != nil {
 		t.Errorf("Saving history should not error: %v", err)
 	}
 
-	history, err = loadHistory()
+	history, err = loadHistory(path)
 	if err != nil {
 		t.Errorf("Loading history should not error: %v", err)
 	}
@@ -484,20 +1068,87 @@ func TestUndoFunctionality(t *testing.T) {
 	}
 }
 
+// TestFindLastRunByAccount tests that undo only considers the current account's runs
+func TestFindLastRunByAccount(t *testing.T) {
+	history := &TagHistory{Actions: []TagHistoryEntry{
+		{Account: "111111111111", Resource: "i-a1", RunID: "run-a1"},
+		{Account: "111111111111", Resource: "i-a2", RunID: "run-a2"},
+		{Account: "111111111111", Resource: "i-a3", RunID: "run-a2"},
+		{Account: "222222222222", Resource: "i-b1", RunID: "run-b1"},
+		{Account: "222222222222", Resource: "i-b2", RunID: "run-b2", Undone: true},
+	}}
+
+	runID, actions := findLastRun(history, "111111111111", time.Time{})
+	if runID != "run-a2" {
+		t.Errorf("Expected run-a2 for account 111111111111, got %q", runID)
+	}
+	if len(actions) != 2 || actions[0].Resource != "i-a2" || actions[1].Resource != "i-a3" {
+		t.Errorf("Expected actions i-a2 and i-a3, got %v", actions)
+	}
+
+	// The other account's latest run was undone, so its earlier run is next
+	runID, actions = findLastRun(history, "222222222222", time.Time{})
+	if runID != "run-b1" || len(actions) != 1 || actions[0].Resource != "i-b1" {
+		t.Errorf("Expected run-b1 with i-b1 for account 222222222222, got %q %v", runID, actions)
+	}
+
+	if runID, actions := findLastRun(history, "333333333333", time.Time{}); runID != "" || actions != nil {
+		t.Errorf("Unknown account should have no runs, got %q %v", runID, actions)
+	}
+}
+
+// TestHistorySince tests --since filtering for history listing and undo
+func TestHistorySince(t *testing.T) {
+	now := time.Now()
+	history := &TagHistory{Actions: []TagHistoryEntry{
+		{Account: "111111111111", Resource: "i-old", RunID: "run-old", Timestamp: now.Add(-48 * time.Hour).Format(time.RFC3339)},
+		{Account: "111111111111", Resource: "i-new", RunID: "run-new", Timestamp: now.Add(-1 * time.Hour).Format(time.RFC3339), Undone: true},
+		{Account: "111111111111", Resource: "i-bad", RunID: "run-bad", Timestamp: "not-a-time"},
+	}}
+
+	cutoff := now.Add(-24 * time.Hour)
+	filtered := filterHistorySince(history.Actions, cutoff)
+	if len(filtered) != 1 || filtered[0].Resource != "i-new" {
+		t.Errorf("Expected only i-new within 24h, got %v", filtered)
+	}
+	if len(filterHistorySince(history.Actions, time.Time{})) != 3 {
+		t.Error("A zero cutoff should keep every entry")
+	}
+
+	// Without a cutoff the older run is still undoable; with one it is out of range
+	if runID, _ := findLastRun(history, "111111111111", time.Time{}); runID != "run-bad" {
+		t.Errorf("Expected run-bad without a cutoff, got %q", runID)
+	}
+	if runID, _ := findLastRun(history, "111111111111", cutoff); runID != "" {
+		t.Errorf("Expected no undoable run within 24h, got %q", runID)
+	}
+
+	path := filepath.Join(t.TempDir(), ".quick-tag.yml")
+	if err := saveHistory(path, history); err != nil {
+		t.Fatalf("Saving history should not error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := listHistory(&buf, path, 24*time.Hour); err != nil {
+		t.Fatalf("listHistory should not error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "i-new") || strings.Contains(buf.String(), "i-old") {
+		t.Errorf("Expected only i-new in listing, got:\n%s", buf.String())
+	}
+}
+
 // TestUndoneFieldConsistency tests that the Undone field is always present in YAML output
 func TestUndoneFieldConsistency(t *testing.T) {
-	// Clean up any existing history file for clean testing
-	path := getHistoryFilePath()
-	os.Remove(path)
+	// Use a temp history file for clean testing
+	path := filepath.Join(t.TempDir(), ".quick-tag.yml")
 
 	// Add a test entry
-	err := addToHistory("123456789012", "i-1234567890abcdef0", "old-name", "new-name", "run-test123")
+	err := addToHistory(path, 0, TagHistoryEntry{Account: "123456789012", Region: "us-east-1", Resource: "i-1234567890abcdef0", OldValue: "old-name", NewValue: "new-name", RunID: "run-test123"})
 	if err != nil {
 		t.Errorf("Adding to history should not error: %v", err)
 	}
 
 	// Load the history and check the YAML output
-	history, err := loadHistory()
+	history, err := loadHistory(path)
 	if err != nil {
 		t.Errorf("Loading history should not error: %v", err)
 	}
@@ -512,7 +1163,7 @@ func TestUndoneFieldConsistency(t *testing.T) {
 	}
 
 	// Save the history and check that Undone field is present in YAML
-	err = saveHistory(history)
+	err = saveHistory(path, history)
 	if err != nil {
 		t.Errorf("Saving history should not error: %v", err)
 	}
@@ -527,10 +1178,13 @@ func TestUndoneFieldConsistency(t *testing.T) {
 	if !strings.Contains(yamlContent, "Undone: false") {
 		t.Errorf("YAML should contain 'Undone: false', got: %s", yamlContent)
 	}
+	if !strings.Contains(yamlContent, "Region: us-east-1") {
+		t.Errorf("YAML should contain 'Region: us-east-1', got: %s", yamlContent)
+	}
 
 	// Test that setting Undone to true is preserved
 	history.Actions[0].Undone = true
-	err = saveHistory(history)
+	err = saveHistory(path, history)
 	if err != nil {
 		t.Errorf("Saving history should not error: %v", err)
 	}
@@ -547,52 +1201,1562 @@ func TestUndoneFieldConsistency(t *testing.T) {
 	}
 }
 
-func TestExtractELBName(t *testing.T) {
-	tests := []struct {
-		description string
-		expected    string
-	}{
-		{
-			description: "ELB app/canvas-lb-sbx/35b9ec36d721abfe",
-			expected:    "canvas-lb-sbx",
-		},
-		{
-			description: "ELB net/my-lb/1234567890abcdef",
-			expected:    "my-lb",
-		},
-		{
-			description: "ELB app/production-api/abcdef1234567890",
-			expected:    "production-api",
-		},
-		{
-			description: "ELB net/test-lb-123/9876543210fedcba",
-			expected:    "test-lb-123",
-		},
-		{
-			description: "Not an ELB description",
-			expected:    "",
-		},
-		{
-			description: "ELB invalid-format",
-			expected:    "",
-		},
-		{
-			description: "",
-			expected:    "",
-		},
+// TestTruncateSuggestedName tests trimming long names to the tag value limit
+func TestTruncateSuggestedName(t *testing.T) {
+	// Short names are unchanged
+	if got := naming.Truncate("i-0abc", "(web)", " /dev/xvdf"); got != "i-0abc(web) /dev/xvdf" {
+		t.Errorf("Short name should be unchanged, got %q", got)
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.description, func(t *testing.T) {
-			result := extractELBName(tt.description)
-			if result != tt.expected {
-				t.Errorf("extractELBName(%q) = %q, want %q", tt.description, result, tt.expected)
-			}
-		})
+	// Long volume names keep the instance ID and mount point
+	longName := "(" + strings.Repeat("really-long-instance-name-", 20) + ")"
+	got := naming.Truncate("i-0abc", longName, " /dev/xvdf")
+	if utf8.RuneCountInString(got) != naming.MaxTagValueLength {
+		t.Errorf("Expected %d characters, got %d", naming.MaxTagValueLength, utf8.RuneCountInString(got))
+	}
+	if !strings.HasPrefix(got, "i-0abc(really-long") {
+		t.Errorf("Truncated name should keep the instance ID prefix, got %q", got)
+	}
+	if !strings.HasSuffix(got, "... /dev/xvdf") {
+		t.Errorf("Truncated name should keep the mount point suffix after an ellipsis, got %q", got)
 	}
-}
 
-// Helper function to check if a string contains a substring
+	// Long ENI names keep the -eni suffix
+	got = naming.Truncate("", strings.Repeat("x", 300), "-eni")
+	if utf8.RuneCountInString(got) != naming.MaxTagValueLength || !strings.HasSuffix(got, "...-eni") {
+		t.Errorf("Truncated ENI name should fit and keep -eni suffix, got %q", got)
+	}
+
+	// Multi-byte characters are counted as single characters
+	got = naming.Truncate("", strings.Repeat("é", 300), "")
+	if utf8.RuneCountInString(got) != naming.MaxTagValueLength || !utf8.ValidString(got) {
+		t.Errorf("Truncated name should be %d valid characters, got %d", naming.MaxTagValueLength, utf8.RuneCountInString(got))
+	}
+
+	// Oversized prefix and suffix fall back to cutting the end
+	got = naming.Truncate(strings.Repeat("p", 200), "middle", strings.Repeat("s", 200))
+	if utf8.RuneCountInString(got) != naming.MaxTagValueLength || !strings.HasSuffix(got, "...") {
+		t.Errorf("Fallback truncation should fit and end with an ellipsis, got %q", got)
+	}
+}
+
+// TestInstanceNameDetails tests the --verbose-names instance type and platform suffix
+func TestInstanceNameDetails(t *testing.T) {
+	tests := []struct {
+		instanceType    string
+		platformDetails string
+		expected        string
+	}{
+		{"t3.large", "Linux/UNIX", "t3.large, linux"},
+		{"m5.xlarge", "Windows with SQL Server Standard", "m5.xlarge, windows"},
+		{"c6g.medium", "Red Hat Enterprise Linux", "c6g.medium, red hat enterprise linux"},
+		{"t3.micro", "", "t3.micro"},
+		{"", "", ""},
+	}
+
+	for _, tt := range tests {
+		if got := naming.InstanceDetails(tt.instanceType, tt.platformDetails); got != tt.expected {
+			t.Errorf("naming.InstanceDetails(%q, %q) = %q, want %q", tt.instanceType, tt.platformDetails, got, tt.expected)
+		}
+	}
+}
+
+// TestVolumeNameDetails tests the --verbose-names volume type and size suffix
+func TestVolumeNameDetails(t *testing.T) {
+	tests := []struct {
+		volumeType string
+		size       int32
+		expected   string
+	}{
+		{"gp3", 100, "gp3-100GiB"},
+		{"io2", 0, "io2"},
+		{"", 8, "8GiB"},
+		{"", 0, ""},
+	}
+
+	for _, tt := range tests {
+		if got := naming.VolumeDetails(tt.volumeType, tt.size); got != tt.expected {
+			t.Errorf("naming.VolumeDetails(%q, %d) = %q, want %q", tt.volumeType, tt.size, got, tt.expected)
+		}
+	}
+
+	// Verbose unattached names are still recognized, and go stale once the volume is attached
+	if !naming.IsQuickTagCreatedName("unattached gp3-100GiB", "volume") {
+		t.Error("unattached gp3-100GiB should be recognized as a generated volume name")
+	}
+	if naming.IsQuickTagNameStillValid("unattached gp3-100GiB", "volume", "in-use", "/dev/xvdf") {
+		t.Error("unattached gp3-100GiB should be stale once the volume is in use")
+	}
+}
+
+// TestFormatTagSummary tests the per-type summary printed after tagging
+func TestFormatTagSummary(t *testing.T) {
+	tests := []struct {
+		counts   map[string]int
+		expected string
+	}{
+		{map[string]int{}, "Tagged 0 resources."},
+		{map[string]int{"instance": 12, "volume": 8, "eni": 3}, "Tagged 12 instances, 8 volumes, 3 ENIs (23 total)."},
+		{map[string]int{"instance": 1, "eni": 1}, "Tagged 1 instance, 1 ENI (2 total)."},
+		{map[string]int{"eip": 2, "volume": 1}, "Tagged 1 volume, 2 EIPs (3 total)."},
+		{map[string]int{"volume": 2, "zeta": 1, "alpha": 1}, "Tagged 2 volumes, 1 alpha, 1 zeta (4 total)."},
+	}
+
+	for _, tt := range tests {
+		if got := formatTagSummary(tt.counts); got != tt.expected {
+			t.Errorf("formatTagSummary(%v) = %q, want %q", tt.counts, got, tt.expected)
+		}
+	}
+}
+
+// TestWriteFileAtomic tests that history writes replace the file in one step
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".quick-tag.yml")
+
+	original := []byte("actions:\n    - Account: \"123456789012\"\n")
+	if err := writeFileAtomic(path, original, 0644); err != nil {
+		t.Fatalf("writeFileAtomic should not error: %v", err)
+	}
+
+	// Simulate a write that was interrupted before the rename: a truncated temp
+	// file is left behind next to the history file
+	partial := filepath.Join(dir, ".quick-tag.yml.12345.tmp")
+	if err := os.WriteFile(partial, original[:10], 0644); err != nil {
+		t.Fatalf("Writing partial temp file should not error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Reading history file should not error: %v", err)
+	}
+	if string(data) != string(original) {
+		t.Errorf("History file should be intact after interrupted write, got %q", string(data))
+	}
+
+	// A successful write replaces the contents and leaves no temp files of its own
+	updated := []byte("actions: []\n")
+	if err := writeFileAtomic(path, updated, 0644); err != nil {
+		t.Fatalf("writeFileAtomic should not error: %v", err)
+	}
+	data, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Reading history file should not error: %v", err)
+	}
+	if string(data) != string(updated) {
+		t.Errorf("Expected %q, got %q", string(updated), string(data))
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Reading temp dir should not error: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("Expected only the history file and the simulated partial file, got %d entries", len(entries))
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat should not error: %v", err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("Expected mode 0644, got %v", info.Mode().Perm())
+	}
+}
+
+// TestGetVolumeInstanceID tests reading the attached instance from a scanned volume
+func TestGetVolumeInstanceID(t *testing.T) {
+	instanceID := "i-1234567890abcdef0"
+	tests := []struct {
+		name     string
+		volume   types.Volume
+		expected string
+	}{
+		{"unattached", types.Volume{}, ""},
+		{"attached", types.Volume{Attachments: []types.VolumeAttachment{{InstanceId: &instanceID}}}, instanceID},
+		{"attachment without instance", types.Volume{Attachments: []types.VolumeAttachment{{}}}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getVolumeInstanceID(tt.volume); got != tt.expected {
+				t.Errorf("getVolumeInstanceID() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestAddressNaming tests Elastic IP state detection and stale quick-tag names
+func TestAddressNaming(t *testing.T) {
+	tests := []struct {
+		name     string
+		address  types.Address
+		expected string
+	}{
+		{"unassociated", types.Address{AllocationId: stringPtr("eipalloc-1")}, "unassociated"},
+		{"instance", types.Address{InstanceId: stringPtr("i-1"), AssociationId: stringPtr("eipassoc-1")}, "associated"},
+		{"eni", types.Address{NetworkInterfaceId: stringPtr("eni-1")}, "associated"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getAddressState(tt.address); got != tt.expected {
+				t.Errorf("getAddressState() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+
+	if !naming.IsQuickTagCreatedName("unassociated-eip", "eip") || naming.IsQuickTagCreatedName("web-eip", "eip") {
+		t.Error("Only unassociated-eip should be treated as a quick-tag created EIP name")
+	}
+	if !naming.IsQuickTagNameStillValid("unassociated-eip", "eip", "unassociated", "") {
+		t.Error("unassociated-eip should stay valid while the address is unassociated")
+	}
+	if naming.IsQuickTagNameStillValid("unassociated-eip", "eip", "associated", "") {
+		t.Error("unassociated-eip should be stale once the address is associated")
+	}
+}
+
+// fakeDescribeClient counts describe calls and returns canned images and instances
+type fakeDescribeClient struct {
+	imageCalls    int
+	instanceCalls int
+	images        map[string]string // AMI ID -> name
+	instances     map[string]string // instance ID -> Name tag
+	deregistered  []string          // AMI IDs that fail the whole DescribeImages call
+}
+
+func (f *fakeDescribeClient) DescribeImages(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error) {
+	f.imageCalls++
+	for _, id := range params.ImageIds {
+		if slices.Contains(f.deregistered, id) {
+			return nil, &smithy.GenericAPIError{Code: "InvalidAMIID.NotFound", Message: fmt.Sprintf("The image id '[%s]' does not exist", id)}
+		}
+	}
+	output := &ec2.DescribeImagesOutput{}
+	for _, id := range params.ImageIds {
+		if name, ok := f.images[id]; ok {
+			output.Images = append(output.Images, types.Image{ImageId: stringPtr(id), Name: stringPtr(name)})
+		}
+	}
+	return output, nil
+}
+
+func (f *fakeDescribeClient) DescribeInstances(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+	f.instanceCalls++
+	reservation := types.Reservation{}
+	for _, id := range params.InstanceIds {
+		if name, ok := f.instances[id]; ok {
+			reservation.Instances = append(reservation.Instances, types.Instance{
+				InstanceId: stringPtr(id),
+				Tags:       []types.Tag{{Key: stringPtr("Name"), Value: stringPtr(name)}},
+			})
+		}
+	}
+	return &ec2.DescribeInstancesOutput{Reservations: []types.Reservation{reservation}}, nil
+}
+
+// TestDescribeImagesDropsInvalidAMIs tests that one deregistered AMI doesn't fail the lookup
+// for the rest of its batch
+func TestDescribeImagesDropsInvalidAMIs(t *testing.T) {
+	fake := &fakeDescribeClient{
+		images:       map[string]string{"ami-1": "web-ami", "ami-2": "db-ami"},
+		deregistered: []string{"ami-gone", "ami-private"},
+	}
+	config := &Config{Describe: newDescribeCache(fake)}
+
+	amiNames, err := getAMINames(context.Background(), config, map[string]bool{"ami-1": true, "ami-2": true, "ami-gone": true, "ami-private": true})
+	if err != nil {
+		t.Fatalf("getAMINames should not error: %v", err)
+	}
+	expected := map[string]string{"ami-1": "web-ami", "ami-2": "db-ami"}
+	if !maps.Equal(amiNames, expected) {
+		t.Errorf("getAMINames() = %v, want %v", amiNames, expected)
+	}
+	if fake.imageCalls != 3 {
+		t.Errorf("Expected 3 DescribeImages calls (two retries), got %d", fake.imageCalls)
+	}
+
+	// Other errors still fail the lookup
+	denied := &smithy.GenericAPIError{Code: "UnauthorizedOperation", Message: "ami-1"}
+	if invalid := invalidAMIIDs(denied, []string{"ami-1"}); invalid != nil {
+		t.Errorf("invalidAMIIDs(UnauthorizedOperation) = %v, want nil", invalid)
+	}
+}
+
+// TestDescribeCache tests that repeated lookups are served from the cache
+func TestDescribeCache(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeDescribeClient{
+		images:    map[string]string{"ami-1": "web-ami", "ami-2": "db-ami"},
+		instances: map[string]string{"i-1": "web", "i-2": "db"},
+	}
+	config := &Config{Describe: newDescribeCache(fake)}
+
+	amiNames, err := getAMINames(ctx, config, map[string]bool{"ami-1": true, "ami-missing": true})
+	if err != nil {
+		t.Fatalf("getAMINames should not error: %v", err)
+	}
+	if amiNames["ami-1"] != "web-ami" {
+		t.Errorf("Expected ami-1 name web-ami, got %q", amiNames["ami-1"])
+	}
+	if _, ok := amiNames["ami-missing"]; ok {
+		t.Error("Missing AMIs should not have a name")
+	}
+
+	// Cached and known-missing AMIs are not looked up again
+	if _, err := getAMINames(ctx, config, map[string]bool{"ami-1": true, "ami-missing": true, "ami-2": true}); err != nil {
+		t.Fatalf("getAMINames should not error: %v", err)
+	}
+	if fake.imageCalls != 2 {
+		t.Errorf("Expected 2 DescribeImages calls, got %d", fake.imageCalls)
+	}
+	if _, err := getAMINames(ctx, config, map[string]bool{"ami-1": true, "ami-2": true}); err != nil {
+		t.Fatalf("getAMINames should not error: %v", err)
+	}
+	if fake.imageCalls != 2 {
+		t.Errorf("Fully cached lookup should not call DescribeImages, got %d calls", fake.imageCalls)
+	}
+
+	// Instances recorded by the scan are shared with volume and ENI lookups
+	config.Describe.addInstances(types.Instance{
+		InstanceId: stringPtr("i-scanned"),
+		Tags:       []types.Tag{{Key: stringPtr("Name"), Value: stringPtr("scanned")}},
+	})
+	instanceNames, err := getInstanceNames(ctx, config, map[string]bool{"i-scanned": true})
+	if err != nil {
+		t.Fatalf("getInstanceNames should not error: %v", err)
+	}
+	if instanceNames["i-scanned"] != "scanned" || fake.instanceCalls != 0 {
+		t.Errorf("Scanned instance should be served from cache, got %q with %d calls", instanceNames["i-scanned"], fake.instanceCalls)
+	}
+
+	if _, err := getInstanceNames(ctx, config, map[string]bool{"i-1": true}); err != nil {
+		t.Fatalf("getInstanceNames should not error: %v", err)
+	}
+	attachmentNames, err := getAttachmentNames(ctx, config, map[string]bool{"i-1": true, "i-scanned": true})
+	if err != nil {
+		t.Fatalf("getAttachmentNames should not error: %v", err)
+	}
+	if attachmentNames["i-1"] != "web" || attachmentNames["i-scanned"] != "scanned" {
+		t.Errorf("Unexpected attachment names: %v", attachmentNames)
+	}
+	if fake.instanceCalls != 1 {
+		t.Errorf("Expected 1 DescribeInstances call, got %d", fake.instanceCalls)
+	}
+}
+
+func TestExtractELBName(t *testing.T) {
+	tests := []struct {
+		description string
+		expected    string
+	}{
+		{
+			description: "ELB app/canvas-lb-sbx/35b9ec36d721abfe",
+			expected:    "canvas-lb-sbx",
+		},
+		{
+			description: "ELB net/my-lb/1234567890abcdef",
+			expected:    "my-lb",
+		},
+		{
+			description: "ELB app/production-api/abcdef1234567890",
+			expected:    "production-api",
+		},
+		{
+			description: "ELB net/test-lb-123/9876543210fedcba",
+			expected:    "test-lb-123",
+		},
+		{
+			description: "Not an ELB description",
+			expected:    "",
+		},
+		{
+			description: "ELB invalid-format",
+			expected:    "",
+		},
+		{
+			description: "",
+			expected:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			result := naming.ExtractELBName(tt.description)
+			if result != tt.expected {
+				t.Errorf("naming.ExtractELBName(%q) = %q, want %q", tt.description, result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestEmptyNameTag tests that an empty Name tag is distinguished from a missing one
+func TestEmptyNameTag(t *testing.T) {
+	tests := []struct {
+		name       string
+		tags       []types.Tag
+		wantName   string
+		wantHasTag bool
+	}{
+		{"no tags", nil, "", false},
+		{"other tags", []types.Tag{{Key: stringPtr("Team"), Value: stringPtr("web")}}, "", false},
+		{"empty name", []types.Tag{{Key: stringPtr("Name"), Value: stringPtr("")}}, "", true},
+		{"nil name value", []types.Tag{{Key: stringPtr("Name")}}, "", true},
+		{"named", []types.Tag{{Key: stringPtr("Name"), Value: stringPtr("web")}}, "web", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotName, gotHasTag := getNameTag(tt.tags)
+			if gotName != tt.wantName || gotHasTag != tt.wantHasTag {
+				t.Errorf("getNameTag() = (%q, %v), want (%q, %v)", gotName, gotHasTag, tt.wantName, tt.wantHasTag)
+			}
+		})
+	}
+
+	labels := map[string]*ResourceInfo{
+		"untagged": {ID: "i-1"},
+		"empty":    {ID: "i-2", EmptyName: true},
+		"web":      {ID: "i-3", Name: "web"},
+	}
+	for expected, resource := range labels {
+		if got := currentNameLabel(resource); got != expected {
+			t.Errorf("currentNameLabel(%s) = %q, want %q", resource.ID, got, expected)
+		}
+	}
+}
+
+// TestPrintResourceTable tests the --output table report
+func TestPrintResourceTable(t *testing.T) {
+	resources := []*ResourceInfo{
+		{ID: "i-1234567890abcdef0", Type: "instance", Name: "", SuggestedName: "web-ami", State: "running"},
+		{ID: "vol-1", Type: "volume", Name: "unattached", SuggestedName: "i-1 /dev/xvdf", State: "in-use"},
+	}
+
+	var buf bytes.Buffer
+	printResourceTable(&buf, resources)
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+
+	if len(lines) != 4 {
+		t.Fatalf("Expected header, separator, and 2 rows, got %d lines:\n%s", len(lines), buf.String())
+	}
+	if !strings.Contains(lines[0], "Type") || !strings.Contains(lines[0], "Suggested") {
+		t.Errorf("Header row missing columns: %q", lines[0])
+	}
+	if !strings.Contains(lines[2], "untagged") || !strings.Contains(lines[2], "web-ami") {
+		t.Errorf("Instance row missing values: %q", lines[2])
+	}
+
+	// Columns line up regardless of ID length
+	for _, line := range lines[1:] {
+		if strings.Index(line, "|") != strings.Index(lines[0], "|") {
+			t.Errorf("Column borders are not aligned:\n%s", buf.String())
+			break
+		}
+	}
+}
+
+// TestReadResourceIDs tests parsing an --ids-from list into IDs by type
+func TestReadResourceIDs(t *testing.T) {
+	input := `# instances to name
+i-1234567890abcdef0
+vol-0abc, vol-0def
+
+eni-0123  i-1234567890abcdef0 # duplicate
+eipalloc-0abc
+`
+	targetIDs, err := readResourceIDs(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("readResourceIDs should not error: %v", err)
+	}
+
+	expected := map[string][]string{
+		"instance": {"i-1234567890abcdef0"},
+		"volume":   {"vol-0abc", "vol-0def"},
+		"eni":      {"eni-0123"},
+		"eip":      {"eipalloc-0abc"},
+	}
+	for resourceType, ids := range expected {
+		if strings.Join(targetIDs[resourceType], ",") != strings.Join(ids, ",") {
+			t.Errorf("%s IDs = %v, want %v", resourceType, targetIDs[resourceType], ids)
+		}
+	}
+
+	config := &Config{TargetIDs: map[string][]string{"volume": {"vol-0abc"}}}
+	if shouldScanType(config, "instance") || !shouldScanType(config, "volume") {
+		t.Error("Only types with listed IDs should be scanned")
+	}
+	if !shouldScanType(&Config{}, "eni") {
+		t.Error("All types should be scanned without --ids-from")
+	}
+
+	if _, err := readResourceIDs(strings.NewReader("sg-123\n")); err == nil {
+		t.Error("Unsupported resource IDs should error")
+	}
+	if _, err := readResourceIDs(strings.NewReader("# nothing here\n\n")); err == nil {
+		t.Error("An empty ID list should error")
+	}
+}
+
+// TestFilterTagFlag tests parsing repeatable --filter-tag flags into describe filters
+func TestFilterTagFlag(t *testing.T) {
+	var pairs keyValueList
+	for _, value := range []string{"Team=platform", "Env=prod=blue", "Owner="} {
+		if err := pairs.Set(value); err != nil {
+			t.Errorf("Set(%q) should not error: %v", value, err)
+		}
+	}
+	for _, value := range []string{"Team", "=platform"} {
+		if err := pairs.Set(value); err == nil {
+			t.Errorf("Set(%q) should error", value)
+		}
+	}
+
+	if pairs.String() != "Team=platform,Env=prod=blue,Owner=" {
+		t.Errorf("Unexpected String() = %q", pairs.String())
+	}
+
+	filters := tagFilters(pairs)
+	if len(filters) != 3 {
+		t.Fatalf("Expected 3 filters, got %d", len(filters))
+	}
+	if *filters[0].Name != "tag:Team" || filters[0].Values[0] != "platform" {
+		t.Errorf("Unexpected first filter: %s=%v", *filters[0].Name, filters[0].Values)
+	}
+	if *filters[1].Name != "tag:Env" || filters[1].Values[0] != "prod=blue" {
+		t.Errorf("Values should keep everything after the first '=', got %s=%v", *filters[1].Name, filters[1].Values)
+	}
+	if tagFilters(nil) != nil {
+		t.Error("No --filter-tag flags should produce no filters")
+	}
+}
+
+// TestWrapPermissionError tests friendly messages for missing IAM permissions
+func TestWrapPermissionError(t *testing.T) {
+	denied := &smithy.GenericAPIError{Code: "UnauthorizedOperation", Message: "You are not authorized to perform this operation."}
+	err := wrapPermissionError(fmt.Errorf("operation error EC2: DescribeVolumes: %w", denied), "ec2:DescribeVolumes", "EBS volumes")
+	if !strings.Contains(err.Error(), "missing permission ec2:DescribeVolumes for EBS volumes") {
+		t.Errorf("Expected friendly permission message, got: %v", err)
+	}
+
+	accessDenied := &smithy.GenericAPIError{Code: "AccessDenied"}
+	if err := wrapPermissionError(accessDenied, "ec2:CreateTags", "volumes"); !strings.Contains(err.Error(), "ec2:CreateTags") {
+		t.Errorf("AccessDenied should be wrapped, got: %v", err)
+	}
+
+	// Other errors pass through unchanged
+	throttled := &smithy.GenericAPIError{Code: "RequestLimitExceeded"}
+	if err := wrapPermissionError(throttled, "ec2:DescribeVolumes", "EBS volumes"); err != throttled {
+		t.Errorf("Non-permission API errors should be unchanged, got: %v", err)
+	}
+	plain := fmt.Errorf("network unreachable")
+	if err := wrapPermissionError(plain, "ec2:DescribeVolumes", "EBS volumes"); err != plain {
+		t.Errorf("Non-API errors should be unchanged, got: %v", err)
+	}
+}
+
+// newTestEC2Client returns an EC2 client that sends requests to a local test server
+func newTestEC2Client(t *testing.T, handler http.HandlerFunc) *ec2.Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return ec2.New(ec2.Options{
+		Region:           "us-east-1",
+		BaseEndpoint:     aws.String(server.URL),
+		Credentials:      aws.AnonymousCredentials{},
+		RetryMaxAttempts: 1,
+	})
+}
+
+// writeCreateTagsResponse writes a successful EC2 CreateTags response
+func writeCreateTagsResponse(w http.ResponseWriter) {
+	fmt.Fprint(w, `<CreateTagsResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><return>true</return></CreateTagsResponse>`)
+}
+
+// writeDeleteTagsResponse writes a successful EC2 DeleteTags response
+func writeDeleteTagsResponse(w http.ResponseWriter) {
+	fmt.Fprint(w, `<DeleteTagsResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><return>true</return></DeleteTagsResponse>`)
+}
+
+// TestApplyTagsConcurrently tests tagging with a worker pool and history recording
+func TestApplyTagsConcurrently(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			seen := maxInFlight.Load()
+			if current <= seen || maxInFlight.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		body, _ := io.ReadAll(r.Body)
+		values, _ := url.ParseQuery(string(body))
+		if values.Get("ResourceId.1") == "i-fail" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `<Response><Errors><Error><Code>InvalidInstanceID.NotFound</Code><Message>not found</Message></Error></Errors><RequestID>test</RequestID></Response>`)
+			return
+		}
+		writeCreateTagsResponse(w)
+	})
+
+	quiet = true
+	defer func() { quiet = false }()
+
+	historyPath := filepath.Join(t.TempDir(), ".quick-tag.yml")
+	config := &Config{EC2Client: client, Region: "us-east-1", HistoryFile: historyPath, Concurrency: 4, AssumeYes: true}
+
+	var resources []*ResourceInfo
+	for i := range 12 {
+		resources = append(resources, &ResourceInfo{ID: fmt.Sprintf("i-%02d", i), Type: "instance", SuggestedName: fmt.Sprintf("web-%d", i)})
+	}
+
+	applied, err := applyTags(context.Background(), config, resources, "123456789012", "run-1", true)
+	if err != nil {
+		t.Fatalf("applyTags should not error: %v", err)
+	}
+	if applied != len(resources) {
+		t.Errorf("Expected %d tags applied, got %d", len(resources), applied)
+	}
+	if maxInFlight.Load() < 2 || maxInFlight.Load() > 4 {
+		t.Errorf("Expected between 2 and 4 concurrent CreateTags calls, got %d", maxInFlight.Load())
+	}
+
+	history, err := loadHistory(historyPath)
+	if err != nil {
+		t.Fatalf("loadHistory should not error: %v", err)
+	}
+	if len(history.Actions) != len(resources) {
+		t.Errorf("Expected %d history entries, got %d", len(resources), len(history.Actions))
+	}
+
+	// A failure stops dispatching new work and is returned
+	failing := append([]*ResourceInfo{{ID: "i-fail", Type: "instance", SuggestedName: "bad"}}, resources...)
+	applied, err = applyTags(context.Background(), config, failing, "123456789012", "run-2", true)
+	if err == nil || !strings.Contains(err.Error(), "i-fail") {
+		t.Errorf("Expected an error for i-fail, got %v", err)
+	}
+	if applied >= len(failing) {
+		t.Errorf("Expected fewer than %d tags applied after a failure, got %d", len(failing), applied)
+	}
+}
+
+// TestHistoryMigration tests reading a v0 history file (no version field) in the current schema
+func TestHistoryMigration(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".quick-tag.yml")
+	v0 := `actions:
+- Account: "123456789012"
+  Resource: i-1
+  OldValue: ""
+  NewValue: web-1
+  Timestamp: "2025-01-26T10:00:00Z"
+  RunID: run-old
+  Undone: false
+`
+	if err := os.WriteFile(path, []byte(v0), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := loadHistory(path)
+	if err != nil {
+		t.Fatalf("loadHistory should migrate a v0 file: %v", err)
+	}
+	if history.Version != historyVersion || len(history.Actions) != 1 || history.Actions[0].RunID != "run-old" || history.Actions[0].Region != "" {
+		t.Errorf("Unexpected migrated history: %+v", history)
+	}
+
+	// Loading alone leaves the file as it was; the next save writes the current version
+	if data, _ := os.ReadFile(path); string(data) != v0 {
+		t.Errorf("loadHistory should not rewrite the file, got:\n%s", data)
+	}
+	if err := saveHistory(path, history); err != nil {
+		t.Fatalf("saveHistory should not error: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), fmt.Sprintf("version: %d", historyVersion)) {
+		t.Errorf("Expected the file rewritten with the current version, got:\n%s", data)
+	}
+	history, _ = loadHistory(path)
+	if runID, actions := findLastRun(history, "123456789012", time.Time{}); runID != "run-old" || len(actions) != 1 {
+		t.Errorf("Expected run-old still undoable after migration, got %q with %d actions", runID, len(actions))
+	}
+
+	// An empty v0 file gets an empty actions list
+	empty := &TagHistory{}
+	if migrated, err := migrateHistory(empty); err != nil || !migrated || empty.Actions == nil {
+		t.Errorf("migrateHistory(empty v0) = %v, %v, actions %v", migrated, err, empty.Actions)
+	}
+
+	// Files from a newer quick-tag are refused rather than rewritten
+	newer := filepath.Join(dir, "newer.yml")
+	os.WriteFile(newer, []byte(fmt.Sprintf("version: %d\nactions: []\n", historyVersion+1)), 0644)
+	if _, err := loadHistory(newer); err == nil {
+		t.Error("Expected an error loading a history file from a newer version")
+	}
+}
+
+// TestResume tests that --resume skips resources already tagged in the run being resumed
+func TestResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".quick-tag.yml")
+	if _, err := resumedResources(path, "run-1", "123456789012"); err == nil {
+		t.Error("Expected an error resuming a run with no history")
+	}
+
+	for _, entry := range []struct{ account, resource, runID string }{
+		{"123456789012", "i-1", "run-1"},
+		{"123456789012", "i-2", "run-1"},
+		{"123456789012", "i-3", "run-2"},
+		{"999999999999", "i-4", "run-1"},
+	} {
+		if err := addToHistory(path, 0, TagHistoryEntry{Account: entry.account, Region: "us-east-1", Resource: entry.resource, NewValue: "web", RunID: entry.runID}); err != nil {
+			t.Fatalf("addToHistory should not error: %v", err)
+		}
+	}
+
+	done, err := resumedResources(path, "run-1", "123456789012")
+	if err != nil {
+		t.Fatalf("resumedResources should not error: %v", err)
+	}
+	if !maps.Equal(done, map[string]bool{"i-1": true, "i-2": true}) {
+		t.Errorf("resumedResources() = %v, want i-1 and i-2", done)
+	}
+
+	resources := []*ResourceInfo{{ID: "i-1"}, {ID: "i-3"}, {ID: "i-4"}}
+	var ids []string
+	for _, resource := range skipResumed(resources, done) {
+		ids = append(ids, resource.ID)
+	}
+	if !slices.Equal(ids, []string{"i-3", "i-4"}) {
+		t.Errorf("skipResumed() kept %v, want [i-3 i-4]", ids)
+	}
+}
+
+// TestApplyAllRemaining tests answering 'a' at a per-resource prompt to apply it and the rest
+func TestApplyAllRemaining(t *testing.T) {
+	var mu sync.Mutex
+	var tagged []string
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		values, _ := url.ParseQuery(string(body))
+		mu.Lock()
+		tagged = append(tagged, values.Get("ResourceId.1"))
+		mu.Unlock()
+		writeCreateTagsResponse(w)
+	})
+
+	quiet = true
+	defer func() { quiet = false }()
+	original := os.Stdin
+	defer func() { os.Stdin = original }()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe should not error: %v", err)
+	}
+	w.WriteString("a\n")
+	w.Close()
+	os.Stdin = r
+	defer r.Close()
+
+	config := &Config{EC2Client: client, Region: "us-east-1", HistoryFile: filepath.Join(t.TempDir(), ".quick-tag.yml"), Concurrency: 2}
+	resources := []*ResourceInfo{
+		{ID: "i-1", Type: "instance", Name: "old", SuggestedName: "web-1"}, // Confirmed at the prompt, so replaced
+		{ID: "i-2", Type: "instance", SuggestedName: "web-2"},
+		{ID: "i-3", Type: "instance", Name: "keep", SuggestedName: "web-3"}, // Not confirmed, so not overwritten
+	}
+	applied, err := applyTags(context.Background(), config, resources, "123456789012", "run-1", false)
+	if err != nil {
+		t.Fatalf("applyTags should not error: %v", err)
+	}
+	slices.Sort(tagged)
+	if applied != 2 || !slices.Equal(tagged, []string{"i-1", "i-2"}) {
+		t.Errorf("Expected i-1 and i-2 tagged after 'a', got %d: %v", applied, tagged)
+	}
+}
+
+// TestConfirmOver tests when auto-applying stops for the bulk confirmation
+func TestConfirmOver(t *testing.T) {
+	tests := []struct {
+		assumeYes   bool
+		confirmOver int
+		count       int
+		want        bool
+	}{
+		{false, 0, 1, true},   // 'all' always confirms by default
+		{true, 0, 100, false}, // --yes never does
+		{false, 10, 10, false},
+		{false, 10, 11, true},
+		{true, 10, 11, true}, // The threshold applies to --yes too
+	}
+	for _, tt := range tests {
+		config := &Config{AssumeYes: tt.assumeYes, ConfirmOver: tt.confirmOver}
+		if got := config.needsBulkConfirm(tt.count); got != tt.want {
+			t.Errorf("needsBulkConfirm(%d) with AssumeYes=%v, ConfirmOver=%d = %v, want %v", tt.count, tt.assumeYes, tt.confirmOver, got, tt.want)
+		}
+	}
+
+	// --yes --confirm-over may still ask, so it needs a terminal unless only listing
+	if !willPrompt(true, 10, "", false, false) || willPrompt(true, 10, "json", false, false) {
+		t.Error("--yes --confirm-over should need a terminal when tagging, and only then")
+	}
+}
+
+// TestCallEstimate tests counting tagging calls and the throttle warning
+func TestCallEstimate(t *testing.T) {
+	noColor = true
+	defer func() { noColor = false }()
+
+	resources := []*ResourceInfo{
+		{ID: "vol-1", Type: "volume", SuggestedName: "unattached"},
+		{ID: "vol-2", Type: "volume", SuggestedName: "unattached"},
+		{ID: "i-1", Type: "instance", SuggestedName: "web"},
+		{ID: "11111111-1111-1111-1111-111111111111", Type: "kms-key", SuggestedName: "app"},
+		{ID: "22222222-2222-2222-2222-222222222222", Type: "kms-key", SuggestedName: "app"},
+	}
+	if calls := estimateTagCalls(resources); calls != 4 {
+		t.Errorf("estimateTagCalls() = %d, want 4 (2 CreateTags, 2 TagResource)", calls)
+	}
+
+	var buf bytes.Buffer
+	printCallEstimate(&buf, resources, 1, 10)
+	if buf.String() != "ℹ️ Applying these would take 4 tagging API calls for 5 resources.\n" {
+		t.Errorf("Unexpected estimate under the threshold: %q", buf.String())
+	}
+
+	buf.Reset()
+	printCallEstimate(&buf, resources, 4, 3)
+	if !strings.Contains(buf.String(), "more than 3 calls") || !strings.Contains(buf.String(), "lower --concurrency (currently 4)") {
+		t.Errorf("Expected a throttle warning suggesting lower concurrency, got %q", buf.String())
+	}
+}
+
+// TestNoHistory tests that --no-history tags without writing the history file
+func TestNoHistory(t *testing.T) {
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		writeCreateTagsResponse(w)
+	})
+
+	quiet = true
+	defer func() { quiet = false }()
+
+	historyPath := filepath.Join(t.TempDir(), ".quick-tag.yml")
+	config := &Config{EC2Client: client, Region: "us-east-1", HistoryFile: historyPath, AssumeYes: true, NoHistory: true}
+	resources := []*ResourceInfo{{ID: "i-1", Type: "instance", SuggestedName: "web"}}
+	applied, err := applyTags(context.Background(), config, resources, "123456789012", "run-1", true)
+	if err != nil || applied != 1 {
+		t.Fatalf("applyTags() = %d, %v, want 1, nil", applied, err)
+	}
+	if _, err := os.Stat(historyPath); !os.IsNotExist(err) {
+		t.Errorf("Expected no history file with NoHistory, got %v", err)
+	}
+}
+
+// TestScanThenBatchTags tests that shared names from a scan still batch into one CreateTags
+// call, while generic names are told apart and tagged one resource at a time
+func TestScanThenBatchTags(t *testing.T) {
+	var createTags atomic.Int32
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("Action") {
+		case "DescribeVpcs":
+			fmt.Fprint(w, `<DescribeVpcsResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><vpcSet>`+
+				`<item><vpcId>vpc-1</vpcId><cidrBlock>10.0.0.0/16</cidrBlock><state>available</state></item>`+
+				`<item><vpcId>vpc-2</vpcId><cidrBlock>10.0.0.0/16</cidrBlock><state>available</state></item>`+
+				`<item><vpcId>vpc-3</vpcId><cidrBlock>10.0.0.0/16</cidrBlock><state>available</state></item>`+
+				`</vpcSet></DescribeVpcsResponse>`)
+		case "DescribeInternetGateways":
+			fmt.Fprint(w, `<DescribeInternetGatewaysResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><internetGatewaySet>`+
+				`<item><internetGatewayId>igw-1</internetGatewayId></item>`+
+				`<item><internetGatewayId>igw-2</internetGatewayId></item>`+
+				`</internetGatewaySet></DescribeInternetGatewaysResponse>`)
+		case "CreateTags":
+			createTags.Add(1)
+			writeCreateTagsResponse(w)
+		}
+	})
+
+	quiet = true
+	defer func() { quiet = false }()
+
+	config := &Config{
+		EC2Client:   client,
+		Region:      "us-east-1",
+		TargetIDs:   map[string][]string{"vpc": {"vpc-1", "vpc-2", "vpc-3"}, "igw": {"igw-1", "igw-2"}},
+		HistoryFile: filepath.Join(t.TempDir(), ".quick-tag.yml"),
+		Concurrency: 1,
+		AssumeYes:   true,
+	}
+	resources, err := findUntaggedResources(context.Background(), config)
+	if err != nil {
+		t.Fatalf("findUntaggedResources should not error: %v", err)
+	}
+	if len(resources) != 5 {
+		t.Fatalf("Expected 3 VPCs and 2 internet gateways, got %+v", resources)
+	}
+	if calls := estimateTagCalls(resources); calls != 3 {
+		t.Errorf("estimateTagCalls() = %d, want 3", calls)
+	}
+
+	applied, err := applyTags(context.Background(), config, resources, "123456789012", "run-1", true)
+	if err != nil {
+		t.Fatalf("applyTags should not error: %v", err)
+	}
+	if applied != 5 || createTags.Load() != 3 {
+		t.Errorf("Expected 5 tags in 3 CreateTags calls (one for the VPCs, one per gateway), got %d tags in %d calls", applied, createTags.Load())
+	}
+}
+
+// TestBatchedCreateTags tests grouping resources that share a Name into fewer CreateTags calls
+func TestBatchedCreateTags(t *testing.T) {
+	resources := []*ResourceInfo{
+		{ID: "vol-1", Type: "volume", SuggestedName: "unattached"},
+		{ID: "i-1", Type: "instance", SuggestedName: "web"},
+		{ID: "vol-2", Type: "volume", SuggestedName: "unattached"},
+		{ID: "vol-3", Type: "volume", SuggestedName: "unattached"},
+	}
+
+	batches := groupByTagValue(resources, 2)
+	var got []string
+	for _, batch := range batches {
+		var ids []string
+		for _, resource := range batch {
+			ids = append(ids, resource.ID)
+		}
+		got = append(got, strings.Join(ids, ","))
+	}
+	if strings.Join(got, " ") != "vol-1,vol-2 i-1 vol-3" {
+		t.Errorf("groupByTagValue() = %v, want [vol-1,vol-2 i-1 vol-3]", got)
+	}
+
+	// A shared Name never puts two types, and so two taggers, in one batch
+	mixed := groupByTagValue([]*ResourceInfo{
+		{ID: "vpc-1", Type: "vpc", SuggestedName: "shared"},
+		{ID: "key-1", Type: "kms-key", SuggestedName: "shared"},
+		{ID: "vpc-2", Type: "vpc", SuggestedName: "shared"},
+	}, maxCreateTagsResources)
+	if len(mixed) != 2 || len(mixed[0]) != 2 || mixed[1][0].Type != "kms-key" {
+		t.Errorf("Expected the VPCs and the KMS key in separate batches, got %d batches", len(mixed))
+	}
+
+	var calls atomic.Int32
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		writeCreateTagsResponse(w)
+	})
+
+	quiet = true
+	defer func() { quiet = false }()
+
+	historyPath := filepath.Join(t.TempDir(), ".quick-tag.yml")
+	config := &Config{EC2Client: client, Region: "us-east-1", HistoryFile: historyPath, Concurrency: 1, AssumeYes: true}
+	applied, err := applyTags(context.Background(), config, resources, "123456789012", "run-1", true)
+	if err != nil {
+		t.Fatalf("applyTags should not error: %v", err)
+	}
+	if applied != len(resources) || calls.Load() != 2 {
+		t.Errorf("Expected %d tags in 2 CreateTags calls, got %d tags in %d calls", len(resources), applied, calls.Load())
+	}
+
+	// History is still recorded per resource
+	history, err := loadHistory(historyPath)
+	if err != nil {
+		t.Fatalf("loadHistory should not error: %v", err)
+	}
+	if len(history.Actions) != len(resources) {
+		t.Errorf("Expected %d history entries, got %d", len(resources), len(history.Actions))
+	}
+}
+
+// TestExtraTags tests that --extra-tag tags are sent with Name and recorded for undo
+func TestExtraTags(t *testing.T) {
+	var pairs keyValueList
+	pairs.Set("ManagedBy=quick_tag")
+	pairs.Set("Run=2025-01-01")
+	tags, err := extraTags(pairs)
+	if err != nil {
+		t.Fatalf("extraTags should not error: %v", err)
+	}
+	if strings.Join(tagKeys(tags), ",") != "ManagedBy,Run" {
+		t.Errorf("tagKeys() = %v, want [ManagedBy Run]", tagKeys(tags))
+	}
+
+	var nameOverride keyValueList
+	nameOverride.Set("Name=web")
+	if _, err := extraTags(nameOverride); err == nil {
+		t.Error("--extra-tag Name=... should error")
+	}
+
+	var sent url.Values
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		sent, _ = url.ParseQuery(string(body))
+		writeCreateTagsResponse(w)
+	})
+
+	quiet = true
+	defer func() { quiet = false }()
+
+	historyPath := filepath.Join(t.TempDir(), ".quick-tag.yml")
+	config := &Config{EC2Client: client, Region: "us-east-1", HistoryFile: historyPath, AssumeYes: true, ExtraTags: tags}
+	resources := []*ResourceInfo{{ID: "i-1", Type: "instance", SuggestedName: "web", Tags: map[string]string{"ManagedBy": "terraform", "Team": "ops"}}}
+	if _, err := applyTags(context.Background(), config, resources, "123456789012", "run-1", true); err != nil {
+		t.Fatalf("applyTags should not error: %v", err)
+	}
+
+	if sent.Get("Tag.1.Key") != "Name" || sent.Get("Tag.2.Key") != "ManagedBy" || sent.Get("Tag.3.Value") != "2025-01-01" {
+		t.Errorf("CreateTags did not include the extra tags: %v", sent)
+	}
+
+	history, err := loadHistory(historyPath)
+	if err != nil {
+		t.Fatalf("loadHistory should not error: %v", err)
+	}
+	if len(history.Actions) != 1 || strings.Join(history.Actions[0].ExtraTags, ",") != "ManagedBy,Run" {
+		t.Errorf("Expected extra tag keys in history, got %+v", history.Actions)
+	}
+
+	// Only the overwritten value is kept, so undo restores ManagedBy and removes Run
+	if prior := history.Actions[0].PriorTags; len(prior) != 1 || prior["ManagedBy"] != "terraform" {
+		t.Errorf("Expected the overwritten ManagedBy value in history, got %v", prior)
+	}
+	restore, remove := revertExtraTags(history.Actions[0])
+	if len(restore) != 1 || aws.ToString(restore[0].Key) != "ManagedBy" || aws.ToString(restore[0].Value) != "terraform" || !slices.Equal(remove, []string{"Run"}) {
+		t.Errorf("revertExtraTags() = %v, %v, want ManagedBy=terraform restored and Run removed", restore, remove)
+	}
+}
+
+// TestTagPlan tests saving a plan and re-verifying it before apply
+func TestTagPlan(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plan.json")
+	resources := []*ResourceInfo{
+		{ID: "i-1", Type: "instance", SuggestedName: "instance-ami-web"},
+		{ID: "vol-1", Type: "volume", Name: "unattached", SuggestedName: "web-/dev/xvda"},
+		{ID: "i-2", Type: "instance", SuggestedName: "instance-ami-db"},
+	}
+	if err := savePlan(path, newTagPlan("123456789012", "eu-west-1", resources)); err != nil {
+		t.Fatalf("savePlan should not error: %v", err)
+	}
+
+	plan, err := loadPlan(path)
+	if err != nil {
+		t.Fatalf("loadPlan should not error: %v", err)
+	}
+	if plan.Account != "123456789012" || plan.Region != "eu-west-1" || len(plan.Resources) != 3 || plan.Resources[1].Name != "unattached" {
+		t.Errorf("Plan did not round-trip: %+v", plan)
+	}
+
+	for _, content := range []string{`{`, `{"TagKey": "Owner", "Region": "us-east-1"}`, `{"TagKey": "Name", "Region": "us-east-1"}`} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Writing plan should not error: %v", err)
+		}
+		if _, err := loadPlan(path); err == nil {
+			t.Errorf("loadPlan(%s) should error", content)
+		}
+	}
+
+	// i-2 was named by someone else after the plan was written
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<DescribeTagsResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><tagSet>`+
+			`<item><resourceId>vol-1</resourceId><resourceType>volume</resourceType><key>Name</key><value>unattached</value></item>`+
+			`<item><resourceId>i-2</resourceId><resourceType>instance</resourceType><key>Name</key><value>db-primary</value></item>`+
+			`</tagSet></DescribeTagsResponse>`)
+	})
+	ready, changed, err := verifyPlan(context.Background(), &Config{EC2Client: client}, plan)
+	if err != nil {
+		t.Fatalf("verifyPlan should not error: %v", err)
+	}
+	if len(ready) != 2 || ready[0].ID != "i-1" || ready[1].ID != "vol-1" {
+		t.Errorf("Expected i-1 and vol-1 ready, got %v", ready)
+	}
+	if len(changed) != 1 || changed[0].ID != "i-2" {
+		t.Errorf("Expected i-2 changed, got %v", changed)
+	}
+}
+
+// fakeTaggingClient returns canned GetResources pages
+type fakeTaggingClient struct {
+	pages [][]taggingtypes.ResourceTagMapping
+}
+
+func (f *fakeTaggingClient) GetResources(ctx context.Context, params *resourcegroupstaggingapi.GetResourcesInput, optFns ...func(*resourcegroupstaggingapi.Options)) (*resourcegroupstaggingapi.GetResourcesOutput, error) {
+	page := 0
+	if params.PaginationToken != nil {
+		page, _ = strconv.Atoi(*params.PaginationToken)
+	}
+	output := &resourcegroupstaggingapi.GetResourcesOutput{ResourceTagMappingList: f.pages[page]}
+	if page+1 < len(f.pages) {
+		output.PaginationToken = aws.String(strconv.Itoa(page + 1))
+	}
+	return output, nil
+}
+
+// TestTaggingAPICandidates tests finding Name-less resources with the Resource Groups Tagging API
+func TestTaggingAPICandidates(t *testing.T) {
+	arn := func(resource string) *string {
+		return aws.String("arn:aws:ec2:us-east-1:123456789012:" + resource)
+	}
+	name := func(value string) []taggingtypes.Tag {
+		return []taggingtypes.Tag{{Key: aws.String("Name"), Value: aws.String(value)}}
+	}
+	client := &fakeTaggingClient{pages: [][]taggingtypes.ResourceTagMapping{
+		{
+			{ResourceARN: arn("instance/i-1"), Tags: []taggingtypes.Tag{{Key: aws.String("Team"), Value: aws.String("web")}}},
+			{ResourceARN: arn("instance/i-2"), Tags: name("web-server")},
+			{ResourceARN: arn("volume/vol-1"), Tags: name("unattached")},
+		},
+		{
+			{ResourceARN: arn("network-interface/eni-1"), Tags: name("")},
+			{ResourceARN: arn("elastic-ip/eipalloc-1"), Tags: name("custom-eip")},
+			{ResourceARN: arn("security-group/sg-1")},
+		},
+	}}
+
+	candidates, err := findTaggingAPICandidates(context.Background(), client, nil, "", "", nil, 0)
+	if err != nil {
+		t.Fatalf("findTaggingAPICandidates should not error: %v", err)
+	}
+	expected := map[string][]string{
+		"instance": {"i-1"},
+		"volume":   {"vol-1"},
+		"eni":      {"eni-1"},
+	}
+	if len(candidates) != len(expected) {
+		t.Errorf("Expected candidates %v, got %v", expected, candidates)
+	}
+	for resourceType, ids := range expected {
+		if strings.Join(candidates[resourceType], ",") != strings.Join(ids, ",") {
+			t.Errorf("%s candidates = %v, want %v", resourceType, candidates[resourceType], ids)
+		}
+	}
+
+	// --ids-from still limits the candidates
+	candidates, err = findTaggingAPICandidates(context.Background(), client, map[string][]string{"volume": {"vol-1"}}, "", "", nil, 0)
+	if err != nil {
+		t.Fatalf("findTaggingAPICandidates should not error: %v", err)
+	}
+	if len(candidates) != 1 || len(candidates["volume"]) != 1 {
+		t.Errorf("Expected only vol-1 with --ids-from, got %v", candidates)
+	}
+
+	if _, _, ok := parseEC2ResourceARN("arn:aws:s3:::bucket"); ok {
+		t.Error("Non-EC2 ARNs should not parse")
+	}
+	if validateEngine("tagging-api") != nil || validateEngine("ec2") != nil || validateEngine("config") == nil {
+		t.Error("validateEngine should accept only ec2 and tagging-api")
+	}
+}
+
+// TestTagPrefix tests prefixing suggested names and recognizing prefixed generated names
+func TestTagPrefix(t *testing.T) {
+	resources := []*ResourceInfo{
+		{ID: "i-1", SuggestedName: "web-ami"},
+		{ID: "vol-1", SuggestedName: "platform/web-ami-/dev/xvda"}, // Derived from a prefixed instance name
+	}
+	applyTagPrefix(resources, "platform/")
+	if resources[0].SuggestedName != "platform/web-ami" || resources[1].SuggestedName != "platform/web-ami-/dev/xvda" {
+		t.Errorf("Unexpected prefixed names: %q, %q", resources[0].SuggestedName, resources[1].SuggestedName)
+	}
+
+	// A prefixed generated name is still recognized on re-runs
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<DescribeAddressesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><addressesSet>`+
+			`<item><allocationId>eipalloc-1</allocationId><publicIp>192.0.2.1</publicIp>`+
+			`<tagSet><item><key>Name</key><value>platform/unassociated-eip</value></item></tagSet></item>`+
+			`<item><allocationId>eipalloc-2</allocationId><publicIp>192.0.2.2</publicIp><instanceId>i-1</instanceId><associationId>eipassoc-2</associationId>`+
+			`<tagSet><item><key>Name</key><value>platform/unassociated-eip</value></item></tagSet></item>`+
+			`</addressesSet></DescribeAddressesResponse>`)
+	})
+	config := &Config{
+		EC2Client: client,
+		Describe:  newDescribeCache(&fakeDescribeClient{instances: map[string]string{"i-1": "platform/web"}}),
+		TagPrefix: "platform/",
+	}
+	addresses, err := findUntaggedAddresses(context.Background(), config)
+	if err != nil {
+		t.Fatalf("findUntaggedAddresses should not error: %v", err)
+	}
+	if len(addresses) != 1 || addresses[0].ID != "eipalloc-2" || addresses[0].SuggestedName != "platform/web-eip" {
+		t.Errorf("Expected only the now-associated eipalloc-2 named platform/web-eip, got %+v", addresses)
+	}
+}
+
+// TestTagSuffix tests suffixing suggested names and recognizing suffixed generated names
+func TestTagSuffix(t *testing.T) {
+	resources := []*ResourceInfo{
+		{ID: "i-1", SuggestedName: "web-ami"},
+		{ID: "i-2", SuggestedName: "db-ami-prod"},
+	}
+	applyTagSuffix(resources, "-prod")
+	if resources[0].SuggestedName != "web-ami-prod" || resources[1].SuggestedName != "db-ami-prod" {
+		t.Errorf("Unexpected suffixed names: %q, %q", resources[0].SuggestedName, resources[1].SuggestedName)
+	}
+
+	config := &Config{TagPrefix: "platform/", TagSuffix: "-prod"}
+	if got := config.baseName("platform/unassociated-eip-prod", "eipalloc-1"); got != "unassociated-eip" || !naming.IsQuickTagCreatedName(got, "eip") {
+		t.Errorf("baseName() = %q, want a recognizable generated name", got)
+	}
+	if !taggingAPINeedsName("eip", []taggingtypes.Tag{{Key: aws.String("Name"), Value: aws.String("unassociated-eip-prod")}}, "", "-prod", nil) {
+		t.Error("taggingAPINeedsName should recognize a suffixed generated name")
+	}
+}
+
+// TestNameTemplate tests building names from other tags with --name-template
+func TestNameTemplate(t *testing.T) {
+	tags := map[string]string{"Service": "billing", "Environment": "prod", "Team": ""}
+	tests := []struct {
+		template    string
+		wantName    string
+		wantMissing []string
+	}{
+		{"${Service}-${Environment}", "billing-prod", nil},
+		{"${Service}-${Owner}", "billing-missing-Owner", []string{"Owner"}},
+		{"${Team}/${Team}", "missing-Team/missing-Team", []string{"Team"}},
+		{"static", "static", nil},
+	}
+	for _, tt := range tests {
+		name, missing := naming.ExpandTemplate(tt.template, tags)
+		if name != tt.wantName || !slices.Equal(missing, tt.wantMissing) {
+			t.Errorf("ExpandTemplate(%q) = %q, %v, want %q, %v", tt.template, name, missing, tt.wantName, tt.wantMissing)
+		}
+	}
+
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<DescribeVpcsResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><vpcSet>`+
+			`<item><vpcId>vpc-1</vpcId><cidrBlock>10.0.0.0/16</cidrBlock><state>available</state>`+
+			`<tagSet><item><key>Service</key><value>billing</value></item><item><key>Environment</key><value>prod</value></item></tagSet></item>`+
+			`</vpcSet></DescribeVpcsResponse>`)
+	})
+	vpcs, err := findUntaggedVPCs(context.Background(), &Config{EC2Client: client})
+	if err != nil {
+		t.Fatalf("findUntaggedVPCs should not error: %v", err)
+	}
+	applyNameTemplate(vpcs, "${Service}-${Environment}-vpc")
+	if len(vpcs) != 1 || vpcs[0].SuggestedName != "billing-prod-vpc" || vpcs[0].Source != "name template" {
+		t.Errorf("Expected vpc-1 named billing-prod-vpc from the template, got %+v", vpcs)
+	}
+}
+
+// TestNameFromTag tests copying a source tag into the suggested name with --name-from-tag
+func TestNameFromTag(t *testing.T) {
+	key := "aws:cloudformation:logical-id"
+	resources := []*ResourceInfo{
+		{ID: "vpc-1", Type: "vpc", SuggestedName: "10.0.0.0/16", Source: "CIDR block", Tags: map[string]string{key: "AppVpc", "Service": "billing"}},
+		{ID: "vpc-2", Type: "vpc", SuggestedName: "10.1.0.0/16", Source: "CIDR block", Tags: map[string]string{"Service": "search"}},
+		{ID: "vpc-3", Type: "vpc", SuggestedName: "10.2.0.0/16", Source: "CIDR block", Tags: map[string]string{key: ""}},
+	}
+
+	// The source tag wins over --name-template; resources without it keep the template name
+	applyNameTemplate(resources, "${Service}")
+	applyNameFromTag(resources, key)
+	applyNameFromTag(resources, "")
+
+	expected := []struct{ name, source string }{
+		{"AppVpc", "tag " + key},
+		{"search", "name template"},
+		{"missing-Service", "name template"},
+	}
+	for i, want := range expected {
+		if resources[i].SuggestedName != want.name || resources[i].Source != want.source {
+			t.Errorf("%s: got %q from %q, want %q from %q", resources[i].ID, resources[i].SuggestedName, resources[i].Source, want.name, want.source)
+		}
+	}
+}
+
+// TestConsoleURL tests building AWS console links for --show-links
+func TestConsoleURL(t *testing.T) {
+	tests := []struct {
+		resourceType, id, region, expected string
+	}{
+		{"instance", "i-0abc", "us-east-1", "https://us-east-1.console.aws.amazon.com/ec2/home?region=us-east-1#InstanceDetails:instanceId=i-0abc"},
+		{"subnet", "subnet-1", "eu-west-1", "https://eu-west-1.console.aws.amazon.com/vpcconsole/home?region=eu-west-1#SubnetDetails:subnetId=subnet-1"},
+		{"kms-key", "1234abcd", "us-west-2", "https://us-west-2.console.aws.amazon.com/kms/home?region=us-west-2#/kms/keys/1234abcd"},
+		{"volume", "vol-1", "cn-north-1", "https://cn-north-1.console.amazonaws.cn/ec2/home?region=cn-north-1#VolumeDetails:volumeId=vol-1"},
+		{"eni", "eni-1", "us-gov-west-1", "https://us-gov-west-1.console.amazonaws-us-gov.com/ec2/home?region=us-gov-west-1#NetworkInterface:networkInterfaceId=eni-1"},
+		{"lambda", "fn-1", "us-east-1", ""},
+		{"instance", "i-0abc", "", ""},
+	}
+	for _, tt := range tests {
+		if got := consoleURL(tt.resourceType, tt.id, tt.region); got != tt.expected {
+			t.Errorf("consoleURL(%q, %q, %q) = %q, want %q", tt.resourceType, tt.id, tt.region, got, tt.expected)
+		}
+	}
+	for _, resourceType := range resourceTypeOrder {
+		if consoleURL(resourceType, "id-1", "us-east-1") == "" {
+			t.Errorf("No console link for resource type %s", resourceType)
+		}
+	}
+
+	noColor = true
+	if got := hyperlink("text", "https://example.com"); got != "text" {
+		t.Errorf("Expected plain text without color, got %q", got)
+	}
+	noColor = false
+	if got := hyperlink("text", "https://example.com"); got != "\x1b]8;;https://example.com\x1b\\text\x1b]8;;\x1b\\" {
+		t.Errorf("Expected an OSC 8 hyperlink, got %q", got)
+	}
+}
+
+// TestCallTimeout tests that --call-timeout retries slow calls and reports them distinctly from cancellation
+func TestCallTimeout(t *testing.T) {
+	quiet = true
+	defer func() { quiet = false }()
+
+	var calls, slowCalls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= slowCalls.Load() {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(300 * time.Millisecond):
+			}
+			return
+		}
+		fmt.Fprint(w, `<DescribeVpcsResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><vpcSet></vpcSet></DescribeVpcsResponse>`)
+	}))
+	defer server.Close()
+
+	cfg := aws.Config{}
+	withCallTimeout(&cfg, 50*time.Millisecond)
+	client := ec2.New(ec2.Options{
+		Region:           "us-east-1",
+		BaseEndpoint:     aws.String(server.URL),
+		Credentials:      aws.AnonymousCredentials{},
+		RetryMaxAttempts: 1,
+		APIOptions:       cfg.APIOptions,
+	})
+
+	// Two slow responses are retried through
+	slowCalls.Store(callTimeoutRetries)
+	if _, err := client.DescribeVpcs(context.Background(), &ec2.DescribeVpcsInput{}); err != nil {
+		t.Errorf("Expected the call to succeed after retrying, got %v", err)
+	}
+	if calls.Load() != callTimeoutRetries+1 {
+		t.Errorf("Expected %d attempts, got %d", callTimeoutRetries+1, calls.Load())
+	}
+
+	// A call that never answers in time is classified as a timeout
+	calls.Store(0)
+	slowCalls.Store(100)
+	_, err := client.DescribeVpcs(context.Background(), &ec2.DescribeVpcsInput{})
+	if classifyAWSError(err) != awsErrorTimeout || !strings.Contains(fmt.Sprint(err), "DescribeVpcs") {
+		t.Errorf("Expected a DescribeVpcs call timeout, got %v", err)
+	}
+	if calls.Load() != callTimeoutRetries+1 {
+		t.Errorf("Expected %d attempts before giving up, got %d", callTimeoutRetries+1, calls.Load())
+	}
+
+	// Cancelling the run isn't a call timeout and isn't retried
+	calls.Store(0)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = client.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{})
+	if err == nil || errors.Is(err, errCallTimeout) || calls.Load() != 1 {
+		t.Errorf("Expected a single cancelled call, got %v after %d attempts", err, calls.Load())
+	}
+
+	// Without --call-timeout nothing is added
+	cfg = aws.Config{}
+	withCallTimeout(&cfg, 0)
+	if len(cfg.APIOptions) != 0 {
+		t.Error("Expected no middleware without --call-timeout")
+	}
+}
+
+// TestWriteOutputFile tests that --output-file creates missing parent directories and replaces old results
+func TestWriteOutputFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reports", "nightly", "inventory.json")
+	for _, resources := range [][]*ResourceInfo{
+		{{ID: "i-1", Type: "instance", SuggestedName: "web"}, {ID: "vol-1", Type: "volume", SuggestedName: "unattached"}},
+		{{ID: "i-2", Type: "instance", SuggestedName: "api"}},
+	} {
+		if err := writeOutputFile(path, func(w io.Writer) error {
+			return writeResources(w, resources, "json")
+		}); err != nil {
+			t.Fatalf("writeOutputFile should not error: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var document struct{ Resources []*ResourceInfo }
+	if err := json.Unmarshal(data, &document); err != nil {
+		t.Fatalf("Output file isn't valid JSON: %v", err)
+	}
+	if len(document.Resources) != 1 || document.Resources[0].ID != "i-2" {
+		t.Errorf("Expected only the latest results in the file, got %+v", document.Resources)
+	}
+
+	// A file where a directory should be can't be written under, and the failure leaves
+	// no stray temp files behind
+	blocked := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(blocked, nil, 0644)
+	if err := writeOutputFile(filepath.Join(blocked, "out.json"), func(w io.Writer) error { return nil }); err == nil {
+		t.Error("Expected an error when the parent path is a file")
+	}
+
+	// A scan that fails partway through streaming keeps the previous export and its error
+	scanErr := errors.New("scan failed")
+	if err := writeOutputFile(path, func(w io.Writer) error {
+		fmt.Fprintln(w, `{"ID":"i-3"}`)
+		return scanErr
+	}); err != scanErr {
+		t.Errorf("Expected the scan's own error, got %v", err)
+	}
+	if after, _ := os.ReadFile(path); !bytes.Equal(after, data) {
+		t.Errorf("Expected the previous results kept after a failed scan, got:\n%s", after)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("Expected only the output file in its directory, got %d entries", len(entries))
+	}
+}
+
+// TestSortResources tests ordering the listing with --sort and --reverse
+func TestSortResources(t *testing.T) {
+	newResources := func() []*ResourceInfo {
+		return []*ResourceInfo{
+			{ID: "vol-2", Type: "volume", State: "available", Name: "old", SuggestedName: "unattached"},
+			{ID: "i-2", Type: "instance", State: "stopped", SuggestedName: "web"},
+			{ID: "i-1", Type: "instance", State: "running", Name: "zeta", SuggestedName: "api"},
+			{ID: "vol-1", Type: "volume", State: "in-use", SuggestedName: "i-1(api) /dev/xvda"},
+		}
+	}
+	tests := []struct {
+		by       string
+		reverse  bool
+		expected []string
+	}{
+		{"", false, []string{"i-1", "i-2", "vol-1", "vol-2"}},
+		{"type", true, []string{"vol-2", "vol-1", "i-2", "i-1"}},
+		{"id", false, []string{"i-1", "i-2", "vol-1", "vol-2"}},
+		{"state", false, []string{"vol-2", "vol-1", "i-1", "i-2"}},
+		{"name", false, []string{"i-2", "vol-1", "vol-2", "i-1"}},
+		{"suggested", false, []string{"i-1", "vol-1", "vol-2", "i-2"}},
+		{"suggested", true, []string{"i-2", "vol-2", "vol-1", "i-1"}},
+	}
+	for _, tt := range tests {
+		resources := newResources()
+		sortResources(resources, tt.by, tt.reverse)
+		var ids []string
+		for _, resource := range resources {
+			ids = append(ids, resource.ID)
+		}
+		if !slices.Equal(ids, tt.expected) {
+			t.Errorf("sortResources(%q, reverse=%v) = %v, want %v", tt.by, tt.reverse, ids, tt.expected)
+		}
+	}
+}
+
+// TestDisambiguateNames tests that generic suggested names get the resource ID appended,
+// while other names are kept even when shared
+func TestDisambiguateNames(t *testing.T) {
+	resources := []*ResourceInfo{
+		{ID: "vol-1", Type: "volume", SuggestedName: "unattached"},
+		{ID: "vol-2", Type: "volume", SuggestedName: "unattached gp3-100GiB"},
+		{ID: "vol-3", Type: "volume", SuggestedName: "i-1(web) /dev/xvdf"},
+		{ID: "vol-4", Type: "volume", SuggestedName: "i-1(web) /dev/xvdf"},
+		{ID: "igw-1", Type: "igw", SuggestedName: "detached-igw"},
+	}
+	disambiguateNames(resources)
+
+	var names []string
+	for _, resource := range resources {
+		names = append(names, resource.SuggestedName)
+	}
+	expected := []string{"unattached-vol-1", "unattached gp3-100GiB-vol-2", "i-1(web) /dev/xvdf", "i-1(web) /dev/xvdf", "detached-igw-igw-1"}
+	if !slices.Equal(names, expected) {
+		t.Errorf("disambiguateNames() = %q, want %q", names, expected)
+	}
+
+	// Re-runs see through the ID to the generated name, which still matches the volume's state
+	base := (&Config{}).baseName("unattached-vol-1", "vol-1")
+	if base != "unattached" || !naming.IsQuickTagCreatedName(base, "volume") || !naming.GeneratedNameMatchesState(base, "volume", "available", "unattached") {
+		t.Errorf("baseName(unattached-vol-1) = %q, want a still-valid generated name", base)
+	}
+}
+
+// TestRunStats tests --stats counters and report
+func TestRunStats(t *testing.T) {
+	var nilStats *runStats
+	nilStats.recordScan("DescribeInstances", "instance", 1, 1, 1) // Must not panic
+	nilStats.recordCreateTags()
+
+	stats := newRunStats()
+	stats.recordScan("DescribeInstances", "instance", 3, 250, 4)
+	stats.recordScan("DescribeNetworkInterfaces", "eni", 1, 12, 2)
+
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		writeCreateTagsResponse(w)
+	})
+	quiet = true
+	defer func() { quiet = false }()
+
+	config := &Config{EC2Client: client, HistoryFile: filepath.Join(t.TempDir(), ".quick-tag.yml"), AssumeYes: true, Stats: stats}
+	resources := []*ResourceInfo{
+		{ID: "eni-1", Type: "eni", SuggestedName: "unattached-eni"},
+		{ID: "eni-2", Type: "eni", SuggestedName: "unattached-eni"},
+		{ID: "i-1", Type: "instance", SuggestedName: "web"},
+	}
+	if _, err := applyTags(context.Background(), config, resources, "123456789012", "run-1", true); err != nil {
+		t.Fatalf("applyTags should not error: %v", err)
+	}
+
+	noColor = true
+	defer func() { noColor = false }()
+	var buf bytes.Buffer
+	printStats(&buf, stats)
+	for _, expected := range []string{
+		"instances: 250 examined, 4 needed a Name",
+		"ENIs: 12 examined, 2 needed a Name",
+		"Pages fetched: DescribeInstances 3, DescribeNetworkInterfaces 1",
+		"CreateTags calls: 2",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("Expected %q in stats output:\n%s", expected, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "volumes:") {
+		t.Errorf("Unscanned types should not be reported:\n%s", buf.String())
+	}
+}
+
+// TestLimitResources tests truncating the resource list with --limit
+func TestLimitResources(t *testing.T) {
+	resources := []*ResourceInfo{
+		{ID: "i-1", Type: "instance"},
+		{ID: "i-2", Type: "instance"},
+		{ID: "vol-1", Type: "volume"},
+	}
+
+	tests := []struct {
+		limit    int
+		expected int
+	}{
+		{0, 3},
+		{-1, 3},
+		{2, 2},
+		{3, 3},
+		{10, 3},
+	}
+
+	for _, tt := range tests {
+		result := limitResources(resources, tt.limit)
+		if len(result) != tt.expected {
+			t.Errorf("limitResources(%d) returned %d resources, want %d", tt.limit, len(result), tt.expected)
+		}
+	}
+
+	// Truncation should keep the first resources in sorted order
+	result := limitResources(resources, 2)
+	if result[0].ID != "i-1" || result[1].ID != "i-2" {
+		t.Errorf("limitResources should keep the first resources, got %s, %s", result[0].ID, result[1].ID)
+	}
+}
+
+// Helper function to check if a string contains a substring
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr ||
 		(len(s) > len(substr) &&
@@ -601,12 +2765,1831 @@ func contains(s, substr string) bool {
 				containsInMiddle(s, substr))))
 }
 
-// Helper function to check if substr is contained in the middle of s
-func containsInMiddle(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
+// Helper function to check if substr is contained in the middle of s
+func containsInMiddle(s, substr string) bool {
+	for i := 0; i <= len(s)-len(substr); i++ {
+		if s[i:i+len(substr)] == substr {
+			return true
+		}
+	}
+	return false
+}
+
+// TestInstanceWithoutImageID tests that instances with no AMI reference are named from their ID
+func TestInstanceWithoutImageID(t *testing.T) {
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<DescribeInstancesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><reservationSet>`+
+			`<item><reservationId>r-1</reservationId><instancesSet>`+
+			`<item><instanceId>i-noami</instanceId><instanceState><code>16</code><name>running</name></instanceState></item>`+
+			`<item><instanceId>i-web</instanceId><imageId>ami-1</imageId><instanceState><code>16</code><name>running</name></instanceState></item>`+
+			`</instancesSet></item></reservationSet></DescribeInstancesResponse>`)
+	})
+	config := &Config{
+		EC2Client: client,
+		Describe:  newDescribeCache(&fakeDescribeClient{images: map[string]string{"ami-1": "web-ami"}}),
+	}
+	instances, err := findUntaggedInstances(context.Background(), config)
+	if err != nil {
+		t.Fatalf("findUntaggedInstances should not error: %v", err)
+	}
+
+	names := make(map[string]string)
+	for _, instance := range instances {
+		names[instance.ID] = instance.SuggestedName
+	}
+	if names["i-noami"] != "instance-i-noami" || names["i-web"] != "web-ami" {
+		t.Errorf("Unexpected suggested names: %v", names)
+	}
+	if !naming.IsQuickTagCreatedName("instance-i-noami", "instance") {
+		t.Error("instance-i-noami should be recognized as a generated name")
+	}
+}
+
+// TestMissingResourceIDs tests that resources missing their ID are skipped instead of panicking
+func TestMissingResourceIDs(t *testing.T) {
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("Action") {
+		case "DescribeInstances":
+			fmt.Fprint(w, `<DescribeInstancesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><reservationSet>`+
+				`<item><reservationId>r-1</reservationId><instancesSet>`+
+				`<item><imageId>ami-1</imageId></item>`+
+				`</instancesSet></item></reservationSet></DescribeInstancesResponse>`)
+		case "DescribeVolumes":
+			fmt.Fprint(w, `<DescribeVolumesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><volumeSet>`+
+				`<item><status>available</status></item>`+
+				`<item><volumeId>vol-1</volumeId><status>available</status></item>`+
+				`</volumeSet></DescribeVolumesResponse>`)
+		}
+	})
+	config := &Config{EC2Client: client, Describe: newDescribeCache(&fakeDescribeClient{})}
+
+	instances, err := findUntaggedInstances(context.Background(), config)
+	if err != nil || len(instances) != 0 {
+		t.Errorf("findUntaggedInstances() = %v, %v, want no instances", instances, err)
+	}
+	volumes, err := findUntaggedVolumes(context.Background(), config)
+	if err != nil || len(volumes) != 1 || volumes[0].ID != "vol-1" {
+		t.Errorf("findUntaggedVolumes() = %v, %v, want only vol-1", volumes, err)
+	}
+}
+
+// TestIncludeTerminated tests that terminated instances are only scanned with --include-terminated
+func TestIncludeTerminated(t *testing.T) {
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<DescribeInstancesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><reservationSet>`+
+			`<item><reservationId>r-1</reservationId><instancesSet>`+
+			`<item><instanceId>i-gone</instanceId><imageId>ami-1</imageId><instanceState><code>48</code><name>terminated</name></instanceState></item>`+
+			`</instancesSet></item></reservationSet></DescribeInstancesResponse>`)
+	})
+
+	for _, include := range []bool{false, true} {
+		config := &Config{
+			EC2Client:         client,
+			Describe:          newDescribeCache(&fakeDescribeClient{images: map[string]string{"ami-1": "web-ami"}}),
+			IncludeTerminated: include,
+		}
+		instances, err := findUntaggedInstances(context.Background(), config)
+		if err != nil {
+			t.Fatalf("findUntaggedInstances should not error: %v", err)
+		}
+		want := 0
+		if include {
+			want = 1
+		}
+		if len(instances) != want {
+			t.Errorf("IncludeTerminated=%v: got %d instances, want %d", include, len(instances), want)
+		}
+	}
+}
+
+// TestReadLinePromptTimeout tests that unanswered prompts give up after --prompt-timeout
+// and that prompts refuse to wait when stdin isn't a terminal
+func TestReadLinePromptTimeout(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	promptTimeout = 10 * time.Millisecond
+	defer func() { promptTimeout = 0 }()
+	defer promptAbandoned.Store(false)
+	if _, err := readLine(context.Background(), bufio.NewReader(pr)); !errors.Is(err, errPromptTimeout) {
+		t.Errorf("Expected errPromptTimeout, got %v", err)
+	}
+
+	stdinIsTTY = false
+	defer func() { stdinIsTTY = true }()
+	if _, err := readLine(context.Background(), bufio.NewReader(strings.NewReader("yes\n"))); !errors.Is(err, errStdinNotTerminal) {
+		t.Errorf("Expected errStdinNotTerminal, got %v", err)
+	}
+}
+
+// TestRunReport tests that --report-file records each resource's outcome
+func TestRunReport(t *testing.T) {
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		values, _ := url.ParseQuery(string(body))
+		if values.Get("ResourceId.1") == "i-fail" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `<Response><Errors><Error><Code>InvalidInstanceID.NotFound</Code><Message>not found</Message></Error></Errors><RequestID>test</RequestID></Response>`)
+			return
+		}
+		writeCreateTagsResponse(w)
+	})
+	quiet = true
+	defer func() { quiet = false }()
+
+	report := newRunReport("run-1", "123456789012", "us-east-1")
+	config := &Config{EC2Client: client, Region: "us-east-1", HistoryFile: filepath.Join(t.TempDir(), ".quick-tag.yml"), AssumeYes: true, Overwrite: true, Report: report}
+	resources := []*ResourceInfo{
+		{ID: "i-ok", Type: "instance", Name: "instance-ami-old", SuggestedName: "web"},
+		{ID: "i-fail", Type: "instance", SuggestedName: "db"},
+	}
+	if _, err := applyTags(context.Background(), config, resources, "123456789012", "run-1", true); err == nil {
+		t.Fatal("applyTags should return the i-fail error")
+	}
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := saveRunReport(path, report); err != nil {
+		t.Fatalf("saveRunReport should not error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Reading report should not error: %v", err)
+	}
+	var saved RunReport
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatalf("Report should be valid JSON: %v", err)
+	}
+	if saved.RunID != "run-1" || saved.Account != "123456789012" || saved.FinishedAt == "" || len(saved.Resources) != 2 {
+		t.Fatalf("Unexpected report: run %q, account %q, finished %q, %d resources", saved.RunID, saved.Account, saved.FinishedAt, len(saved.Resources))
+	}
+	outcomes := make(map[string]ReportEntry)
+	for _, entry := range saved.Resources {
+		outcomes[entry.ID] = entry
+	}
+	if ok := outcomes["i-ok"]; !ok.Success || ok.OldName != "instance-ami-old" || ok.NewName != "web" {
+		t.Errorf("Unexpected i-ok entry: %+v", ok)
+	}
+	if failed := outcomes["i-fail"]; failed.Success || failed.Outcome != "failed" || failed.Error == "" {
+		t.Errorf("Unexpected i-fail entry: %+v", failed)
+	}
+	if ok := outcomes["i-ok"]; ok.Outcome != "tagged" {
+		t.Errorf("Expected i-ok tagged, got %+v", ok)
+	}
+
+	// Names kept because of a missing --overwrite are reported as skipped
+	report = newRunReport("run-2", "123456789012", "us-east-1")
+	config.Overwrite, config.Report = false, report
+	resources = []*ResourceInfo{
+		{ID: "i-named", Type: "instance", Name: "legacy", SuggestedName: "web"},
+		{ID: "i-new", Type: "instance", SuggestedName: "api"},
+	}
+	if _, err := applyTags(context.Background(), config, resources, "123456789012", "run-2", true); err != nil {
+		t.Fatalf("applyTags should not error: %v", err)
+	}
+	outcomes = make(map[string]ReportEntry)
+	for _, entry := range report.Resources {
+		outcomes[entry.ID] = entry
+	}
+	if skipped := outcomes["i-named"]; skipped.Outcome != "skipped" || skipped.Success || skipped.OldName != "legacy" {
+		t.Errorf("Expected i-named reported as skipped, got %+v", skipped)
+	}
+	if tagged := outcomes["i-new"]; tagged.Outcome != "tagged" || !tagged.Success {
+		t.Errorf("Expected i-new reported as tagged, got %+v", tagged)
+	}
+
+	// A run that tags nothing still writes a report with no resources
+	empty := filepath.Join(t.TempDir(), "empty.json")
+	if err := saveRunReport(empty, newRunReport("run-3", "123456789012", "us-east-1")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(empty); !strings.Contains(string(data), `"Resources": []`) {
+		t.Errorf("Expected an empty Resources list, got:\n%s", data)
+	}
+}
+
+// TestPrintUndoPreview tests that the undo preview aligns resources into columns
+func TestPrintUndoPreview(t *testing.T) {
+	noColor = true
+	defer func() { noColor = false }()
+
+	var buf bytes.Buffer
+	printUndoPreview(&buf, "run-1", []TagHistoryEntry{
+		{Resource: "i-1", NewValue: "web", OldValue: ""},
+		{Resource: "vol-0123456789", Region: "eu-west-1", NewValue: "unattached", OldValue: "data", ExtraTags: []string{"Owner", "Team"}, PriorTags: map[string]string{"Team": "ops"}},
+	})
+
+	expected := "🔄 Undoing run run-1 (2 actions):\n" +
+		"  i-1:                        'web' -> ''\n" +
+		"  vol-0123456789 (eu-west-1): 'unattached' -> 'data'\n" +
+		"    and restore tags: Team=ops\n" +
+		"    and remove tags: Owner\n"
+	if buf.String() != expected {
+		t.Errorf("printUndoPreview() =\n%s\nwant\n%s", buf.String(), expected)
+	}
+}
+
+// TestUndoForce tests that --undo --force reverts without reading a confirmation
+func TestUndoForce(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+
+	var reverted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		values, _ := url.ParseQuery(string(body))
+		switch values.Get("Action") {
+		case "GetCallerIdentity":
+			fmt.Fprint(w, `<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><GetCallerIdentityResult>`+
+				`<Arn>arn:aws:iam::123456789012:user/test</Arn><UserId>AIDTEST</UserId><Account>123456789012</Account>`+
+				`</GetCallerIdentityResult><ResponseMetadata><RequestId>test</RequestId></ResponseMetadata></GetCallerIdentityResponse>`)
+		case "CreateTags":
+			reverted = append(reverted, values.Get("ResourceId.1")+"="+values.Get("Tag.1.Value"))
+			writeCreateTagsResponse(w)
+		}
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), ".quick-tag.yml")
+	if err := addToHistory(path, 0, TagHistoryEntry{Account: "123456789012", Region: "us-east-1", Resource: "i-1", OldValue: "old-name", NewValue: "new-name", RunID: "run-test1"}); err != nil {
+		t.Fatalf("Adding to history should not error: %v", err)
+	}
+
+	// The test's stdin is never read, so a prompt would fail rather than wait
+	stdinIsTTY = false
+	defer func() { stdinIsTTY = true }()
+	if err := undoLastRun(context.Background(), path, server.URL, "us-east-1", "", 0, true, nil); err != nil {
+		t.Fatalf("undoLastRun with force should not error: %v", err)
+	}
+	if len(reverted) != 1 || reverted[0] != "i-1=old-name" {
+		t.Errorf("Expected i-1 reverted to old-name, got %v", reverted)
+	}
+}
+
+// TestUndoRestoresExtraTags tests that undo puts back --extra-tag values a run overwrote
+// and only removes the keys it added
+func TestUndoRestoresExtraTags(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+
+	var created, deleted url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		values, _ := url.ParseQuery(string(body))
+		switch values.Get("Action") {
+		case "GetCallerIdentity":
+			fmt.Fprint(w, `<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><GetCallerIdentityResult>`+
+				`<Arn>arn:aws:iam::123456789012:user/test</Arn><UserId>AIDTEST</UserId><Account>123456789012</Account>`+
+				`</GetCallerIdentityResult><ResponseMetadata><RequestId>test</RequestId></ResponseMetadata></GetCallerIdentityResponse>`)
+		case "CreateTags":
+			created = values
+			writeCreateTagsResponse(w)
+		case "DeleteTags":
+			deleted = values
+			writeDeleteTagsResponse(w)
+		}
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), ".quick-tag.yml")
+	entry := TagHistoryEntry{
+		Account: "123456789012", Region: "us-east-1", Resource: "i-1", OldValue: "old-name", NewValue: "new-name", RunID: "run-test1",
+		ExtraTags: []string{"Owner", "ManagedBy"}, PriorTags: map[string]string{"Owner": "alice"},
+	}
+	if err := addToHistory(path, 0, entry); err != nil {
+		t.Fatalf("Adding to history should not error: %v", err)
+	}
+
+	stdinIsTTY = false
+	defer func() { stdinIsTTY = true }()
+	if err := undoLastRun(context.Background(), path, server.URL, "us-east-1", "", 0, true, nil); err != nil {
+		t.Fatalf("undoLastRun should not error: %v", err)
+	}
+	if created.Get("Tag.1.Value") != "old-name" || created.Get("Tag.2.Key") != "Owner" || created.Get("Tag.2.Value") != "alice" {
+		t.Errorf("Expected Name and Owner=alice restored, got %v", created)
+	}
+	if deleted.Get("Tag.1.Key") != "ManagedBy" || deleted.Has("Tag.2.Key") {
+		t.Errorf("Expected only ManagedBy removed, got %v", deleted)
+	}
+}
+
+// TestUndoResource tests reverting only some resources from a run with --undo-resource
+func TestUndoResource(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+
+	var reverted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		values, _ := url.ParseQuery(string(body))
+		switch values.Get("Action") {
+		case "GetCallerIdentity":
+			fmt.Fprint(w, `<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><GetCallerIdentityResult>`+
+				`<Arn>arn:aws:iam::123456789012:user/test</Arn><UserId>AIDTEST</UserId><Account>123456789012</Account>`+
+				`</GetCallerIdentityResult><ResponseMetadata><RequestId>test</RequestId></ResponseMetadata></GetCallerIdentityResponse>`)
+		case "DeleteTags":
+			// These resources had no Name before the run, so undo removes it rather than blanking it
+			if values.Get("Tag.1.Key") == "Name" && !values.Has("Tag.1.Value") {
+				reverted = append(reverted, values.Get("ResourceId.1"))
+			}
+			writeDeleteTagsResponse(w)
+		}
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), ".quick-tag.yml")
+	for _, id := range []string{"i-1", "i-2", "i-3"} {
+		if err := addToHistory(path, 0, TagHistoryEntry{Account: "123456789012", Region: "us-east-1", Resource: id, NewValue: "name-" + id, RunID: "run-test1"}); err != nil {
+			t.Fatalf("Adding to history should not error: %v", err)
+		}
+	}
+
+	stdinIsTTY = false
+	defer func() { stdinIsTTY = true }()
+	if err := undoLastRun(context.Background(), path, server.URL, "us-east-1", "", 0, true, []string{"i-2"}); err != nil {
+		t.Fatalf("undoLastRun with a resource should not error: %v", err)
+	}
+	if !slices.Equal(reverted, []string{"i-2"}) {
+		t.Errorf("Expected only i-2 reverted, got %v", reverted)
+	}
+
+	history, err := loadHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, remaining := findLastRun(history, "123456789012", time.Time{})
+	if len(remaining) != 2 || remaining[0].Resource != "i-1" || remaining[1].Resource != "i-3" {
+		t.Errorf("Expected i-1 and i-3 still undoable, got %+v", remaining)
+	}
+
+	if err := undoLastRun(context.Background(), path, server.URL, "us-east-1", "", 0, true, []string{"i-9"}); err == nil {
+		t.Error("Expected an error for a resource not in the run")
+	}
+
+	var buf bytes.Buffer
+	if got := parseSelection(&buf, "2, 9, x", 3); !slices.Equal(got, []int{1}) || !strings.Contains(buf.String(), "Invalid selection: 9") {
+		t.Errorf("parseSelection() = %v with warnings %q", got, buf.String())
+	}
+}
+
+// TestNameBuilders tests the exported naming package name builders
+func TestNameBuilders(t *testing.T) {
+	tests := []struct {
+		got      string
+		expected string
+	}{
+		{naming.InstanceName("i-1", "ami-1", "web-ami", ""), "web-ami"},
+		{naming.InstanceName("i-1", "ami-1", "web-ami", "t3.large, linux"), "web-ami (t3.large, linux)"},
+		{naming.InstanceName("i-1", "ami-1", "", ""), "instance-ami-1"},
+		{naming.InstanceName("i-1", "", "", ""), "instance-i-1"},
+		{naming.VolumeName("i-1", "web", "/dev/xvdf", ""), "i-1(web) /dev/xvdf"},
+		{naming.VolumeName("i-1", "", "/dev/xvdf", "gp3-100GiB"), "i-1 /dev/xvdf gp3-100GiB"},
+		{naming.VolumeName("", "", "unattached", ""), "unattached"},
+		{naming.EIPName("i-1", "web", ""), "web-eip"},
+		{naming.EIPName("i-1", "", ""), "i-1-eip"},
+		{naming.EIPName("", "", "eni-1"), "eni-1-eip"},
+		{naming.EIPName("", "", ""), "unassociated-eip"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.expected {
+			t.Errorf("got %q, want %q", tt.got, tt.expected)
+		}
+	}
+
+	enis := []struct {
+		attachmentInfo string
+		wantName       string
+		wantAttachment string
+	}{
+		{"attached-to-i-1", "web-eni", "i-1 (web)"},
+		{"attached-to-i-2", "i-2-eni", "i-2"},
+		{"attached-elb-my-lb", "my-lb-eni", "elb-my-lb"},
+		{"attached-rds-ela-attach-0abc", "rds-ela-attach-0abc-eni", "rds-attachment-ela-attach-0abc"},
+		{"unattached", "unattached-eni", "unattached"},
+	}
+	for _, tt := range enis {
+		name, attachment := naming.ENIName(tt.attachmentInfo, map[string]string{"i-1": "web"})
+		if name != tt.wantName || attachment != tt.wantAttachment {
+			t.Errorf("naming.ENIName(%q) = (%q, %q), want (%q, %q)", tt.attachmentInfo, name, attachment, tt.wantName, tt.wantAttachment)
+		}
+	}
+}
+
+// TestJSONLogs tests that --log-format json emits structured tagging events
+func TestJSONLogs(t *testing.T) {
+	var buf safeBuffer
+	logger = newJSONLogger(&buf, false)
+	defer func() { logger = nil }()
+	quiet = true
+	defer func() { quiet = false }()
+
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		writeCreateTagsResponse(w)
+	})
+	config := &Config{EC2Client: client, HistoryFile: filepath.Join(t.TempDir(), ".quick-tag.yml")}
+	if err := tagResources(context.Background(), config, []*ResourceInfo{{ID: "i-1", Type: "instance", SuggestedName: "web"}}, "123456789012", "run-1"); err != nil {
+		t.Fatalf("tagResources should not error: %v", err)
+	}
+	debugf("not logged without --verbose")
+
+	var event map[string]any
+	if err := json.Unmarshal([]byte(strings.TrimSpace(buf.String())), &event); err != nil {
+		t.Fatalf("Expected exactly one JSON log line, got %q: %v", buf.String(), err)
+	}
+	if event["msg"] != "tagged" || event["level"] != "INFO" || event["name"] != "web" || event["type"] != "instance" {
+		t.Errorf("Unexpected log event: %v", event)
+	}
+}
+
+// TestClassifyAWSError tests classifying AWS errors by error code
+func TestClassifyAWSError(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected awsErrorKind
+	}{
+		{&smithy.GenericAPIError{Code: "InvalidInstanceID.NotFound"}, awsErrorNotFound},
+		{&smithy.GenericAPIError{Code: "InvalidAllocationID.NotFound"}, awsErrorNotFound},
+		{&smithy.GenericAPIError{Code: "RequestLimitExceeded"}, awsErrorThrottling},
+		{&smithy.GenericAPIError{Code: "ThrottlingException"}, awsErrorThrottling},
+		{&smithy.GenericAPIError{Code: "UnauthorizedOperation"}, awsErrorAccessDenied},
+		{&smithy.GenericAPIError{Code: "InvalidParameterValue", Message: "resource NotFound"}, awsErrorOther},
+		{fmt.Errorf("failed to tag instance i-1: %w", &smithy.GenericAPIError{Code: "InvalidInstanceID.NotFound"}), awsErrorNotFound},
+		{errors.New("InvalidInstanceID.NotFound"), awsErrorOther},
+	}
+
+	for _, tt := range tests {
+		if got := classifyAWSError(tt.err); got != tt.expected {
+			t.Errorf("classifyAWSError(%v) = %v, want %v", tt.err, got, tt.expected)
+		}
+	}
+}
+
+// TestScanProgress tests the running count passed to the progress callback and its formatting
+func TestScanProgress(t *testing.T) {
+	counts := map[int]string{0: "0", 999: "999", 1000: "1,000", 1234567: "1,234,567", -4321: "-4,321"}
+	for n, expected := range counts {
+		if got := formatCount(n); got != expected {
+			t.Errorf("formatCount(%d) = %q, want %q", n, got, expected)
+		}
+	}
+
+	pages := 0
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		pages++
+		nextToken := ""
+		if pages == 1 {
+			nextToken = "<nextToken>page2</nextToken>"
+		}
+		fmt.Fprintf(w, `<DescribeVolumesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><volumeSet>`+
+			`<item><volumeId>vol-%d</volumeId><status>available</status></item><item><volumeId>vol-%d0</volumeId><status>available</status></item>`+
+			`</volumeSet>%s</DescribeVolumesResponse>`, pages, pages, nextToken)
+	})
+
+	var seen []int
+	config := &Config{EC2Client: client, Describe: newDescribeCache(&fakeDescribeClient{}), Progress: func(n int) { seen = append(seen, n) }}
+	if _, err := findUntaggedVolumes(context.Background(), config); err != nil {
+		t.Fatalf("findUntaggedVolumes should not error: %v", err)
+	}
+	if !slices.Equal(seen, []int{2, 4}) {
+		t.Errorf("Expected progress after each page of [2 4], got %v", seen)
+	}
+}
+
+// TestSubnetsAndRouteTables tests naming subnets and route tables
+func TestSubnetsAndRouteTables(t *testing.T) {
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("Action") {
+		case "DescribeSubnets":
+			fmt.Fprint(w, `<DescribeSubnetsResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><subnetSet>`+
+				`<item><subnetId>subnet-1</subnetId><vpcId>vpc-1</vpcId><cidrBlock>10.0.1.0/24</cidrBlock><state>available</state></item>`+
+				`<item><subnetId>subnet-2</subnetId><vpcId>vpc-1</vpcId><cidrBlock>10.0.2.0/24</cidrBlock><state>available</state>`+
+				`<tagSet><item><key>Name</key><value>private-a</value></item></tagSet></item>`+
+				`</subnetSet></DescribeSubnetsResponse>`)
+		case "DescribeRouteTables":
+			fmt.Fprint(w, `<DescribeRouteTablesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><routeTableSet>`+
+				`<item><routeTableId>rtb-main</routeTableId><vpcId>vpc-1</vpcId><associationSet><item><main>true</main></item></associationSet></item>`+
+				`<item><routeTableId>rtb-1</routeTableId><vpcId>vpc-1</vpcId><associationSet><item><main>false</main><subnetId>subnet-2</subnetId></item>`+
+				`<item><main>false</main><subnetId>subnet-1</subnetId></item></associationSet></item>`+
+				`<item><routeTableId>rtb-stale</routeTableId><vpcId>vpc-1</vpcId>`+
+				`<tagSet><item><key>Name</key><value>subnet-9-rtb</value></item></tagSet></item>`+
+				`</routeTableSet></DescribeRouteTablesResponse>`)
+		}
+	})
+	config := &Config{EC2Client: client}
+
+	subnets, err := findUntaggedSubnets(context.Background(), config)
+	if err != nil {
+		t.Fatalf("findUntaggedSubnets should not error: %v", err)
+	}
+	if len(subnets) != 1 || subnets[0].ID != "subnet-1" || subnets[0].SuggestedName != "vpc-1-10.0.1.0/24" {
+		t.Errorf("Expected only subnet-1 named vpc-1-10.0.1.0/24, got %+v", subnets)
+	}
+
+	routeTables, err := findUntaggedRouteTables(context.Background(), config)
+	if err != nil {
+		t.Fatalf("findUntaggedRouteTables should not error: %v", err)
+	}
+	names := make(map[string]string)
+	for _, routeTable := range routeTables {
+		names[routeTable.ID] = routeTable.SuggestedName
+	}
+	expected := map[string]string{
+		"rtb-main":  "vpc-1-main-rtb",
+		"rtb-1":     "subnet-1-rtb",           // First associated subnet by ID
+		"rtb-stale": "vpc-1-unassociated-rtb", // Generated name no longer matches the association
+	}
+	if !maps.Equal(names, expected) {
+		t.Errorf("Route table names = %v, want %v", names, expected)
+	}
+
+	if !naming.IsQuickTagCreatedName("vpc-0abc-10.0.1.0/24", "subnet") || naming.IsQuickTagCreatedName("private-a", "subnet") {
+		t.Error("Only generated subnet names should be recognized")
+	}
+	if !naming.IsQuickTagNameStillValid("subnet-1-rtb", "route-table", "associated", "subnet-1") {
+		t.Error("subnet-1-rtb should be valid while associated with subnet-1")
+	}
+}
+
+// TestNATGateways tests naming NAT gateways after their subnet and skipping deleted ones
+func TestNATGateways(t *testing.T) {
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<DescribeNatGatewaysResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><natGatewaySet>`+
+			`<item><natGatewayId>nat-1</natGatewayId><subnetId>subnet-1</subnetId><vpcId>vpc-1</vpcId><state>available</state>`+
+			`<natGatewayAddressSet><item><publicIp>203.0.113.10</publicIp></item></natGatewayAddressSet></item>`+
+			`<item><natGatewayId>nat-gone</natGatewayId><subnetId>subnet-2</subnetId><vpcId>vpc-1</vpcId><state>deleted</state></item>`+
+			`<item><natGatewayId>nat-named</natGatewayId><subnetId>subnet-3</subnetId><vpcId>vpc-1</vpcId><state>available</state>`+
+			`<tagSet><item><key>Name</key><value>egress-a</value></item></tagSet></item>`+
+			`</natGatewaySet></DescribeNatGatewaysResponse>`)
+	})
+
+	natGateways, err := findUntaggedNatGateways(context.Background(), &Config{EC2Client: client})
+	if err != nil {
+		t.Fatalf("findUntaggedNatGateways should not error: %v", err)
+	}
+	if len(natGateways) != 1 || natGateways[0].ID != "nat-1" || natGateways[0].SuggestedName != "nat-subnet-1" || natGateways[0].Extra != "203.0.113.10" {
+		t.Errorf("Expected only nat-1 named nat-subnet-1, got %+v", natGateways)
+	}
+
+	tests := []struct {
+		subnetID, vpcID, publicIP string
+		want                      string
+	}{
+		{"subnet-0abc", "vpc-0abc", "203.0.113.10", "nat-subnet-0abc"},
+		{"", "vpc-0abc", "203.0.113.10", "nat-vpc-0abc"},
+		{"", "", "203.0.113.10", "nat-203.0.113.10"},
+		{"", "", "", "nat-gateway"},
+	}
+	for _, tt := range tests {
+		got := naming.NATGatewayName(tt.subnetID, tt.vpcID, tt.publicIP)
+		if got != tt.want {
+			t.Errorf("NATGatewayName(%q, %q, %q) = %q, want %q", tt.subnetID, tt.vpcID, tt.publicIP, got, tt.want)
+		}
+		if !naming.IsQuickTagCreatedName(got, "nat-gateway") {
+			t.Errorf("IsQuickTagCreatedName(%q, \"nat-gateway\") = false, want true", got)
+		}
+	}
+	if naming.IsQuickTagCreatedName("egress-a", "nat-gateway") {
+		t.Error("egress-a should not be recognized as a generated NAT gateway name")
+	}
+}
+
+// TestInternetAndTransitGateways tests naming gateways after their VPC attachments
+func TestInternetAndTransitGateways(t *testing.T) {
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("Action") {
+		case "DescribeInternetGateways":
+			fmt.Fprint(w, `<DescribeInternetGatewaysResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><internetGatewaySet>`+
+				`<item><internetGatewayId>igw-1</internetGatewayId><attachmentSet><item><vpcId>vpc-1</vpcId><state>available</state></item></attachmentSet></item>`+
+				`<item><internetGatewayId>igw-2</internetGatewayId></item>`+
+				`<item><internetGatewayId>igw-stale</internetGatewayId><attachmentSet><item><vpcId>vpc-2</vpcId><state>available</state></item></attachmentSet>`+
+				`<tagSet><item><key>Name</key><value>detached-igw</value></item></tagSet></item>`+
+				`<item><internetGatewayId>igw-named</internetGatewayId><tagSet><item><key>Name</key><value>edge</value></item></tagSet></item>`+
+				`</internetGatewaySet></DescribeInternetGatewaysResponse>`)
+		case "DescribeTransitGateways":
+			fmt.Fprint(w, `<DescribeTransitGatewaysResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><transitGatewaySet>`+
+				`<item><transitGatewayId>tgw-1</transitGatewayId><state>available</state></item>`+
+				`<item><transitGatewayId>tgw-2</transitGatewayId><state>available</state></item>`+
+				`<item><transitGatewayId>tgw-3</transitGatewayId><state>available</state></item>`+
+				`<item><transitGatewayId>tgw-gone</transitGatewayId><state>deleted</state></item>`+
+				`</transitGatewaySet></DescribeTransitGatewaysResponse>`)
+		case "DescribeTransitGatewayAttachments":
+			fmt.Fprint(w, `<DescribeTransitGatewayAttachmentsResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><transitGatewayAttachments>`+
+				`<item><transitGatewayId>tgw-1</transitGatewayId><resourceId>vpc-1</resourceId><state>available</state></item>`+
+				`<item><transitGatewayId>tgw-2</transitGatewayId><resourceId>vpc-2</resourceId><state>available</state></item>`+
+				`<item><transitGatewayId>tgw-2</transitGatewayId><resourceId>vpc-3</resourceId><state>available</state></item>`+
+				`<item><transitGatewayId>tgw-3</transitGatewayId><resourceId>vpc-4</resourceId><state>deleted</state></item>`+
+				`</transitGatewayAttachments></DescribeTransitGatewayAttachmentsResponse>`)
+		}
+	})
+	config := &Config{EC2Client: client}
+
+	internetGateways, err := findUntaggedInternetGateways(context.Background(), config)
+	if err != nil {
+		t.Fatalf("findUntaggedInternetGateways should not error: %v", err)
+	}
+	names := make(map[string]string)
+	for _, internetGateway := range internetGateways {
+		names[internetGateway.ID] = internetGateway.SuggestedName
+	}
+	expected := map[string]string{
+		"igw-1":     "vpc-1-igw",
+		"igw-2":     "detached-igw",
+		"igw-stale": "vpc-2-igw", // Generated name no longer matches the attachment
+	}
+	if !maps.Equal(names, expected) {
+		t.Errorf("Internet gateway names = %v, want %v", names, expected)
+	}
+
+	transitGateways, err := findUntaggedTransitGateways(context.Background(), config)
+	if err != nil {
+		t.Fatalf("findUntaggedTransitGateways should not error: %v", err)
+	}
+	names = make(map[string]string)
+	for _, transitGateway := range transitGateways {
+		names[transitGateway.ID] = transitGateway.SuggestedName
+	}
+	expected = map[string]string{
+		"tgw-1": "vpc-1-tgw",
+		"tgw-2": "tgw-2-vpcs",
+		"tgw-3": "unattached-tgw", // Deleted attachments don't count
+	}
+	if !maps.Equal(names, expected) {
+		t.Errorf("Transit gateway names = %v, want %v", names, expected)
+	}
+
+	for _, name := range []string{"vpc-0abc-igw", "detached-igw"} {
+		if !naming.IsQuickTagCreatedName(name, "igw") {
+			t.Errorf("IsQuickTagCreatedName(%q, \"igw\") = false, want true", name)
+		}
+	}
+	for _, name := range []string{"vpc-0abc-tgw", "tgw-3-vpcs", "unattached-tgw"} {
+		if !naming.IsQuickTagCreatedName(name, "tgw") {
+			t.Errorf("IsQuickTagCreatedName(%q, \"tgw\") = false, want true", name)
+		}
+	}
+}
+
+// fakeTGWAttachmentsClient serves DescribeTransitGatewayAttachments one attachment per page
+type fakeTGWAttachmentsClient struct {
+	pages [][]types.TransitGatewayAttachment
+	calls int
+}
+
+func (f *fakeTGWAttachmentsClient) DescribeTransitGatewayAttachments(ctx context.Context, params *ec2.DescribeTransitGatewayAttachmentsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeTransitGatewayAttachmentsOutput, error) {
+	page := f.calls
+	f.calls++
+	output := &ec2.DescribeTransitGatewayAttachmentsOutput{TransitGatewayAttachments: f.pages[page]}
+	if page+1 < len(f.pages) {
+		output.NextToken = aws.String(fmt.Sprint(page + 1))
+	}
+	return output, nil
+}
+
+// TestTransitGatewayVPCs tests paging through transit gateway attachments with --max-pages and --stats
+func TestTransitGatewayVPCs(t *testing.T) {
+	quiet = true
+	defer func() { quiet = false }()
+
+	attachment := func(tgwID, vpcID string, state types.TransitGatewayAttachmentState) []types.TransitGatewayAttachment {
+		return []types.TransitGatewayAttachment{{TransitGatewayId: aws.String(tgwID), ResourceId: aws.String(vpcID), State: state}}
+	}
+	newClient := func() *fakeTGWAttachmentsClient {
+		return &fakeTGWAttachmentsClient{pages: [][]types.TransitGatewayAttachment{
+			attachment("tgw-1", "vpc-b", types.TransitGatewayAttachmentStateAvailable),
+			attachment("tgw-1", "vpc-a", types.TransitGatewayAttachmentStateAvailable),
+			attachment("tgw-1", "vpc-gone", types.TransitGatewayAttachmentStateDeleted),
+		}}
+	}
+
+	config := &Config{Stats: newRunStats()}
+	vpcs, err := transitGatewayVPCs(context.Background(), newClient(), config)
+	if err != nil {
+		t.Fatalf("transitGatewayVPCs should not error: %v", err)
+	}
+	if !slices.Equal(vpcs["tgw-1"], []string{"vpc-a", "vpc-b"}) {
+		t.Errorf("Attached VPCs = %v, want [vpc-a vpc-b]", vpcs["tgw-1"])
+	}
+	if config.Stats.pages["DescribeTransitGatewayAttachments"] != 3 {
+		t.Errorf("Expected 3 attachment pages in stats, got %v", config.Stats.pages)
+	}
+
+	client := newClient()
+	vpcs, _ = transitGatewayVPCs(context.Background(), client, &Config{MaxPages: 1})
+	if client.calls != 1 || !slices.Equal(vpcs["tgw-1"], []string{"vpc-b"}) {
+		t.Errorf("--max-pages 1 made %d calls and found %v", client.calls, vpcs["tgw-1"])
+	}
+}
+
+// TestVPCs tests naming VPCs from their CIDR block and default flag
+func TestVPCs(t *testing.T) {
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<DescribeVpcsResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><vpcSet>`+
+			`<item><vpcId>vpc-default</vpcId><cidrBlock>172.31.0.0/16</cidrBlock><state>available</state><isDefault>true</isDefault></item>`+
+			`<item><vpcId>vpc-app</vpcId><cidrBlock>10.0.0.0/16</cidrBlock><state>available</state><isDefault>false</isDefault></item>`+
+			`<item><vpcId>vpc-named</vpcId><cidrBlock>10.1.0.0/16</cidrBlock><state>available</state><isDefault>false</isDefault>`+
+			`<tagSet><item><key>Name</key><value>shared-services</value></item></tagSet></item>`+
+			`</vpcSet></DescribeVpcsResponse>`)
+	})
+
+	vpcs, err := findUntaggedVPCs(context.Background(), &Config{EC2Client: client})
+	if err != nil {
+		t.Fatalf("findUntaggedVPCs should not error: %v", err)
+	}
+	names := make(map[string]string)
+	for _, vpc := range vpcs {
+		names[vpc.ID] = vpc.SuggestedName
+	}
+	expected := map[string]string{"vpc-default": "default-vpc (172.31.0.0/16)", "vpc-app": "vpc (10.0.0.0/16)"}
+	if !maps.Equal(names, expected) {
+		t.Errorf("VPC names = %v, want %v", names, expected)
+	}
+
+	if !naming.IsQuickTagCreatedName("default-vpc (172.31.0.0/16)", "vpc") || naming.IsQuickTagCreatedName("shared-services", "vpc") {
+		t.Error("Only generated VPC names should be recognized")
+	}
+	if got := resourceTypeLabel("vpc", 2); got != "VPCs" {
+		t.Errorf("resourceTypeLabel(vpc, 2) = %q, want %q", got, "VPCs")
+	}
+}
+
+// TestOverwriteRequiresFlag tests that auto-applied tags skip named resources without --overwrite
+func TestOverwriteRequiresFlag(t *testing.T) {
+	var tagged []string
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		values, _ := url.ParseQuery(string(body))
+		for i := 1; values.Get(fmt.Sprintf("ResourceId.%d", i)) != ""; i++ {
+			tagged = append(tagged, values.Get(fmt.Sprintf("ResourceId.%d", i)))
+		}
+		writeCreateTagsResponse(w)
+	})
+	quiet = true
+	defer func() { quiet = false }()
+
+	resources := []*ResourceInfo{
+		{ID: "i-named", Type: "instance", Name: "instance-ami-old", SuggestedName: "instance-ami-new"},
+		{ID: "i-empty", Type: "instance", SuggestedName: "instance-ami-new"},
+	}
+	config := &Config{EC2Client: client, Region: "us-east-1", HistoryFile: filepath.Join(t.TempDir(), ".quick-tag.yml"), AssumeYes: true}
+	applied, err := applyTags(context.Background(), config, resources, "123456789012", "run-1", true)
+	if err != nil {
+		t.Fatalf("applyTags should not error: %v", err)
+	}
+	if applied != 1 || !slices.Equal(tagged, []string{"i-empty"}) {
+		t.Errorf("Without --overwrite, applied %d and tagged %v, want 1 and [i-empty]", applied, tagged)
+	}
+
+	tagged = nil
+	config.Overwrite = true
+	if applied, err := applyTags(context.Background(), config, resources, "123456789012", "run-2", true); err != nil || applied != 2 {
+		t.Errorf("With --overwrite, applyTags = %d, %v, want 2, nil", applied, err)
+	}
+	if len(tagged) != 2 {
+		t.Errorf("With --overwrite, tagged %v, want both resources", tagged)
+	}
+}
+
+// TestWriteCompletion tests that completion scripts cover flag names and region values
+func TestWriteCompletion(t *testing.T) {
+	fs := flag.NewFlagSet("quick-tag", flag.ContinueOnError)
+	fs.String("region", "", "AWS region [default: profile]")
+	fs.Bool("yes", false, "Tag everything, don't prompt")
+	fs.Int("limit", 0, "Maximum: resources")
+
+	for _, shell := range validCompletionShells {
+		var buf bytes.Buffer
+		if err := writeCompletion(&buf, shell, fs); err != nil {
+			t.Fatalf("writeCompletion(%q) should not error: %v", shell, err)
+		}
+		script := buf.String()
+		for _, want := range []string{"region", "yes", "limit", "eu-west-1", "completion"} {
+			if !strings.Contains(script, want) {
+				t.Errorf("writeCompletion(%q) is missing %q", shell, want)
+			}
+		}
+		// Check the script parses, when the shell is installed
+		if path, err := exec.LookPath(shell); err == nil {
+			check := exec.Command(path, "-n")
+			check.Stdin = strings.NewReader(script)
+			if shell == "fish" {
+				check = exec.Command(path, "--no-execute")
+				check.Stdin = strings.NewReader(script)
+			}
+			if out, err := check.CombinedOutput(); err != nil {
+				t.Errorf("%s rejected the completion script: %v\n%s", shell, err, out)
+			}
+		}
+	}
+
+	if err := writeCompletion(io.Discard, "powershell", fs); err == nil {
+		t.Error("writeCompletion should reject unsupported shells")
+	}
+}
+
+// TestTUISelection tests key decoding, toggling, and scrolling in the --tui list
+func TestTUISelection(t *testing.T) {
+	noColor = true
+	defer func() { noColor = false }()
+
+	reader := bufio.NewReader(strings.NewReader("j \x1b[Bx\x1b[Aa\r"))
+	var keys []tuiKey
+	for {
+		key, err := readTUIKey(reader)
+		if err != nil {
+			break
+		}
+		keys = append(keys, key)
+	}
+	expectedKeys := []tuiKey{tuiKeyDown, tuiKeyToggle, tuiKeyDown, tuiKeyToggle, tuiKeyUp, tuiKeyToggleAll, tuiKeyConfirm}
+	if !slices.Equal(keys, expectedKeys) {
+		t.Fatalf("readTUIKey decoded %v, want %v", keys, expectedKeys)
+	}
+
+	resources := []*ResourceInfo{
+		{ID: "i-1", SuggestedName: "one"},
+		{ID: "i-2", SuggestedName: "two"},
+		{ID: "i-3", SuggestedName: "three"},
+	}
+	selection := newTUISelection(resources)
+	for _, key := range []tuiKey{tuiKeyDown, tuiKeyToggle, tuiKeyDown, tuiKeyDown, tuiKeyToggle} {
+		selection.handle(key)
+	}
+	if got := selection.selected(); len(got) != 2 || got[0].ID != "i-2" || got[1].ID != "i-3" {
+		t.Errorf("selected() = %v, want i-2 and i-3", got)
+	}
+
+	var buf bytes.Buffer
+	selection.render(&buf, 2)
+	rendered := buf.String()
+	if strings.Contains(rendered, "i-1") || !strings.Contains(rendered, "> [x] i-3 untagged -> three") || !strings.Contains(rendered, "2 of 3 selected") {
+		t.Errorf("render should scroll to the cursor and show checkboxes, got:\n%s", rendered)
+	}
+
+	// Toggling all checks everything, then clears it
+	selection.handle(tuiKeyToggleAll)
+	if len(selection.selected()) != 3 {
+		t.Errorf("Toggle all should check every resource")
+	}
+	selection.handle(tuiKeyToggleAll)
+	if len(selection.selected()) != 0 {
+		t.Errorf("Toggle all with everything checked should clear the selection")
+	}
+	if done, cancelled := selection.handle(tuiKeyCancel); !done || !cancelled {
+		t.Errorf("handle(tuiKeyCancel) = %v, %v, want true, true", done, cancelled)
+	}
+}
+
+// TestPrintSelectionList tests that the selection list has a header per type and continuous numbering
+func TestPrintSelectionList(t *testing.T) {
+	noColor = true
+	defer func() { noColor = false }()
+
+	var buf bytes.Buffer
+	printSelectionList(&buf, []*ResourceInfo{
+		{ID: "i-1", Type: "instance", SuggestedName: "web", Source: "AMI"},
+		{ID: "i-2", Type: "instance", Name: "old", SuggestedName: "db"},
+		{ID: "eni-1", Type: "eni", SuggestedName: "web-eni", Source: "instance i-1"},
+		{ID: "rtb-1", Type: "route-table", SuggestedName: "main-rtb"},
+	})
+
+	expected := "Instances (2):\n" +
+		"  1. i-1   untagged -> web [from AMI]\n" +
+		"  2. i-2   old -> db\n" +
+		"ENIs (1):\n" +
+		"  3. eni-1 untagged -> web-eni [from instance i-1]\n" +
+		"Route tables (1):\n" +
+		"  4. rtb-1 untagged -> main-rtb\n"
+	if buf.String() != expected {
+		t.Errorf("printSelectionList() =\n%s\nwant\n%s", buf.String(), expected)
+	}
+}
+
+// TestENINameSource tests describing where an ENI's suggested name comes from
+func TestENINameSource(t *testing.T) {
+	tests := []struct {
+		attachmentInfo string
+		want           string
+	}{
+		{"attached-to-i-0abc", "instance i-0abc"},
+		{"attached-elb-web-lb", "ELB"},
+		{"attached-nat-nat-0abc", "nat attachment"},
+		{"unattached", "attachment state"},
+	}
+	for _, tt := range tests {
+		if got := eniNameSource(tt.attachmentInfo); got != tt.want {
+			t.Errorf("eniNameSource(%q) = %q, want %q", tt.attachmentInfo, got, tt.want)
+		}
+	}
+}
+
+// TestNamePolicy tests that --name-policy also includes resources whose existing Name breaks it
+func TestNamePolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yml")
+	if err := os.WriteFile(path, []byte("vpc: '^(prod|dev)-'\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := loadNamePolicy(path)
+	if err != nil {
+		t.Fatalf("loadNamePolicy should not error: %v", err)
+	}
+
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<DescribeVpcsResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><vpcSet>`+
+			`<item><vpcId>vpc-ok</vpcId><cidrBlock>10.0.0.0/16</cidrBlock><state>available</state>`+
+			`<tagSet><item><key>Name</key><value>prod-core</value></item></tagSet></item>`+
+			`<item><vpcId>vpc-bad</vpcId><cidrBlock>10.1.0.0/16</cidrBlock><state>available</state>`+
+			`<tagSet><item><key>Name</key><value>shared-services</value></item></tagSet></item>`+
+			`</vpcSet></DescribeVpcsResponse>`)
+	})
+	vpcs, err := findUntaggedVPCs(context.Background(), &Config{EC2Client: client, NamePolicy: policy})
+	if err != nil {
+		t.Fatalf("findUntaggedVPCs should not error: %v", err)
+	}
+	if len(vpcs) != 1 || vpcs[0].ID != "vpc-bad" || vpcs[0].Name != "shared-services" {
+		t.Errorf("findUntaggedVPCs with a policy = %v, want only vpc-bad", vpcs)
+	}
+
+	tests := []struct {
+		resourceType, name string
+		expected           bool
+	}{
+		{"vpc", "dev-edge", false},
+		{"vpc", "edge", true},
+		{"vpc", "", false},
+		{"subnet", "anything", false},
+	}
+	for _, test := range tests {
+		if got := policy.violates(test.resourceType, test.name); got != test.expected {
+			t.Errorf("violates(%q, %q) = %v, want %v", test.resourceType, test.name, got, test.expected)
+		}
+	}
+
+	for _, bad := range []string{"bucket: '.*'\n", "vpc: '('\n"} {
+		if err := os.WriteFile(path, []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadNamePolicy(path); err == nil {
+			t.Errorf("loadNamePolicy should reject %q", bad)
+		}
+	}
+}
+
+// fakeRegionLister returns a fixed DescribeRegions result and counts calls
+type fakeRegionLister struct {
+	regions []string
+	err     error
+	calls   int
+}
+
+func (f *fakeRegionLister) DescribeRegions(ctx context.Context, params *ec2.DescribeRegionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRegionsOutput, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	output := &ec2.DescribeRegionsOutput{}
+	for _, region := range f.regions {
+		output.Regions = append(output.Regions, types.Region{RegionName: aws.String(region)})
+	}
+	return output, nil
+}
+
+// TestValidateRegion tests that region typos fail fast and the region list is only fetched once
+func TestValidateRegion(t *testing.T) {
+	resetCache := func() { regionCache.names = nil }
+	resetCache()
+	defer resetCache()
+
+	lister := &fakeRegionLister{regions: []string{"us-east-1", "mx-central-1"}}
+	ctx := context.Background()
+	if err := validateRegion(ctx, "eu-west-1", lister); err != nil || lister.calls != 0 {
+		t.Errorf("Known regions should pass without DescribeRegions, got %v after %d calls", err, lister.calls)
+	}
+	if err := validateRegion(ctx, "mx-central-1", lister); err != nil {
+		t.Errorf("Regions from DescribeRegions should pass, got %v", err)
+	}
+	err := validateRegion(ctx, "us-east-11", lister)
+	if err == nil || !strings.Contains(err.Error(), "mx-central-1, us-east-1") {
+		t.Errorf("validateRegion(us-east-11) = %v, want an error listing valid regions", err)
+	}
+	if lister.calls != 1 {
+		t.Errorf("DescribeRegions called %d times, want 1 (cached)", lister.calls)
+	}
+
+	// Without a region list, unknown regions get the benefit of the doubt
+	resetCache()
+	if err := validateRegion(ctx, "us-gov-west-1", &fakeRegionLister{err: errors.New("UnauthorizedOperation")}); err != nil {
+		t.Errorf("validateRegion should allow regions it can't check, got %v", err)
+	}
+}
+
+// TestWriteResources tests that --output yaml round-trips the ResourceInfo fields
+func TestWriteResources(t *testing.T) {
+	resources := []*ResourceInfo{
+		{ID: "vol-1", Type: "volume", SuggestedName: "i-1(web) /dev/xvdf", State: "in-use", Extra: "/dev/xvdf", InstanceID: "i-1"},
+		{ID: "i-2", Type: "instance", EmptyName: true, SuggestedName: "instance-ami-1", State: "running"},
+	}
+
+	var buf bytes.Buffer
+	if err := writeResources(&buf, resources, "yaml"); err != nil {
+		t.Fatalf("writeResources should not error: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "resources:\n    - ID: vol-1\n      Type: volume\n") {
+		t.Errorf("Unexpected YAML layout:\n%s", buf.String())
+	}
+
+	var decoded struct {
+		Resources []*ResourceInfo `yaml:"resources" json:"resources"`
+	}
+	if err := yaml.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Output should be valid YAML: %v", err)
+	}
+	if len(decoded.Resources) != 2 || !reflect.DeepEqual(decoded.Resources, resources) {
+		t.Errorf("Decoded resources = %+v, want %+v", decoded.Resources, resources)
+	}
+
+	buf.Reset()
+	if err := writeResources(&buf, nil, "yaml"); err != nil || buf.String() != "resources: []\n" {
+		t.Errorf("writeResources(nil, yaml) = %q, %v, want an empty list", buf.String(), err)
+	}
+
+	// JSON carries the same document
+	buf.Reset()
+	if err := writeResources(&buf, resources, "json"); err != nil {
+		t.Fatalf("writeResources should not error: %v", err)
+	}
+	decoded.Resources = nil
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Output should be valid JSON: %v", err)
+	}
+	if len(decoded.Resources) != 2 || !reflect.DeepEqual(decoded.Resources, resources) {
+		t.Errorf("Decoded JSON resources = %+v, want %+v", decoded.Resources, resources)
+	}
+
+	// ...with the same keys as YAML, leaving out the same empty fields
+	var yamlDoc, jsonDoc struct {
+		Resources []map[string]any `yaml:"resources" json:"resources"`
+	}
+	var yamlBuf bytes.Buffer
+	writeResources(&yamlBuf, resources, "yaml")
+	yaml.Unmarshal(yamlBuf.Bytes(), &yamlDoc)
+	json.Unmarshal(buf.Bytes(), &jsonDoc)
+	for i := range resources {
+		yamlKeys, jsonKeys := slices.Sorted(maps.Keys(yamlDoc.Resources[i])), slices.Sorted(maps.Keys(jsonDoc.Resources[i]))
+		if !slices.Equal(yamlKeys, jsonKeys) {
+			t.Errorf("%s: YAML keys %v, JSON keys %v", resources[i].ID, yamlKeys, jsonKeys)
+		}
+	}
+	if strings.Contains(buf.String(), `"MonthlyCost"`) || strings.Contains(buf.String(), `"Tags"`) {
+		t.Errorf("Expected empty optional fields left out of JSON:\n%s", buf.String())
+	}
+
+	// Tags only feed naming, so their values (which may be sensitive) never reach output or plans
+	tagged := []*ResourceInfo{{ID: "i-3", Type: "instance", SuggestedName: "web", Tags: map[string]string{"DBPassword": "hunter2"}}}
+	for _, format := range []string{"yaml", "json"} {
+		buf.Reset()
+		writeResources(&buf, tagged, format)
+		if strings.Contains(buf.String(), "hunter2") || strings.Contains(buf.String(), "Tags") {
+			t.Errorf("Expected no tags in %s output:\n%s", format, buf.String())
+		}
+	}
+}
+
+// TestWriteCounts tests the --count line and JSON object
+func TestWriteCounts(t *testing.T) {
+	config := &Config{TargetIDs: map[string][]string{"instance": {"i-1", "i-2"}, "eni": {"eni-1"}}}
+	counts := countResources(config, []*ResourceInfo{{Type: "instance"}, {Type: "instance"}})
+
+	var buf bytes.Buffer
+	if err := writeCounts(&buf, counts, ""); err != nil || buf.String() != "instance=2 eni=0 total=2\n" {
+		t.Errorf("writeCounts() = %q, %v, want %q", buf.String(), err, "instance=2 eni=0 total=2\n")
+	}
+
+	buf.Reset()
+	if err := writeCounts(&buf, counts, "json"); err != nil || buf.String() != `{"eni":0,"instance":2,"total":2}`+"\n" {
+		t.Errorf("writeCounts(json) = %q, %v", buf.String(), err)
+	}
+}
+
+// TestHistoryActor tests that history records who made each change, and that older entries still load
+func TestHistoryActor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".quick-tag.yml")
+	if err := os.WriteFile(path, []byte("actions:\n    - Account: \"123456789012\"\n      Resource: i-old\n      OldValue: \"\"\n      NewValue: web\n      Timestamp: \"2025-01-01T00:00:00Z\"\n      RunID: run-old\n      Undone: false\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := addToHistory(path, 0, TagHistoryEntry{Account: "123456789012", Region: "us-east-1", Resource: "i-new", NewValue: "db", RunID: "run-new", Principal: "arn:aws:iam::123456789012:user/alice", User: "alice"}); err != nil {
+		t.Fatalf("addToHistory should not error: %v", err)
+	}
+
+	history, err := loadHistory(path)
+	if err != nil {
+		t.Fatalf("loadHistory should not error: %v", err)
+	}
+	if len(history.Actions) != 2 || history.Actions[0].Principal != "" || history.Actions[1].Principal != "arn:aws:iam::123456789012:user/alice" || history.Actions[1].User != "alice" {
+		t.Errorf("Unexpected history actions: %+v", history.Actions)
+	}
+
+	var buf bytes.Buffer
+	if err := listHistory(&buf, path, 0); err != nil {
+		t.Fatalf("listHistory should not error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.HasSuffix(lines[1], " -") || !strings.HasSuffix(lines[2], "alice (arn:aws:iam::123456789012:user/alice)") {
+		t.Errorf("Expected a By column in the listing, got:\n%s", buf.String())
+	}
+}
+
+// fakeKMSClient serves canned KMS keys and records tag changes
+type fakeKMSClient struct {
+	keys      map[string]kmstypes.KeyMetadata
+	tags      map[string]map[string]string
+	aliases   map[string]string // alias name -> key ID
+	denied    map[string]bool   // Key IDs whose key policy denies DescribeKey
+	tagged    map[string]map[string]string
+	removed   map[string][]string
+	described []string // Key IDs passed to DescribeKey
+}
+
+func (f *fakeKMSClient) ListKeys(ctx context.Context, params *kms.ListKeysInput, optFns ...func(*kms.Options)) (*kms.ListKeysOutput, error) {
+	output := &kms.ListKeysOutput{}
+	for _, id := range slices.Sorted(maps.Keys(f.keys)) {
+		output.Keys = append(output.Keys, kmstypes.KeyListEntry{KeyId: stringPtr(id)})
+	}
+	return output, nil
+}
+
+func (f *fakeKMSClient) ListAliases(ctx context.Context, params *kms.ListAliasesInput, optFns ...func(*kms.Options)) (*kms.ListAliasesOutput, error) {
+	output := &kms.ListAliasesOutput{}
+	for name, keyID := range f.aliases {
+		output.Aliases = append(output.Aliases, kmstypes.AliasListEntry{AliasName: stringPtr(name), TargetKeyId: stringPtr(keyID)})
+	}
+	return output, nil
+}
+
+func (f *fakeKMSClient) DescribeKey(ctx context.Context, params *kms.DescribeKeyInput, optFns ...func(*kms.Options)) (*kms.DescribeKeyOutput, error) {
+	f.described = append(f.described, *params.KeyId)
+	if f.denied[*params.KeyId] {
+		return nil, &smithy.GenericAPIError{Code: "AccessDeniedException"}
+	}
+	metadata := f.keys[*params.KeyId]
+	return &kms.DescribeKeyOutput{KeyMetadata: &metadata}, nil
+}
+
+func (f *fakeKMSClient) ListResourceTags(ctx context.Context, params *kms.ListResourceTagsInput, optFns ...func(*kms.Options)) (*kms.ListResourceTagsOutput, error) {
+	output := &kms.ListResourceTagsOutput{}
+	for key, value := range f.tags[*params.KeyId] {
+		output.Tags = append(output.Tags, kmstypes.Tag{TagKey: stringPtr(key), TagValue: stringPtr(value)})
+	}
+	return output, nil
+}
+
+func (f *fakeKMSClient) TagResource(ctx context.Context, params *kms.TagResourceInput, optFns ...func(*kms.Options)) (*kms.TagResourceOutput, error) {
+	if f.tagged == nil {
+		f.tagged = make(map[string]map[string]string)
+	}
+	for _, tag := range params.Tags {
+		if f.tagged[*params.KeyId] == nil {
+			f.tagged[*params.KeyId] = make(map[string]string)
 		}
+		f.tagged[*params.KeyId][*tag.TagKey] = *tag.TagValue
+	}
+	return &kms.TagResourceOutput{}, nil
+}
+
+func (f *fakeKMSClient) UntagResource(ctx context.Context, params *kms.UntagResourceInput, optFns ...func(*kms.Options)) (*kms.UntagResourceOutput, error) {
+	if f.removed == nil {
+		f.removed = make(map[string][]string)
+	}
+	f.removed[*params.KeyId] = append(f.removed[*params.KeyId], params.TagKeys...)
+	return &kms.UntagResourceOutput{}, nil
+}
+
+// TestKMSKeys tests finding unnamed customer managed keys, then tagging and undoing them
+func TestKMSKeys(t *testing.T) {
+	const (
+		aliased   = "11111111-1111-1111-1111-111111111111"
+		described = "22222222-2222-2222-2222-222222222222"
+		bare      = "33333333-3333-3333-3333-333333333333"
+		managed   = "44444444-4444-4444-4444-444444444444"
+		deleting  = "55555555-5555-5555-5555-555555555555"
+		named     = "66666666-6666-6666-6666-666666666666"
+		denied    = "77777777-7777-7777-7777-777777777777"
+	)
+	customer := func(description string, state kmstypes.KeyState) kmstypes.KeyMetadata {
+		return kmstypes.KeyMetadata{KeyManager: kmstypes.KeyManagerTypeCustomer, KeyState: state, Description: stringPtr(description)}
+	}
+	fake := &fakeKMSClient{
+		keys: map[string]kmstypes.KeyMetadata{
+			aliased:   customer("ignored for the alias", kmstypes.KeyStateEnabled),
+			described: customer("Payments signing key", kmstypes.KeyStateDisabled),
+			bare:      customer("", kmstypes.KeyStateEnabled),
+			managed:   {KeyManager: kmstypes.KeyManagerTypeAws, KeyState: kmstypes.KeyStateEnabled},
+			deleting:  customer("old", kmstypes.KeyStatePendingDeletion),
+			named:     customer("", kmstypes.KeyStateEnabled),
+			denied:    customer("", kmstypes.KeyStateEnabled),
+		},
+		tags:    map[string]map[string]string{named: {"Name": "vault"}},
+		aliases: map[string]string{"alias/app-secrets": aliased, "alias/aws/ebs": managed},
+		denied:  map[string]bool{denied: true},
+	}
+
+	quiet = true
+	defer func() { quiet = false }()
+
+	if shouldScanType(&Config{}, "kms-key") || !shouldScanType(&Config{ScanKMS: true}, "kms-key") {
+		t.Error("KMS keys should only be scanned with --kms")
+	}
+
+	config := &Config{KMSClient: fake, ScanKMS: true, Stats: newRunStats(), HistoryFile: filepath.Join(t.TempDir(), ".quick-tag.yml"), AssumeYes: true}
+	keys, err := findUntaggedKMSKeys(context.Background(), config)
+	if err != nil {
+		t.Fatalf("findUntaggedKMSKeys should not error: %v", err)
+	}
+	names := make(map[string]string)
+	for _, key := range keys {
+		names[key.ID] = key.SuggestedName
+	}
+	expected := map[string]string{
+		aliased:   "app-secrets",
+		described: "Payments signing key",
+		bare:      "kms-" + bare,
+	}
+	if !maps.Equal(names, expected) {
+		t.Errorf("KMS key names = %v, want %v", names, expected)
+	}
+	// The AWS managed key is dropped by its alias before any per-key call
+	if slices.Contains(fake.described, managed) {
+		t.Errorf("DescribeKey was called for the AWS managed key %s", managed)
+	}
+	if config.Stats.pages["DescribeKey"] != len(fake.keys)-1 || config.Stats.pages["ListAliases"] != 1 || config.Stats.pages["ListResourceTags"] != 4 {
+		t.Errorf("Unexpected KMS call counts in stats: %v", config.Stats.pages)
+	}
+	if !naming.IsQuickTagCreatedName("kms-"+bare, "kms-key") {
+		t.Errorf("kms-%s should be recognized as a generated name", bare)
+	}
+
+	// Tagging goes through kms:TagResource rather than ec2:CreateTags
+	applied, err := applyTags(context.Background(), config, keys, "123456789012", "run-1", true)
+	if err != nil || applied != len(keys) {
+		t.Fatalf("applyTags() = %d, %v, want %d, nil", applied, err, len(keys))
+	}
+	if fake.tagged[aliased]["Name"] != "app-secrets" {
+		t.Errorf("Expected %s tagged Name=app-secrets, got %v", aliased, fake.tagged[aliased])
+	}
+
+	if !isKMSKeyID(aliased) || !isKMSKeyID("mrk-1234567890abcdef1234567890abcdef") || isKMSKeyID("i-0abc") {
+		t.Error("isKMSKeyID should only match KMS key IDs")
+	}
+	undo := TagHistoryEntry{Resource: bare, NewValue: "kms-" + bare, ExtraTags: []string{"Owner", "Team"}, PriorTags: map[string]string{"Team": "ops"}}
+	if err := revertKMSKey(context.Background(), fake, undo); err != nil {
+		t.Fatalf("revertKMSKey should not error: %v", err)
+	}
+	if !slices.Equal(fake.removed[bare], []string{"Name", "Owner"}) {
+		t.Errorf("Undo removed %v from %s, want [Name Owner]", fake.removed[bare], bare)
+	}
+	if fake.tagged[bare]["Team"] != "ops" {
+		t.Errorf("Expected undo to restore Team=ops on %s, got %v", bare, fake.tagged[bare])
+	}
+}
+
+// fakeCostTagsClient returns fixed ListCostAllocationTags results
+type fakeCostTagsClient struct {
+	tags []cetypes.CostAllocationTag
+	err  error
+}
+
+func (f *fakeCostTagsClient) ListCostAllocationTags(ctx context.Context, params *costexplorer.ListCostAllocationTagsInput, optFns ...func(*costexplorer.Options)) (*costexplorer.ListCostAllocationTagsOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &costexplorer.ListCostAllocationTagsOutput{CostAllocationTags: f.tags}, nil
+}
+
+// TestCostTagNudge tests the --check-cost-tags message for each cost allocation status
+func TestCostTagNudge(t *testing.T) {
+	noColor = true
+	defer func() { noColor = false }()
+
+	tests := []struct {
+		name     string
+		client   *fakeCostTagsClient
+		expected string
+	}{
+		{"active", &fakeCostTagsClient{tags: []cetypes.CostAllocationTag{{TagKey: aws.String("Name"), Status: cetypes.CostAllocationTagStatusActive}}}, "is active for cost allocation"},
+		{"inactive", &fakeCostTagsClient{tags: []cetypes.CostAllocationTag{{TagKey: aws.String("Name"), Status: cetypes.CostAllocationTagStatusInactive}}}, "TagKey=Name,Status=Active"},
+		{"not yet in billing", &fakeCostTagsClient{}, "up to 24 hours"},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		printCostTagNudge(context.Background(), &buf, test.client, "Name")
+		if !strings.Contains(buf.String(), test.expected) {
+			t.Errorf("%s: printCostTagNudge() = %q, want it to mention %q", test.name, buf.String(), test.expected)
+		}
+	}
+
+	if _, err := costAllocationTagStatus(context.Background(), &fakeCostTagsClient{err: errors.New("boom")}, "Name"); err == nil {
+		t.Error("costAllocationTagStatus should return API errors")
+	}
+}
+
+// TestStateFilter tests that --state keeps only resources in the listed states
+func TestStateFilter(t *testing.T) {
+	resources := []*ResourceInfo{
+		{ID: "i-1", Type: "instance", State: "running"},
+		{ID: "i-2", Type: "instance", State: "stopped"},
+		{ID: "vol-1", Type: "volume", State: "in-use"},
+		{ID: "vol-2", Type: "volume", State: "available"},
+	}
+
+	states := splitList(" running, in-use,,")
+	if !slices.Equal(states, []string{"running", "in-use"}) {
+		t.Errorf("splitList() = %v, want [running in-use]", states)
+	}
+
+	var ids []string
+	for _, resource := range filterByState(slices.Clone(resources), states) {
+		ids = append(ids, resource.ID)
+	}
+	if !slices.Equal(ids, []string{"i-1", "vol-1"}) {
+		t.Errorf("filterByState() kept %v, want [i-1 vol-1]", ids)
+	}
+	if got := filterByState(resources, nil); len(got) != len(resources) {
+		t.Errorf("filterByState() with no states kept %d resources, want %d", len(got), len(resources))
+	}
+}
+
+// TestCostEstimate tests --with-cost estimates from the static rate card and the summary line
+func TestCostEstimate(t *testing.T) {
+	noColor = true
+	defer func() { noColor = false }()
+
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("Action") {
+		case "DescribeInstances":
+			fmt.Fprint(w, `<DescribeInstancesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><reservationSet><item><instancesSet>`+
+				`<item><instanceId>i-running</instanceId><instanceType>t3.micro</instanceType><instanceState><name>running</name></instanceState></item>`+
+				`<item><instanceId>i-stopped</instanceId><instanceType>m5.large</instanceType><instanceState><name>stopped</name></instanceState></item>`+
+				`<item><instanceId>i-exotic</instanceId><instanceType>u-12tb1.112xlarge</instanceType><instanceState><name>running</name></instanceState></item>`+
+				`</instancesSet></item></reservationSet></DescribeInstancesResponse>`)
+		case "DescribeVolumes":
+			fmt.Fprint(w, `<DescribeVolumesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><volumeSet>`+
+				`<item><volumeId>vol-1</volumeId><volumeType>gp3</volumeType><size>100</size><status>available</status></item>`+
+				`</volumeSet></DescribeVolumesResponse>`)
+		}
+	})
+
+	config := &Config{EC2Client: client, Describe: newDescribeCache(&fakeDescribeClient{}), WithCost: true}
+	instances, err := findUntaggedInstances(context.Background(), config)
+	if err != nil {
+		t.Fatalf("findUntaggedInstances should not error: %v", err)
+	}
+	volumes, err := findUntaggedVolumes(context.Background(), config)
+	if err != nil {
+		t.Fatalf("findUntaggedVolumes should not error: %v", err)
+	}
+
+	costs := make(map[string]string)
+	for _, resource := range append(instances, volumes...) {
+		costs[resource.ID] = costNote(resource)
+	}
+	expected := map[string]string{
+		"i-running": " ~$7.59/mo", // 0.0104 * 730
+		"i-stopped": " ~$0.00/mo",
+		"i-exotic":  "",
+		"vol-1":     " ~$8.00/mo", // 100 GB * 0.08
+	}
+	if !maps.Equal(costs, expected) {
+		t.Errorf("Cost notes = %v, want %v", costs, expected)
+	}
+
+	var buf bytes.Buffer
+	printCostSummary(&buf, append(instances, volumes...))
+	if !strings.Contains(buf.String(), "$15.59") || !strings.Contains(buf.String(), "1 not on the rate card") {
+		t.Errorf("Unexpected cost summary: %q", buf.String())
+	}
+
+	// Without --with-cost nothing is estimated or summarized
+	config.WithCost = false
+	instances, _ = findUntaggedInstances(context.Background(), config)
+	buf.Reset()
+	printCostSummary(&buf, instances)
+	if hasCostEstimates(instances) || buf.Len() != 0 {
+		t.Errorf("Expected no estimates without --with-cost, got summary %q", buf.String())
+	}
+
+	if got := formatDollars(1234.5); got != "$1,234.50" {
+		t.Errorf("formatDollars(1234.5) = %q", got)
+	}
+}
+
+// TestCreatedAfter tests --created-after parsing and filtering by creation time
+func TestCreatedAfter(t *testing.T) {
+	if got, err := parseCreatedAfter(""); err != nil || !got.IsZero() {
+		t.Errorf("parseCreatedAfter(\"\") = %v, %v, want no filter", got, err)
+	}
+	if got, err := parseCreatedAfter("2025-03-01"); err != nil || !got.Equal(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("parseCreatedAfter(date) = %v, %v", got, err)
+	}
+	if _, err := parseCreatedAfter("2025-03-01T12:00:00Z"); err != nil {
+		t.Errorf("parseCreatedAfter(RFC 3339) should not error: %v", err)
+	}
+	if _, err := parseCreatedAfter("last week"); err == nil {
+		t.Error("Expected an error for an unparseable date")
+	}
+
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<DescribeInstancesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><reservationSet><item><instancesSet>`+
+			`<item><instanceId>i-new</instanceId><launchTime>2025-06-01T00:00:00Z</launchTime><instanceState><name>running</name></instanceState></item>`+
+			`<item><instanceId>i-legacy</instanceId><launchTime>2019-06-01T00:00:00Z</launchTime><instanceState><name>running</name></instanceState></item>`+
+			`<item><instanceId>i-unknown</instanceId><instanceState><name>running</name></instanceState></item>`+
+			`</instancesSet></item></reservationSet></DescribeInstancesResponse>`)
+	})
+	createdAfter, _ := parseCreatedAfter("2025-03-01")
+	config := &Config{EC2Client: client, Describe: newDescribeCache(&fakeDescribeClient{}), CreatedAfter: createdAfter}
+	instances, err := findUntaggedInstances(context.Background(), config)
+	if err != nil {
+		t.Fatalf("findUntaggedInstances should not error: %v", err)
+	}
+	if len(instances) != 1 || instances[0].ID != "i-new" {
+		t.Errorf("Expected only i-new, got %+v", instances)
+	}
+
+	if shouldScanType(config, "vpc") || !shouldScanType(config, "volume") {
+		t.Error("With --created-after only types with a creation time should be scanned")
+	}
+	if !shouldScanType(&Config{}, "vpc") {
+		t.Error("Without --created-after every type should be scanned")
+	}
+}
+
+// TestScanRegions tests scanning several regions concurrently and merging their results
+func TestScanRegions(t *testing.T) {
+	quiet = true
+	defer func() { quiet = false }()
+
+	vpcClient := func(vpcID string) *ec2.Client {
+		return newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `<DescribeVpcsResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><vpcSet>`+
+				`<item><vpcId>%s</vpcId><cidrBlock>10.0.0.0/16</cidrBlock><state>available</state></item>`+
+				`</vpcSet></DescribeVpcsResponse>`, vpcID)
+		})
+	}
+	clients := map[string]*ec2.Client{"us-east-1": vpcClient("vpc-east"), "eu-west-1": vpcClient("vpc-west")}
+
+	config := &Config{
+		Regions:   []string{"us-east-1", "eu-west-1"},
+		TargetIDs: map[string][]string{"vpc": {"vpc-east", "vpc-west"}},
+	}
+	config.ForRegion = func(region string) *Config {
+		scoped := *config
+		scoped.Region = region
+		scoped.EC2Client = clients[region]
+		return &scoped
+	}
+
+	resources, err := findUntaggedResources(context.Background(), config)
+	if err != nil {
+		t.Fatalf("findUntaggedResources should not error: %v", err)
+	}
+	var got []string
+	for _, resource := range resources {
+		got = append(got, resource.Region+"/"+resource.ID)
+	}
+	if !slices.Equal(got, []string{"us-east-1/vpc-east", "eu-west-1/vpc-west"}) {
+		t.Errorf("Expected results merged in region order, got %v", got)
+	}
+
+	var buf bytes.Buffer
+	printResourceTable(&buf, resources)
+	if !strings.Contains(buf.String(), "Region") || !strings.Contains(buf.String(), "eu-west-1") {
+		t.Errorf("Expected a Region column, got:\n%s", buf.String())
+	}
+
+	// Streaming sets the region too
+	var out safeBuffer
+	if streamed, err := streamResources(context.Background(), config, &out); err != nil || streamed != 2 || !strings.Contains(out.String(), `"Region":"eu-west-1"`) {
+		t.Errorf("streamResources across regions = %d, %v, %q", streamed, err, out.String())
+	}
+
+	// One failing region fails the scan, naming the region
+	clients["eu-west-1"] = newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `<Response><Errors><Error><Code>UnauthorizedOperation</Code><Message>denied</Message></Error></Errors><RequestID>test</RequestID></Response>`)
+	})
+	if _, err := findUntaggedResources(context.Background(), config); err == nil || !strings.HasPrefix(err.Error(), "eu-west-1: ") {
+		t.Errorf("Expected an error naming eu-west-1, got %v", err)
+	}
+
+	// ...and cancels the regions still scanning instead of waiting for them
+	release := make(chan struct{})
+	defer close(release)
+	clients["us-east-1"] = newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-time.After(5 * time.Second):
+		}
+	})
+	start := time.Now()
+	if _, err := findUntaggedResources(context.Background(), config); err == nil || !strings.HasPrefix(err.Error(), "eu-west-1: ") {
+		t.Errorf("Expected an error naming eu-west-1, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the slow region to be cancelled, but the scan took %s", elapsed)
+	}
+}
+
+// TestSkipAWSDefaults tests that --skip-aws-defaults leaves ENIs with AWS default names alone
+func TestSkipAWSDefaults(t *testing.T) {
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<DescribeNetworkInterfacesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><networkInterfaceSet>`+
+			`<item><networkInterfaceId>eni-0abc1234</networkInterfaceId><status>available</status><tagSet><item><key>Name</key><value>eni-0abc1234</value></item></tagSet></item>`+
+			`<item><networkInterfaceId>eni-0def5678</networkInterfaceId><status>available</status><tagSet><item><key>Name</key><value>Network interface for test</value></item></tagSet></item>`+
+			`<item><networkInterfaceId>eni-0aaa9999</networkInterfaceId><status>in-use</status><tagSet><item><key>Name</key><value>Primary network interface</value></item></tagSet></item>`+
+			`<item><networkInterfaceId>eni-untagged</networkInterfaceId><status>available</status></item>`+
+			`</networkInterfaceSet></DescribeNetworkInterfacesResponse>`)
+	})
+
+	for _, tt := range []struct {
+		skip     bool
+		expected []string
+	}{
+		{false, []string{"eni-0aaa9999", "eni-0abc1234", "eni-0def5678", "eni-untagged"}},
+		{true, []string{"eni-untagged"}},
+	} {
+		// A name policy is what flags AWS default names; their shape alone doesn't
+		policy := namePolicy{"eni": regexp.MustCompile(`^(prod|dev)-`)}
+		config := &Config{EC2Client: client, Describe: newDescribeCache(&fakeDescribeClient{}), NamePolicy: policy, SkipAWSDefaults: tt.skip}
+		enis, err := findUntaggedENIs(context.Background(), config)
+		if err != nil {
+			t.Fatalf("findUntaggedENIs should not error: %v", err)
+		}
+		var ids []string
+		for _, eni := range enis {
+			ids = append(ids, eni.ID)
+		}
+		slices.Sort(ids)
+		if !slices.Equal(ids, tt.expected) {
+			t.Errorf("With SkipAWSDefaults=%v found %v, want %v", tt.skip, ids, tt.expected)
+		}
+	}
+
+	if !naming.IsAWSDefaultName("PRIMARY NETWORK INTERFACE", "eni") || naming.IsAWSDefaultName("primary-db", "eni") {
+		t.Error("Primary network interface names should match in any case, and only that phrase")
+	}
+	if naming.IsAWSDefaultName("", "eni") || naming.IsAWSDefaultName("eni-0abc1234", "instance") {
+		t.Error("Empty names and non-ENI types have no AWS defaults")
+	}
+}
+
+// TestPreviewNames tests that --preview-names keeps named resources and reports how each name compares
+func TestPreviewNames(t *testing.T) {
+	matching := naming.VPCName("10.2.0.0/16", false)
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<DescribeVpcsResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><vpcSet>`+
+			`<item><vpcId>vpc-app</vpcId><cidrBlock>10.0.0.0/16</cidrBlock><state>available</state><isDefault>false</isDefault></item>`+
+			`<item><vpcId>vpc-named</vpcId><cidrBlock>10.1.0.0/16</cidrBlock><state>available</state><isDefault>false</isDefault>`+
+			`<tagSet><item><key>Name</key><value>shared-services</value></item></tagSet></item>`+
+			`<item><vpcId>vpc-match</vpcId><cidrBlock>10.2.0.0/16</cidrBlock><state>available</state><isDefault>false</isDefault>`+
+			`<tagSet><item><key>Name</key><value>`+matching+`</value></item></tagSet></item>`+
+			`</vpcSet></DescribeVpcsResponse>`)
+	})
+
+	vpcs, err := findUntaggedVPCs(context.Background(), &Config{EC2Client: client})
+	if err != nil || len(vpcs) != 1 {
+		t.Fatalf("Without --preview-names expected only the unnamed VPC, got %d (%v)", len(vpcs), err)
+	}
+
+	vpcs, err = findUntaggedVPCs(context.Background(), &Config{EC2Client: client, PreviewNames: true})
+	if err != nil || len(vpcs) != 3 {
+		t.Fatalf("With --preview-names expected all 3 VPCs, got %d (%v)", len(vpcs), err)
+	}
+
+	var buf bytes.Buffer
+	printNamePreview(&buf, vpcs)
+	out := buf.String()
+	for _, want := range []string{"vpc-app", "untagged", "set", "shared-services", "rename", matching, "keep", "3 resources: 1 already match, 1 unnamed, 1 named differently"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Preview output missing %q:\n%s", want, out)
+		}
+	}
+
+	buf.Reset()
+	printNamePreview(&buf, nil)
+	if !strings.Contains(buf.String(), "No resources found") {
+		t.Errorf("Expected a message for an empty preview, got %q", buf.String())
+	}
+
+	// The preview is read-only, so it runs without a terminal on stdin (CI, </dev/null)
+	if willPrompt(false, 0, "", false, true) {
+		t.Error("--preview-names should not need a terminal")
+	}
+	if !willPrompt(false, 0, "", false, false) || willPrompt(true, 0, "", false, false) || willPrompt(false, 0, "table", false, false) || willPrompt(false, 0, "", true, false) {
+		t.Error("Only interactive tagging runs should need a terminal")
+	}
+}
+
+// TestSkipUntaggable tests that every scanned type has a tagger and other types are skipped
+func TestSkipUntaggable(t *testing.T) {
+	quiet = true
+	defer func() { quiet = false }()
+
+	for _, resourceType := range resourceTypeOrder {
+		if _, ok := taggers[resourceType]; !ok {
+			t.Errorf("Resource type %s has no tagger", resourceType)
+		}
+	}
+
+	resources := []*ResourceInfo{
+		{ID: "i-1", Type: "instance"},
+		{ID: "fn-1", Type: "lambda"},
+		{ID: "key-1", Type: "kms-key"},
+	}
+	var ids []string
+	for _, resource := range skipUntaggable(resources) {
+		ids = append(ids, resource.ID)
+	}
+	if !slices.Equal(ids, []string{"i-1", "key-1"}) {
+		t.Errorf("skipUntaggable() kept %v, want [i-1 key-1]", ids)
+	}
+	if len(resources) != 3 {
+		t.Error("skipUntaggable should not modify its input")
+	}
+
+	applied, err := applyTags(context.Background(), &Config{AssumeYes: true}, []*ResourceInfo{{ID: "fn-1", Type: "lambda"}}, "123456789012", "run-test", true)
+	if err != nil || applied != 0 {
+		t.Errorf("applyTags with only untaggable resources = %d, %v, want 0, nil", applied, err)
+	}
+}
+
+// TestStreamResources tests that --output ndjson writes each page of resources before the next is fetched
+func TestStreamResources(t *testing.T) {
+	quiet = true
+	defer func() { quiet = false }()
+
+	var out safeBuffer
+	var streamedBeforePage2 string
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("NextToken") == "" {
+			fmt.Fprint(w, `<DescribeVpcsResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><vpcSet>`+
+				`<item><vpcId>vpc-1</vpcId><cidrBlock>10.0.0.0/16</cidrBlock><state>available</state></item>`+
+				`</vpcSet><nextToken>page2</nextToken></DescribeVpcsResponse>`)
+			return
+		}
+		streamedBeforePage2 = out.String()
+		fmt.Fprint(w, `<DescribeVpcsResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><vpcSet>`+
+			`<item><vpcId>vpc-2</vpcId><cidrBlock>10.1.0.0/16</cidrBlock><state>pending</state></item>`+
+			`</vpcSet></DescribeVpcsResponse>`)
+	})
+
+	config := &Config{
+		EC2Client: client,
+		TargetIDs: map[string][]string{"vpc": {"vpc-1", "vpc-2"}},
+		TagPrefix: "prod-",
+	}
+	streamed, err := streamResources(context.Background(), config, &out)
+	if err != nil {
+		t.Fatalf("streamResources should not error: %v", err)
+	}
+	if streamed != 2 {
+		t.Errorf("Expected 2 resources streamed, got %d", streamed)
+	}
+	if !strings.Contains(streamedBeforePage2, `"vpc-1"`) {
+		t.Errorf("Expected vpc-1 written before the second page was fetched, got %q", streamedBeforePage2)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected one line per resource, got %q", out.String())
+	}
+	var resource ResourceInfo
+	if err := json.Unmarshal([]byte(lines[1]), &resource); err != nil {
+		t.Fatalf("Each line should be a JSON object: %v", err)
+	}
+	if resource.ID != "vpc-2" || !strings.HasPrefix(resource.SuggestedName, "prod-") {
+		t.Errorf("Expected vpc-2 with a prefixed name, got %+v", resource)
+	}
+
+	// --state applies while streaming too
+	out = safeBuffer{}
+	config.States = []string{"pending"}
+	if streamed, err := streamResources(context.Background(), config, &out); err != nil || streamed != 1 {
+		t.Errorf("Expected only vpc-2 streamed with --state pending, got %d (%v)", streamed, err)
+	}
+}
+
+// TestAttachmentFilter tests that --only-attached and --only-unattached filter volumes and ENIs
+func TestAttachmentFilter(t *testing.T) {
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("Action") {
+		case "DescribeVolumes":
+			fmt.Fprint(w, `<DescribeVolumesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><volumeSet>`+
+				`<item><volumeId>vol-attached</volumeId><status>in-use</status><attachmentSet><item><instanceId>i-1</instanceId><device>/dev/xvdf</device></item></attachmentSet></item>`+
+				`<item><volumeId>vol-orphan</volumeId><status>available</status></item>`+
+				`</volumeSet></DescribeVolumesResponse>`)
+		case "DescribeNetworkInterfaces":
+			fmt.Fprint(w, `<DescribeNetworkInterfacesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><networkInterfaceSet>`+
+				`<item><networkInterfaceId>eni-attached</networkInterfaceId><status>in-use</status><attachment><attachmentId>eni-attach-1</attachmentId><instanceId>i-1</instanceId></attachment></item>`+
+				`<item><networkInterfaceId>eni-orphan</networkInterfaceId><status>available</status></item>`+
+				`</networkInterfaceSet></DescribeNetworkInterfacesResponse>`)
+		}
+	})
+
+	tests := []struct {
+		attachment string
+		expected   []string
+	}{
+		{"", []string{"eni-attached", "eni-orphan", "vol-attached", "vol-orphan"}},
+		{"attached", []string{"eni-attached", "vol-attached"}},
+		{"unattached", []string{"eni-orphan", "vol-orphan"}},
+	}
+	for _, test := range tests {
+		config := &Config{EC2Client: client, Describe: newDescribeCache(&fakeDescribeClient{}), Attachment: test.attachment}
+		volumes, err := findUntaggedVolumes(context.Background(), config)
+		if err != nil {
+			t.Fatalf("findUntaggedVolumes should not error: %v", err)
+		}
+		enis, err := findUntaggedENIs(context.Background(), config)
+		if err != nil {
+			t.Fatalf("findUntaggedENIs should not error: %v", err)
+		}
+		var ids []string
+		for _, resource := range append(volumes, enis...) {
+			ids = append(ids, resource.ID)
+		}
+		slices.Sort(ids)
+		if !slices.Equal(ids, test.expected) {
+			t.Errorf("Attachment %q found %v, want %v", test.attachment, ids, test.expected)
+		}
+	}
+}
+
+// TestMaxPages tests that --max-pages stops a scan's pagination early
+func TestMaxPages(t *testing.T) {
+	requests := 0
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintf(w, `<DescribeVpcsResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><vpcSet>`+
+			`<item><vpcId>vpc-%d</vpcId><cidrBlock>10.%d.0.0/16</cidrBlock><state>available</state></item>`+
+			`</vpcSet><nextToken>page-%d</nextToken></DescribeVpcsResponse>`, requests, requests, requests)
+	})
+
+	vpcs, err := findUntaggedVPCs(context.Background(), &Config{EC2Client: client, MaxPages: 2})
+	if err != nil {
+		t.Fatalf("findUntaggedVPCs should not error: %v", err)
+	}
+	if requests != 2 || len(vpcs) != 2 {
+		t.Errorf("With --max-pages 2, made %d requests and found %d VPCs, want 2 and 2", requests, len(vpcs))
+	}
+
+	if morePages(true, 100, 0, "DescribeVpcs") != true || morePages(false, 0, 0, "DescribeVpcs") != false {
+		t.Error("morePages should follow the paginator when there is no limit")
+	}
+}
+
+// TestPageSize tests --page-size validation and how it maps to MaxResults for each scan
+func TestPageSize(t *testing.T) {
+	quiet = true
+	defer func() { quiet = false }()
+
+	for _, size := range []int{0, 5, 500, 1000} {
+		if err := validatePageSize(size); err != nil {
+			t.Errorf("validatePageSize(%d) should not error: %v", size, err)
+		}
+	}
+	for _, size := range []int{-1, 4, 1001} {
+		if err := validatePageSize(size); err == nil {
+			t.Errorf("validatePageSize(%d) should error", size)
+		}
+	}
+
+	config := &Config{PageSize: 800}
+	if got := aws.ToInt32(config.pageSize("DescribeInstances", nil)); got != 800 {
+		t.Errorf("DescribeInstances page size = %d, want 800", got)
+	}
+	if got := aws.ToInt32(config.pageSize("DescribeVolumes", nil)); got != 500 {
+		t.Errorf("DescribeVolumes page size = %d, want it capped at 500", got)
+	}
+	if got := config.pageSize("DescribeInstances", []string{"i-1"}); got != nil {
+		t.Errorf("Expected no page size alongside explicit IDs, got %d", *got)
+	}
+	if got := (&Config{}).pageSize("DescribeInstances", nil); got != nil {
+		t.Errorf("Expected the API default without --page-size, got %d", *got)
+	}
+
+	var maxResults string
+	client := newTestEC2Client(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		maxResults = r.Form.Get("MaxResults")
+		fmt.Fprint(w, `<DescribeVolumesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>test</requestId><volumeSet></volumeSet></DescribeVolumesResponse>`)
+	})
+	if _, err := findUntaggedVolumes(context.Background(), &Config{EC2Client: client, Describe: newDescribeCache(&fakeDescribeClient{}), PageSize: 800}); err != nil {
+		t.Fatalf("findUntaggedVolumes should not error: %v", err)
+	}
+	if maxResults != "500" {
+		t.Errorf("DescribeVolumes sent MaxResults=%q, want 500", maxResults)
 	}
-	return false
 }
@@ -0,0 +1,509 @@
+// Package naming builds the Name tags quick-tag suggests for EC2 resources and recognizes
+// names it generated earlier. Resource types are "instance", "volume", "eni", "eip", "vpc",
+// "subnet", "route-table", "nat-gateway", "igw", "tgw", and "kms-key".
+package naming
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// MaxTagValueLength is the maximum length of an EC2 tag value in Unicode characters
+const MaxTagValueLength = 256
+
+// Truncate joins prefix, middle, and suffix into a name that fits in a tag value.
+// When too long, the middle segment is trimmed and marked with an ellipsis so the resource
+// identifier prefix and the suffix (mount point, "-eni") are preserved.
+func Truncate(prefix, middle, suffix string) string {
+	const ellipsis = "..."
+
+	name := prefix + middle + suffix
+	if utf8.RuneCountInString(name) <= MaxTagValueLength {
+		return name
+	}
+
+	budget := MaxTagValueLength - utf8.RuneCountInString(prefix) - utf8.RuneCountInString(suffix) - len(ellipsis)
+	if budget <= 0 {
+		// Prefix and suffix alone are too long, so fall back to cutting the end
+		runes := []rune(name)
+		return string(runes[:MaxTagValueLength-len(ellipsis)]) + ellipsis
+	}
+
+	return prefix + string([]rune(middle)[:budget]) + ellipsis + suffix
+}
+
+// templateTagPattern matches ${Key} references in a name template
+var templateTagPattern = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// ExpandTemplate builds a name from a template like "${Service}-${Environment}" by replacing
+// each ${Key} with the value of that tag. Tags that are missing or empty become "missing-Key"
+// so the gap stands out, and their keys are returned in template order.
+func ExpandTemplate(template string, tags map[string]string) (name string, missing []string) {
+	name = templateTagPattern.ReplaceAllStringFunc(template, func(ref string) string {
+		key := templateTagPattern.FindStringSubmatch(ref)[1]
+		if value := tags[key]; value != "" {
+			return value
+		}
+		if !slices.Contains(missing, key) {
+			missing = append(missing, key)
+		}
+		return "missing-" + key
+	})
+	return Truncate("", name, ""), missing
+}
+
+// InstanceDetails formats an instance's type and platform for --verbose-names,
+// e.g. "t3.large, linux"
+func InstanceDetails(instanceType, platformDetails string) string {
+	var parts []string
+	if instanceType != "" {
+		parts = append(parts, instanceType)
+	}
+	platform := strings.ToLower(platformDetails)
+	switch {
+	case platform == "linux/unix":
+		platform = "linux"
+	case strings.HasPrefix(platform, "windows"):
+		platform = "windows"
+	}
+	if platform != "" {
+		parts = append(parts, platform)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// VolumeDetails formats a volume's type and size for --verbose-names, e.g. "gp3-100GiB"
+func VolumeDetails(volumeType string, sizeGiB int32) string {
+	var parts []string
+	if volumeType != "" {
+		parts = append(parts, volumeType)
+	}
+	if sizeGiB > 0 {
+		parts = append(parts, fmt.Sprintf("%dGiB", sizeGiB))
+	}
+	return strings.Join(parts, "-")
+}
+
+// VolumeMountPoint extracts the mount point from volume attachments
+func VolumeMountPoint(volume types.Volume) string {
+	if len(volume.Attachments) == 0 {
+		return "unattached"
+	}
+
+	attachment := volume.Attachments[0]
+	if attachment.Device != nil {
+		return *attachment.Device
+	}
+
+	return "unknown"
+}
+
+// ExtractELBName extracts the load balancer name from ENI description
+func ExtractELBName(description string) string {
+	// ELB descriptions typically look like: "ELB app/canvas-lb-sbx/35b9ec36d721abfe"
+	// or "ELB net/my-lb/1234567890abcdef"
+	// We want to extract the load balancer name (canvas-lb-sbx or my-lb)
+
+	// Split by spaces and look for the ELB pattern
+	parts := strings.Fields(description)
+	for i, part := range parts {
+		if strings.ToLower(part) == "elb" && i+1 < len(parts) {
+			// The next part should be the ELB ARN or name
+			elbPart := parts[i+1]
+			// Split by '/' to get the name part
+			elbParts := strings.Split(elbPart, "/")
+			if len(elbParts) >= 2 {
+				// Return the load balancer name (second part after the type)
+				return elbParts[1]
+			}
+		}
+	}
+	return ""
+}
+
+// ENIAttachmentInfo describes what an ENI is attached to: "unattached", "attached-to-<instance ID>",
+// "attached-elb-<load balancer name>", or "attached-<service>-<attachment ID>"
+func ENIAttachmentInfo(eni types.NetworkInterface) string {
+	if eni.Attachment == nil {
+		return "unattached"
+	}
+
+	// Handle EC2 instance attachments
+	if eni.Attachment.InstanceId != nil {
+		return fmt.Sprintf("attached-to-%s", *eni.Attachment.InstanceId)
+	}
+
+	// Handle service attachments (NAT, RDS, ElastiCache, ELB, Lambda, etc.)
+	if eni.Attachment.AttachmentId != nil {
+		// Try to determine the attachment type from the description
+		attachmentType := "service"
+		if eni.Description != nil {
+			desc := strings.ToLower(*eni.Description)
+			if strings.Contains(desc, "lambda") {
+				attachmentType = "lambda"
+			} else if strings.Contains(desc, "rds") {
+				attachmentType = "rds"
+			} else if strings.Contains(desc, "elasticache") || strings.Contains(desc, "cache") {
+				attachmentType = "elasticache"
+			} else if strings.Contains(desc, "elb") || strings.Contains(desc, "load balancer") {
+				attachmentType = "elb"
+				// For ELB, try to extract the load balancer name
+				if elbName := ExtractELBName(*eni.Description); elbName != "" {
+					return fmt.Sprintf("attached-elb-%s", elbName)
+				}
+			} else if strings.Contains(desc, "nat") {
+				attachmentType = "nat"
+			}
+		}
+		return fmt.Sprintf("attached-%s-%s", attachmentType, *eni.Attachment.AttachmentId)
+	}
+
+	return "attached-unknown"
+}
+
+// generatedENINamePattern matches the ENI names quick-tag generates for service attachments:
+// a known service prefix followed by either a single ID token ("rds-abc-eni") or a full
+// attachment ID ("rds-ela-attach-0abc1234-eni", or without "-eni" from older versions).
+// Names with any other shape are treated as human-chosen.
+var generatedENINamePattern = regexp.MustCompile(`^(service|attached|lambda|rds|elasticache|elb|nat)-(` +
+	`[0-9a-z]+-eni|` +
+	`(eni|ela)-attach-[0-9a-z]+(-eni)?)$`)
+
+// eniIDPattern matches a bare ENI ID with either the short or long hex suffix
+var eniIDPattern = regexp.MustCompile(`^eni-([0-9a-f]{8}|[0-9a-f]{17})$`)
+
+// generatedVPCNamePattern matches VPCName names like "default-vpc (172.31.0.0/16)" or "vpc (10.0.0.0/16)"
+var generatedVPCNamePattern = regexp.MustCompile(`^(default-)?vpc \([0-9]{1,3}(\.[0-9]{1,3}){3}/[0-9]{1,2}\)$`)
+
+// generatedSubnetNamePattern matches SubnetName names like "vpc-0abc1234-10.0.1.0/24"
+var generatedSubnetNamePattern = regexp.MustCompile(`^vpc-[0-9a-f]+-[0-9]{1,3}(\.[0-9]{1,3}){3}/[0-9]{1,2}$`)
+
+// generatedRouteTableNamePattern matches RouteTableName names like "vpc-0abc1234-main-rtb",
+// "subnet-0abc1234-rtb", or "vpc-0abc1234-unassociated-rtb"
+var generatedRouteTableNamePattern = regexp.MustCompile(`^(vpc-[0-9a-f]+-(main|unassociated)|subnet-[0-9a-f]+)-rtb$`)
+
+// generatedNATGatewayNamePattern matches NATGatewayName names like "nat-subnet-0abc1234",
+// "nat-vpc-0abc1234", "nat-203.0.113.10", or "nat-gateway"
+var generatedNATGatewayNamePattern = regexp.MustCompile(`^nat-(subnet-[0-9a-f]+|vpc-[0-9a-f]+|[0-9]{1,3}(\.[0-9]{1,3}){3}|gateway)$`)
+
+// generatedInternetGatewayNamePattern matches InternetGatewayName names like "vpc-0abc1234-igw"
+// or "detached-igw"
+var generatedInternetGatewayNamePattern = regexp.MustCompile(`^(vpc-[0-9a-f]+|detached)-igw$`)
+
+// generatedTransitGatewayNamePattern matches TransitGatewayName names like "vpc-0abc1234-tgw",
+// "tgw-3-vpcs", or "unattached-tgw"
+var generatedTransitGatewayNamePattern = regexp.MustCompile(`^(vpc-[0-9a-f]+-tgw|tgw-[0-9]+-vpcs|unattached-tgw)$`)
+
+// generatedKMSKeyNamePattern matches the KMSKeyName fallback for keys with no alias or
+// description, e.g. "kms-1234abcd-12ab-34cd-56ef-1234567890ab"
+var generatedKMSKeyNamePattern = regexp.MustCompile(`^kms-([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}|mrk-[0-9a-f]{32})$`)
+
+// IsAWSDefaultName reports whether a name matches a pattern AWS fills in itself rather than
+// a name someone chose. Only ENIs have these: a bare ENI ID, a description like
+// "Network interface for ...", or "Primary network interface" in any case. Empty names don't count.
+func IsAWSDefaultName(name, resourceType string) bool {
+	if name == "" || resourceType != "eni" {
+		return false
+	}
+	return eniIDPattern.MatchString(name) || // Just the ENI ID itself
+		strings.HasPrefix(name, "Network interface") || // AWS default description-based names
+		strings.Contains(strings.ToLower(name), "primary network interface") // e.g. "Primary network interface"
+}
+
+// genericNames are the suggested names that only describe a state, so every resource in that
+// state gets the same one
+var genericNames = map[string]bool{
+	"unattached":       true,
+	"unattached-eni":   true,
+	"unassociated-eip": true,
+	"nat-gateway":      true,
+	"detached-igw":     true,
+	"unattached-tgw":   true,
+}
+
+// IsGenericName reports whether a suggested name says nothing about which resource it names,
+// e.g. "unattached" or "unattached gp3-100GiB", so it can't tell resources apart in the console
+func IsGenericName(name string) bool {
+	return genericNames[name] || strings.HasPrefix(name, "unattached ")
+}
+
+// IsQuickTagCreatedName checks if a name was created by quick-tag, judging by its shape alone
+func IsQuickTagCreatedName(name, resourceType string) bool {
+	switch resourceType {
+	case "instance":
+		// Check for quick-tag created instance names like "instance-ami-12345678" or "instance-i-12345678"
+		return strings.HasPrefix(name, "instance-ami-") ||
+			strings.HasPrefix(name, "instance-i-") ||
+			strings.HasPrefix(name, "unknown-instance")
+	case "volume":
+		// Check for quick-tag created volume names like "unattached", "unattached-/dev/xvda1", "unattached gp3-100GiB"
+		return name == "unattached" ||
+			strings.HasPrefix(name, "unattached-") ||
+			strings.HasPrefix(name, "unattached ") ||
+			strings.HasPrefix(name, "unknown-")
+	case "eni":
+		// Check for quick-tag created ENI names like "unattached-eni", "rds-ela-attach-0abc1234-eni"
+		return name == "unattached-eni" ||
+			generatedENINamePattern.MatchString(name) ||
+			name == "" || // Empty name
+			IsAWSDefaultName(name, "eni")
+	case "eip":
+		// Check for the quick-tag created name for Elastic IPs that aren't associated
+		return name == "unassociated-eip"
+	case "vpc":
+		return generatedVPCNamePattern.MatchString(name)
+	case "subnet":
+		return generatedSubnetNamePattern.MatchString(name)
+	case "route-table":
+		return generatedRouteTableNamePattern.MatchString(name)
+	case "nat-gateway":
+		return generatedNATGatewayNamePattern.MatchString(name)
+	case "igw":
+		return generatedInternetGatewayNamePattern.MatchString(name)
+	case "tgw":
+		return generatedTransitGatewayNamePattern.MatchString(name)
+	case "kms-key":
+		return generatedKMSKeyNamePattern.MatchString(name)
+	}
+	return false
+}
+
+// IsQuickTagNameStillValid checks if a quick-tag created name is still valid for the current resource state.
+// currentState is the instance or volume state, ENI status, or "associated"/"unassociated" for an
+// Elastic IP; extraInfo is the volume mount point, ENI attachment info, or route table association
+// ("main", a subnet ID, or "unassociated"), and unused otherwise.
+func IsQuickTagNameStillValid(name, resourceType, currentState, extraInfo string) bool {
+	if !IsQuickTagCreatedName(name, resourceType) {
+		return true // Not a quick-tag created name, so it's valid
+	}
+	return GeneratedNameMatchesState(name, resourceType, currentState, extraInfo)
+}
+
+// GeneratedNameMatchesState checks a name already known to be generated (by heuristic or
+// marker tag) against the resource's current state
+func GeneratedNameMatchesState(name, resourceType, currentState, extraInfo string) bool {
+	switch resourceType {
+	case "instance":
+		// For instances, quick-tag names are generally still valid unless the AMI changed
+		// This is a simple check - in practice, you might want to verify the AMI matches
+		return true
+	case "volume":
+		// For volumes, check if the attachment state matches the name
+		if name == "unattached" || strings.HasPrefix(name, "unattached ") {
+			// Name says unattached, check if it's actually unattached
+			return currentState == "available" || extraInfo == "unattached"
+		} else if strings.HasPrefix(name, "unattached-") {
+			// Name says unattached with mount point, check if it's actually unattached
+			return currentState == "available" || extraInfo == "unattached"
+		}
+		// For attached volumes, the name should match the current attachment
+		return true // For now, assume attached volume names are still valid
+	case "eni":
+		// For ENIs, check if the attachment state matches the name
+		if name == "unattached-eni" {
+			// Name says unattached, check if it's actually unattached
+			return extraInfo == "unattached"
+		} else if strings.HasPrefix(name, "unattached-") {
+			// Name says unattached, check if it's actually unattached
+			return extraInfo == "unattached"
+		} else if strings.Contains(name, "-eni") {
+			// For attached ENIs, check if the attachment info matches
+			// This is a simplified check - in practice, you might want more detailed validation
+			return extraInfo != "unattached"
+		}
+	case "eip":
+		// An "unassociated-eip" name is stale once the address gets associated
+		return currentState == "unassociated"
+	case "route-table":
+		// The name records the association, which changes as subnets are re-associated
+		switch {
+		case strings.HasSuffix(name, "-main-rtb"):
+			return extraInfo == "main"
+		case strings.HasSuffix(name, "-unassociated-rtb"):
+			return extraInfo == "unassociated"
+		}
+		return name == extraInfo+"-rtb"
+	case "igw", "tgw":
+		// extraInfo is the name the gateway's current attachments would get
+		return name == extraInfo
+	}
+	return true
+}
+
+// InstanceName suggests a Name for an instance from its AMI name, e.g. "web-ami (t3.large, linux)".
+// amiName is empty when the AMI couldn't be looked up, and imageID is empty when the instance
+// has no AMI reference; details is the optional InstanceDetails suffix.
+func InstanceName(instanceID, imageID, amiName, details string) string {
+	switch {
+	case imageID == "":
+		return fmt.Sprintf("instance-%s", instanceID)
+	case amiName == "":
+		return fmt.Sprintf("instance-%s", imageID)
+	}
+	suffix := ""
+	if details != "" {
+		suffix = fmt.Sprintf(" (%s)", details)
+	}
+	return Truncate("", amiName, suffix)
+}
+
+// VolumeName suggests a Name for a volume, e.g. "i-0abc(web) /dev/xvdf" or "unattached".
+// instanceID is empty for unattached volumes and instanceName is empty when the instance
+// couldn't be looked up; details is the optional VolumeDetails suffix.
+func VolumeName(instanceID, instanceName, mountPoint, details string) string {
+	suffix := ""
+	if details != "" {
+		suffix = " " + details
+	}
+
+	if instanceID == "" {
+		// For unattached volumes, just use "unattached" without duplicating; the size helps spot orphans
+		return "unattached" + suffix
+	}
+	if instanceName != "" {
+		return Truncate(instanceID, fmt.Sprintf("(%s)", instanceName), " "+mountPoint+suffix)
+	}
+	return fmt.Sprintf("%s %s%s", instanceID, mountPoint, suffix)
+}
+
+// ENIName suggests a Name for an ENI from its ENIAttachmentInfo, along with a short description
+// of the attachment for display. instanceNames maps attached instance IDs to their names.
+func ENIName(attachmentInfo string, instanceNames map[string]string) (name, attachment string) {
+	// Check if this ENI is attached to an EC2 instance
+	if strings.HasPrefix(attachmentInfo, "attached-to-") {
+		instanceID := strings.TrimPrefix(attachmentInfo, "attached-to-")
+		if instanceName := instanceNames[instanceID]; instanceName != "" {
+			return Truncate("", instanceName, "-eni"), fmt.Sprintf("%s (%s)", instanceID, instanceName)
+		}
+		return fmt.Sprintf("%s-eni", instanceID), instanceID
+	}
+
+	if strings.HasPrefix(attachmentInfo, "attached-") {
+		// For service attachments (NAT, RDS, ElastiCache, ELB, Lambda, etc.), extract the type and ID
+		parts := strings.SplitN(attachmentInfo, "-", 3) // attached-type-id or attached-elb-name
+		if len(parts) < 3 {
+			// Fallback for unexpected format
+			attachmentID := strings.TrimPrefix(attachmentInfo, "attached-")
+			return fmt.Sprintf("service-%s-eni", attachmentID), fmt.Sprintf("service-attachment-%s", attachmentID)
+		}
+
+		attachmentType := parts[1] // nat, rds, elasticache, elb, lambda, etc.
+		attachmentID := parts[2]   // the actual attachment ID or name
+		if attachmentType == "elb" {
+			// The ELB name was extracted from the description
+			return Truncate("", attachmentID, "-eni"), fmt.Sprintf("elb-%s", attachmentID)
+		}
+		return fmt.Sprintf("%s-%s-eni", attachmentType, attachmentID), fmt.Sprintf("%s-attachment-%s", attachmentType, attachmentID)
+	}
+
+	return "unattached-eni", "unattached"
+}
+
+// EIPName suggests a Name for an Elastic IP from what it's associated with: an instance
+// (instanceName may be empty when it couldn't be looked up), an ENI, or nothing
+func EIPName(instanceID, instanceName, eniID string) string {
+	switch {
+	case instanceName != "":
+		return Truncate("", instanceName, "-eip")
+	case instanceID != "":
+		return fmt.Sprintf("%s-eip", instanceID)
+	case eniID != "":
+		return fmt.Sprintf("%s-eip", eniID)
+	}
+	return "unassociated-eip"
+}
+
+// VPCName suggests a Name for a VPC from its CIDR block and whether it's the region's default VPC,
+// e.g. "default-vpc (172.31.0.0/16)" or "vpc (10.0.0.0/16)"
+func VPCName(cidrBlock string, isDefault bool) string {
+	if isDefault {
+		return fmt.Sprintf("default-vpc (%s)", cidrBlock)
+	}
+	return fmt.Sprintf("vpc (%s)", cidrBlock)
+}
+
+// SubnetName suggests a Name for a subnet from its VPC and CIDR block, e.g. "vpc-0abc1234-10.0.1.0/24"
+func SubnetName(vpcID, cidrBlock string) string {
+	return fmt.Sprintf("%s-%s", vpcID, cidrBlock)
+}
+
+// RouteTableName suggests a Name for a route table from its association: "main" for the
+// VPC's main route table, a subnet ID, or "unassociated"
+func RouteTableName(vpcID, association string) string {
+	switch association {
+	case "main", "unassociated":
+		return fmt.Sprintf("%s-%s-rtb", vpcID, association)
+	}
+	return fmt.Sprintf("%s-rtb", association)
+}
+
+// NATGatewayName suggests a Name for a NAT gateway from its subnet, which is unique per
+// availability zone, falling back to its VPC, then its public IP, e.g. "nat-subnet-0abc1234"
+func NATGatewayName(subnetID, vpcID, publicIP string) string {
+	switch {
+	case subnetID != "":
+		return "nat-" + subnetID
+	case vpcID != "":
+		return "nat-" + vpcID
+	case publicIP != "":
+		return "nat-" + publicIP
+	}
+	return "nat-gateway"
+}
+
+// InternetGatewayName suggests a Name for an internet gateway from the VPC it's attached to,
+// e.g. "vpc-0abc1234-igw", or "detached-igw" when it isn't attached
+func InternetGatewayName(vpcID string) string {
+	if vpcID == "" {
+		return "detached-igw"
+	}
+	return vpcID + "-igw"
+}
+
+// TransitGatewayName suggests a Name for a transit gateway from its VPC attachments: the VPC
+// when there's only one, e.g. "vpc-0abc1234-tgw", otherwise the count, e.g. "tgw-3-vpcs"
+func TransitGatewayName(vpcIDs []string) string {
+	switch len(vpcIDs) {
+	case 0:
+		return "unattached-tgw"
+	case 1:
+		return vpcIDs[0] + "-tgw"
+	}
+	return fmt.Sprintf("tgw-%d-vpcs", len(vpcIDs))
+}
+
+// KMSKeyName suggests a Name for a KMS key from its alias (without "alias/"), falling back to
+// its description, then its key ID, e.g. "app-secrets"
+func KMSKeyName(alias, description, keyID string) string {
+	switch {
+	case alias != "":
+		return Truncate("", alias, "")
+	case description != "":
+		return Truncate("", description, "")
+	}
+	return "kms-" + keyID
+}
+
+// RouteTableAssociation describes a route table's association for RouteTableName: "main" for the
+// VPC's main route table, otherwise the first associated subnet ID, or "unassociated"
+func RouteTableAssociation(routeTable types.RouteTable) string {
+	var subnetIDs []string
+	for _, association := range routeTable.Associations {
+		if association.Main != nil && *association.Main {
+			return "main"
+		}
+		if association.SubnetId != nil {
+			subnetIDs = append(subnetIDs, *association.SubnetId)
+		}
+	}
+	if len(subnetIDs) == 0 {
+		return "unassociated"
+	}
+	slices.Sort(subnetIDs)
+	return subnetIDs[0]
+}
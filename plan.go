@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	qc "github.com/bevelwork/quick_color"
+)
+
+// TagPlan is a saved set of tag changes written by --plan and executed later by --apply
+type TagPlan struct {
+	Account   string
+	Region    string
+	TagKey    string
+	CreatedAt string
+	Resources []*ResourceInfo
+}
+
+// newTagPlan builds a plan for the selected resources
+func newTagPlan(account, region string, resources []*ResourceInfo) *TagPlan {
+	return &TagPlan{
+		Account:   account,
+		Region:    region,
+		TagKey:    "Name",
+		CreatedAt: time.Now().Format(time.RFC3339),
+		Resources: resources,
+	}
+}
+
+// savePlan writes a plan file as indented JSON
+func savePlan(path string, plan *TagPlan) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal plan: %v", err)
+	}
+	if err := writeFileAtomic(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write plan file: %v", err)
+	}
+	return nil
+}
+
+// loadPlan reads and validates a plan file
+func loadPlan(path string) (*TagPlan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan file: %v", err)
+	}
+
+	var plan TagPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan file: %v", err)
+	}
+	if plan.TagKey != "Name" {
+		return nil, fmt.Errorf("unsupported tag key %q in plan file (expected Name)", plan.TagKey)
+	}
+	if plan.Region == "" {
+		return nil, fmt.Errorf("plan file is missing a region")
+	}
+	if len(plan.Resources) == 0 {
+		return nil, fmt.Errorf("plan file has no resources")
+	}
+	return &plan, nil
+}
+
+// maxDescribeTagsFilterValues is the most resource IDs sent in one DescribeTags filter
+const maxDescribeTagsFilterValues = 200
+
+// getTags returns the current values of the given tag keys on each resource that has any
+func getTags(ctx context.Context, client *ec2.Client, ids, keys []string) (map[string]map[string]string, error) {
+	tags := make(map[string]map[string]string)
+	for start := 0; start < len(ids); start += maxDescribeTagsFilterValues {
+		end := min(start+maxDescribeTagsFilterValues, len(ids))
+		paginator := ec2.NewDescribeTagsPaginator(client, &ec2.DescribeTagsInput{
+			Filters: []types.Filter{
+				{Name: stringPtr("resource-id"), Values: ids[start:end]},
+				{Name: stringPtr("key"), Values: keys},
+			},
+		})
+
+		debugf("DescribeTags: checking %s tags for %d resources", strings.Join(keys, ","), end-start)
+		for paginator.HasMorePages() {
+			output, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, wrapPermissionError(err, "ec2:DescribeTags", "tags")
+			}
+			for _, tag := range output.Tags {
+				if tag.ResourceId == nil || tag.Key == nil || tag.Value == nil {
+					continue
+				}
+				if tags[*tag.ResourceId] == nil {
+					tags[*tag.ResourceId] = make(map[string]string)
+				}
+				tags[*tag.ResourceId][*tag.Key] = *tag.Value
+			}
+		}
+	}
+	return tags, nil
+}
+
+// verifyPlan splits a plan into resources that still have the Name recorded at plan time
+// and resources whose Name changed since, which are skipped. Ready resources get their
+// current tags, so history records what --extra-tag keys overwrite.
+func verifyPlan(ctx context.Context, config *Config, plan *TagPlan) (ready, changed []*ResourceInfo, err error) {
+	var ec2IDs, kmsKeyIDs []string
+	for _, resource := range plan.Resources {
+		if resource.Type == "kms-key" {
+			kmsKeyIDs = append(kmsKeyIDs, resource.ID)
+		} else {
+			ec2IDs = append(ec2IDs, resource.ID)
+		}
+	}
+
+	current, err := getTags(ctx, config.EC2Client, ec2IDs, append([]string{"Name"}, tagKeys(config.ExtraTags)...))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(kmsKeyIDs) > 0 {
+		kmsTags, err := kmsResourceTags(ctx, config.KMSClient, kmsKeyIDs)
+		if err != nil {
+			return nil, nil, err
+		}
+		maps.Copy(current, kmsTags)
+	}
+
+	for _, resource := range plan.Resources {
+		if current[resource.ID]["Name"] != resource.Name {
+			changed = append(changed, resource)
+			continue
+		}
+		resource.Tags = current[resource.ID]
+		ready = append(ready, resource)
+	}
+	return ready, changed, nil
+}
+
+// applyPlan re-verifies a saved plan and applies the changes that are still valid
+func applyPlan(ctx context.Context, config *Config, plan *TagPlan, accountID, runID string) (int, error) {
+	stop := startThrobber("Verifying plan...")
+	ready, changed, err := verifyPlan(ctx, config, plan)
+	stop()
+	if err != nil {
+		return 0, fmt.Errorf("failed to verify plan: %v", err)
+	}
+
+	for _, resource := range changed {
+		fmt.Printf("%s Skipping %s %s: its Name changed since the plan was created\n", color("⚠️", qc.ColorYellow), resource.Type, resource.ID)
+	}
+	if len(ready) == 0 {
+		fmt.Printf("%s Nothing left to apply from this plan.\n", color("ℹ️", qc.ColorCyan))
+		return 0, nil
+	}
+
+	infof("Applying %d of %d planned changes from %s:\n", len(ready), len(plan.Resources), plan.CreatedAt)
+	return applyTags(ctx, config, ready, accountID, runID, true)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// namePolicy maps resource types to the pattern their Name tags must match (--name-policy)
+type namePolicy map[string]*regexp.Regexp
+
+// loadNamePolicy reads a --name-policy file: a YAML map of resource type to regex, e.g.
+//
+//	instance: '^(prod|dev)-[a-z0-9-]+$'
+//	volume: '^(prod|dev)-'
+func loadNamePolicy(path string) (namePolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read name policy file: %v", err)
+	}
+
+	var patterns map[string]string
+	if err := yaml.Unmarshal(data, &patterns); err != nil {
+		return nil, fmt.Errorf("failed to parse name policy file: %v", err)
+	}
+
+	policy := make(namePolicy)
+	for resourceType, pattern := range patterns {
+		if !slices.Contains(resourceTypeOrder, resourceType) {
+			return nil, fmt.Errorf("invalid name policy resource type %q: must be one of %s", resourceType, strings.Join(resourceTypeOrder, ", "))
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid name policy pattern for %s: %v", resourceType, err)
+		}
+		policy[resourceType] = re
+	}
+	return policy, nil
+}
+
+// violates reports whether an existing Name breaks the policy for resourceType. Empty names
+// are handled as untagged, and types without a pattern (or a nil policy) never violate it.
+func (p namePolicy) violates(resourceType, name string) bool {
+	re, ok := p[resourceType]
+	return ok && name != "" && !re.MatchString(name)
+}
+
+// warnNonCompliantSuggestions warns about suggested names that would themselves break the
+// policy, so they can be fixed up before applying
+func warnNonCompliantSuggestions(resources []*ResourceInfo, policy namePolicy) {
+	var ids []string
+	for _, resource := range resources {
+		if policy.violates(resource.Type, resource.SuggestedName) {
+			ids = append(ids, resource.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	sort.Strings(ids)
+	warnf("%d suggested names don't match --name-policy (%s); use --tag-prefix or review them before applying", len(ids), strings.Join(ids, ", "))
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"golang.org/x/sync/errgroup"
+)
+
+// knownRegions lists the AWS commercial regions. They complete --region and are accepted
+// without asking DescribeRegions.
+var knownRegions = []string{
+	"af-south-1",
+	"ap-east-1",
+	"ap-northeast-1", "ap-northeast-2", "ap-northeast-3",
+	"ap-south-1", "ap-south-2",
+	"ap-southeast-1", "ap-southeast-2", "ap-southeast-3", "ap-southeast-4",
+	"ca-central-1", "ca-west-1",
+	"eu-central-1", "eu-central-2",
+	"eu-north-1",
+	"eu-south-1", "eu-south-2",
+	"eu-west-1", "eu-west-2", "eu-west-3",
+	"il-central-1",
+	"me-central-1", "me-south-1",
+	"sa-east-1",
+	"us-east-1", "us-east-2",
+	"us-west-1", "us-west-2",
+}
+
+// regionLister is the part of the EC2 client used to list regions
+type regionLister interface {
+	DescribeRegions(ctx context.Context, params *ec2.DescribeRegionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRegionsOutput, error)
+}
+
+// regionCache holds the DescribeRegions result so it's only fetched once per process
+var regionCache struct {
+	mu    sync.Mutex
+	names []string
+}
+
+// listRegions returns every region DescribeRegions reports, including ones not opted in
+func listRegions(ctx context.Context, client regionLister) ([]string, error) {
+	regionCache.mu.Lock()
+	defer regionCache.mu.Unlock()
+	if regionCache.names != nil {
+		return regionCache.names, nil
+	}
+
+	debugf("DescribeRegions: listing all regions")
+	output, err := client.DescribeRegions(ctx, &ec2.DescribeRegionsInput{AllRegions: aws.Bool(true)})
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(output.Regions))
+	for _, region := range output.Regions {
+		names = append(names, aws.ToString(region.RegionName))
+	}
+	slices.Sort(names)
+	regionCache.names = names
+	return names, nil
+}
+
+// validateRegion catches --region typos before any scanning. Regions in knownRegions pass
+// straight away; others are checked against DescribeRegions. When the list can't be fetched
+// (no ec2:DescribeRegions permission, another partition), the region is given the benefit of the doubt.
+func validateRegion(ctx context.Context, region string, client regionLister) error {
+	if slices.Contains(knownRegions, region) {
+		return nil
+	}
+	regions, err := listRegions(ctx, client)
+	if err != nil {
+		debugf("DescribeRegions failed, not validating region %s: %v", region, err)
+		return nil
+	}
+	if slices.Contains(regions, region) {
+		return nil
+	}
+	return fmt.Errorf("invalid region %q: valid regions are %s", region, strings.Join(regions, ", "))
+}
+
+// findUntaggedResourcesInRegions scans each of config.Regions concurrently, each with its own
+// clients from config.ForRegion, and merges the results in the order the regions were given,
+// with Region set on every resource. One spinner lists the regions still in flight. The first
+// region to fail cancels the others, and its error is returned.
+func findUntaggedResourcesInRegions(ctx context.Context, config *Config) ([]*ResourceInfo, error) {
+	message := "Scanning regions..."
+	update, stop := startProgress(message)
+	defer stop()
+
+	var (
+		mu       sync.Mutex // guards everything below, and serializes config.Emit
+		inFlight = slices.Clone(config.Regions)
+		results  = make(map[string][]*ResourceInfo)
+	)
+	update(fmt.Sprintf("%s (%s)", message, strings.Join(inFlight, ", ")))
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	for _, region := range config.Regions {
+		group.Go(func() error {
+			scoped := config.ForRegion(region)
+			scoped.Regions = nil
+			scoped.NoSpinner = true
+			if config.Emit != nil {
+				scoped.Emit = func(resource *ResourceInfo) {
+					resource.Region = region
+					mu.Lock()
+					defer mu.Unlock()
+					config.Emit(resource)
+				}
+			}
+
+			resources, err := findUntaggedResources(groupCtx, scoped)
+			if err != nil {
+				return fmt.Errorf("%s: %w", region, err)
+			}
+			for _, resource := range resources {
+				resource.Region = region
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[region] = resources
+			inFlight = slices.DeleteFunc(inFlight, func(r string) bool { return r == region })
+			if len(inFlight) > 0 {
+				update(fmt.Sprintf("%s (%s)", message, strings.Join(inFlight, ", ")))
+			}
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+
+	var merged []*ResourceInfo
+	for _, region := range config.Regions {
+		merged = append(merged, results[region]...)
+	}
+	return merged, nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RunReport is the self-contained JSON summary of one run written by --report-file
+type RunReport struct {
+	RunID      string
+	Account    string
+	Region     string
+	StartedAt  string
+	FinishedAt string
+	Resources  []ReportEntry
+
+	mu sync.Mutex
+}
+
+// ReportEntry is the outcome of tagging one resource
+type ReportEntry struct {
+	ID      string
+	Type    string
+	OldName string
+	NewName string
+	Outcome string // "tagged", "failed", or "skipped"
+	Success bool
+	Error   string `json:",omitempty"`
+}
+
+// newRunReport starts a report for a run
+func newRunReport(runID, account, region string) *RunReport {
+	return &RunReport{
+		RunID:     runID,
+		Account:   account,
+		Region:    region,
+		StartedAt: time.Now().Format(time.RFC3339),
+		Resources: []ReportEntry{},
+	}
+}
+
+// recordTagging records the outcome of one CreateTags call for each resource in it. A nil
+// *RunReport ignores updates, so runs without --report-file don't need to check.
+func (r *RunReport) recordTagging(batch []*ResourceInfo, err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, resource := range batch {
+		entry := ReportEntry{
+			ID:      resource.ID,
+			Type:    resource.Type,
+			OldName: resource.Name,
+			NewName: resource.SuggestedName,
+			Outcome: "tagged",
+			Success: err == nil,
+		}
+		if err != nil {
+			entry.Outcome = "failed"
+			entry.Error = err.Error()
+		}
+		r.Resources = append(r.Resources, entry)
+	}
+}
+
+// recordSkipped records a resource that was selected but deliberately left untagged, with why
+func (r *RunReport) recordSkipped(resource *ResourceInfo, reason string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Resources = append(r.Resources, ReportEntry{
+		ID:      resource.ID,
+		Type:    resource.Type,
+		OldName: resource.Name,
+		NewName: resource.SuggestedName,
+		Outcome: "skipped",
+		Error:   reason,
+	})
+}
+
+// saveRunReport stamps the finish time and writes the report as indented JSON
+func saveRunReport(path string, r *RunReport) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.FinishedAt = time.Now().Format(time.RFC3339)
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report: %v", err)
+	}
+	if err := writeFileAtomic(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write report file: %v", err)
+	}
+	return nil
+}
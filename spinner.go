@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	qc "github.com/bevelwork/quick_color"
+)
+
+// spinnerStyles maps --spinner styles to their animation frames; "none" has no frames
+var spinnerStyles = map[string][]string{
+	"braille": qc.DefaultSpinnerFrames,
+	"dots":    {".  ", ".. ", "..."},
+	"line":    {"|", "/", "-", "\\"},
+	"none":    nil,
+}
+
+// spinnerConfig controls how progress spinners are drawn
+type spinnerConfig struct {
+	Frames   []string
+	Interval time.Duration
+}
+
+// spinner is the shared spinner configuration, set from --spinner and --spinner-interval
+var spinner = spinnerConfig{Frames: qc.DefaultSpinnerFrames, Interval: 100 * time.Millisecond}
+
+// newSpinnerConfig validates a spinner style and interval
+func newSpinnerConfig(style string, interval time.Duration) (spinnerConfig, error) {
+	frames, ok := spinnerStyles[style]
+	if !ok {
+		return spinnerConfig{}, fmt.Errorf("invalid --spinner %q: must be braille, dots, line, or none", style)
+	}
+	if interval <= 0 {
+		return spinnerConfig{}, fmt.Errorf("invalid --spinner-interval %s: must be positive", interval)
+	}
+	return spinnerConfig{Frames: frames, Interval: interval}, nil
+}
+
+// throbber draws spinner frames on one line until stopped
+type throbber struct {
+	writer io.Writer
+	config spinnerConfig
+	stopCh chan struct{}
+	doneCh chan struct{}
+
+	mu      sync.Mutex
+	message string
+}
+
+// newThrobber creates a throbber; call Start to begin drawing
+func newThrobber(w io.Writer, message string, config spinnerConfig) *throbber {
+	return &throbber{
+		writer:  w,
+		message: message,
+		config:  config,
+		stopCh:  make(chan struct{}),
+		doneCh:  make(chan struct{}),
+	}
+}
+
+// Start draws a frame on every tick in a goroutine
+func (t *throbber) Start() {
+	go func() {
+		defer close(t.doneCh)
+		ticker := time.NewTicker(t.config.Interval)
+		defer ticker.Stop()
+		for i := 0; ; {
+			select {
+			case <-t.stopCh:
+				// Clear the line
+				fmt.Fprint(t.writer, "\r\033[K")
+				return
+			case <-ticker.C:
+				t.mu.Lock()
+				message := t.message
+				t.mu.Unlock()
+				fmt.Fprintf(t.writer, "\r%s %s", t.config.Frames[i%len(t.config.Frames)], message)
+				i++
+			}
+		}
+	}()
+}
+
+// SetMessage changes the text drawn from the next frame on
+func (t *throbber) SetMessage(message string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.message = message
+}
+
+// Stop ends the animation and waits for the line to be cleared
+func (t *throbber) Stop() {
+	select {
+	case <-t.stopCh:
+		// already stopped
+	default:
+		close(t.stopCh)
+	}
+	<-t.doneCh
+}
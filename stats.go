@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	qc "github.com/bevelwork/quick_color"
+)
+
+// runStats counts scanned resources and AWS API calls for --stats. A nil *runStats
+// ignores updates, so code paths without stats don't need to check.
+type runStats struct {
+	mu             sync.Mutex
+	examined       map[string]int // Resources scanned, by type
+	needsName      map[string]int // Resources that needed a Name, by type
+	pages          map[string]int // Pages fetched, by API operation
+	createTagCalls int
+}
+
+// newRunStats creates empty stats
+func newRunStats() *runStats {
+	return &runStats{
+		examined:  make(map[string]int),
+		needsName: make(map[string]int),
+		pages:     make(map[string]int),
+	}
+}
+
+// recordScan records one finished scan of a resource type
+func (s *runStats) recordScan(operation, resourceType string, pages, examined, needsName int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pages[operation] += pages
+	s.examined[resourceType] += examined
+	s.needsName[resourceType] += needsName
+}
+
+// recordCreateTags records one CreateTags call
+func (s *runStats) recordCreateTags() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.createTagCalls++
+}
+
+// printStats writes the --stats report
+func printStats(w io.Writer, s *runStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fmt.Fprintf(w, "\n%s\n", color("📈 Run statistics:", qc.ColorBlue))
+	for _, resourceType := range resourceTypeOrder {
+		if _, scanned := s.examined[resourceType]; !scanned {
+			continue
+		}
+		fmt.Fprintf(w, "  %s: %d examined, %d needed a Name\n",
+			resourceTypeLabel(resourceType, 2), s.examined[resourceType], s.needsName[resourceType])
+	}
+
+	operations := make([]string, 0, len(s.pages))
+	for operation := range s.pages {
+		operations = append(operations, operation)
+	}
+	sort.Strings(operations)
+	var parts []string
+	for _, operation := range operations {
+		parts = append(parts, fmt.Sprintf("%s %d", operation, s.pages[operation]))
+	}
+	if len(parts) > 0 {
+		fmt.Fprintf(w, "  Pages fetched: %s\n", strings.Join(parts, ", "))
+	}
+	fmt.Fprintf(w, "  CreateTags calls: %d\n", s.createTagCalls)
+}
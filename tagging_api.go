@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi/types"
+	"github.com/bevelwork/quick_tag/naming"
+)
+
+// taggingAPIResourceTypes maps Resource Groups Tagging API resource types to quick-tag types
+var taggingAPIResourceTypes = map[string]string{
+	"instance":          "instance",
+	"volume":            "volume",
+	"network-interface": "eni",
+	"elastic-ip":        "eip",
+	"vpc":               "vpc",
+	"subnet":            "subnet",
+	"route-table":       "route-table",
+	"natgateway":        "nat-gateway",
+	"internet-gateway":  "igw",
+	"transit-gateway":   "tgw",
+}
+
+// findTaggingAPICandidates lists resources with no Name tag, an empty Name, or a generated
+// Name using one paginated GetResources scan, and groups their IDs by type so the EC2
+// describe scans only look at those resources. When targetIDs is set, only those IDs are kept.
+// Names that break policy are candidates too.
+//
+// GetResources only returns resources that have (or once had) at least one tag, so resources
+// that were never tagged at all are not found by this engine.
+func findTaggingAPICandidates(ctx context.Context, client resourcegroupstaggingapi.GetResourcesAPIClient, targetIDs map[string][]string, tagPrefix, tagSuffix string, policy namePolicy, maxPages int) (map[string][]string, error) {
+	var resourceTypeFilters []string
+	for apiType := range taggingAPIResourceTypes {
+		resourceTypeFilters = append(resourceTypeFilters, "ec2:"+apiType)
+	}
+	slices.Sort(resourceTypeFilters)
+
+	paginator := resourcegroupstaggingapi.NewGetResourcesPaginator(client, &resourcegroupstaggingapi.GetResourcesInput{
+		ResourceTypeFilters: resourceTypeFilters,
+	})
+
+	candidates := make(map[string][]string)
+	pages, scanned := 0, 0
+	for morePages(paginator.HasMorePages(), pages, maxPages, "GetResources") {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, wrapPermissionError(err, "tag:GetResources", "resources")
+		}
+		pages++
+		scanned += len(output.ResourceTagMappingList)
+
+		for _, mapping := range output.ResourceTagMappingList {
+			resourceType, id, ok := parseEC2ResourceARN(aws.ToString(mapping.ResourceARN))
+			if !ok {
+				continue
+			}
+			if targetIDs != nil && !slices.Contains(targetIDs[resourceType], id) {
+				continue
+			}
+			if !taggingAPINeedsName(resourceType, mapping.Tags, tagPrefix, tagSuffix, policy) {
+				continue
+			}
+			candidates[resourceType] = append(candidates[resourceType], id)
+		}
+	}
+
+	debugf("GetResources: %d pages, %d resources scanned", pages, scanned)
+	return candidates, nil
+}
+
+// parseEC2ResourceARN extracts the quick-tag type and ID from an EC2 resource ARN like
+// arn:aws:ec2:us-east-1:123456789012:instance/i-1234567890abcdef0
+func parseEC2ResourceARN(arn string) (string, string, bool) {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[2] != "ec2" {
+		return "", "", false
+	}
+	apiType, id, found := strings.Cut(parts[5], "/")
+	if !found {
+		return "", "", false
+	}
+	resourceType, ok := taggingAPIResourceTypes[apiType]
+	return resourceType, id, ok
+}
+
+// taggingAPINeedsName reports whether a resource from GetResources may need a Name tag
+func taggingAPINeedsName(resourceType string, tags []types.Tag, tagPrefix, tagSuffix string, policy namePolicy) bool {
+	for _, tag := range tags {
+		if aws.ToString(tag.Key) != "Name" {
+			continue
+		}
+		if policy.violates(resourceType, aws.ToString(tag.Value)) {
+			return true
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(aws.ToString(tag.Value), tagPrefix), tagSuffix)
+		// Generated names still go to the EC2 scan, which checks them against current state
+		return name == "" || naming.IsQuickTagCreatedName(name, resourceType) || hasTaggingAPIMarker(tags)
+	}
+	return true
+}
+
+// hasTaggingAPIMarker reports whether the tags include the quick-tag marker tag
+func hasTaggingAPIMarker(tags []types.Tag) bool {
+	for _, tag := range tags {
+		if aws.ToString(tag.Key) == markerTagKey && aws.ToString(tag.Value) == "true" {
+			return true
+		}
+	}
+	return false
+}
+
+// validEngines lists the supported --engine values
+var validEngines = []string{"ec2", "tagging-api"}
+
+// validateEngine checks an --engine value
+func validateEngine(engine string) error {
+	if !slices.Contains(validEngines, engine) {
+		return fmt.Errorf("invalid --engine %q: must be %s", engine, strings.Join(validEngines, " or "))
+	}
+	return nil
+}
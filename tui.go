@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	qc "github.com/bevelwork/quick_color"
+	"golang.org/x/term"
+)
+
+// tuiKey is a decoded keypress in the --tui selection list
+type tuiKey int
+
+const (
+	tuiKeyOther tuiKey = iota
+	tuiKeyUp
+	tuiKeyDown
+	tuiKeyToggle
+	tuiKeyToggleAll
+	tuiKeyConfirm
+	tuiKeyCancel
+)
+
+// tuiSelection is the state of the --tui checkbox list
+type tuiSelection struct {
+	resources []*ResourceInfo
+	checked   []bool
+	cursor    int
+	offset    int // Index of the first visible row
+}
+
+// newTUISelection starts a selection with nothing checked
+func newTUISelection(resources []*ResourceInfo) *tuiSelection {
+	return &tuiSelection{resources: resources, checked: make([]bool, len(resources))}
+}
+
+// handle applies one keypress and reports whether the selection is finished
+func (s *tuiSelection) handle(key tuiKey) (done, cancelled bool) {
+	switch key {
+	case tuiKeyUp:
+		if s.cursor > 0 {
+			s.cursor--
+		}
+	case tuiKeyDown:
+		if s.cursor < len(s.resources)-1 {
+			s.cursor++
+		}
+	case tuiKeyToggle:
+		s.checked[s.cursor] = !s.checked[s.cursor]
+	case tuiKeyToggleAll:
+		// Check everything, or clear everything when it's all checked already
+		all := true
+		for _, checked := range s.checked {
+			all = all && checked
+		}
+		for i := range s.checked {
+			s.checked[i] = !all
+		}
+	case tuiKeyConfirm:
+		return true, false
+	case tuiKeyCancel:
+		return true, true
+	}
+	return false, false
+}
+
+// selected returns the checked resources in list order
+func (s *tuiSelection) selected() []*ResourceInfo {
+	var selected []*ResourceInfo
+	for i, resource := range s.resources {
+		if s.checked[i] {
+			selected = append(selected, resource)
+		}
+	}
+	return selected
+}
+
+// render draws the list, scrolled so the cursor stays within height rows. Lines end in
+// \r\n because the terminal is in raw mode.
+func (s *tuiSelection) render(w io.Writer, height int) {
+	height = max(height, 1)
+	if s.cursor < s.offset {
+		s.offset = s.cursor
+	}
+	if s.cursor >= s.offset+height {
+		s.offset = s.cursor - height + 1
+	}
+
+	longestID := 0
+	for _, resource := range s.resources {
+		longestID = max(longestID, len(resource.ID))
+	}
+
+	fmt.Fprintf(w, "%s\r\n", color("Select resources to tag (↑/↓ move, space toggle, a all, enter confirm, q cancel):", qc.ColorYellow))
+	for i := s.offset; i < len(s.resources) && i < s.offset+height; i++ {
+		resource := s.resources[i]
+		pointer, box := " ", "[ ]"
+		if i == s.cursor {
+			pointer = color(">", qc.ColorCyan)
+		}
+		if s.checked[i] {
+			box = color("[x]", qc.ColorGreen)
+		}
+		fmt.Fprintf(w, "%s %s %-*s %s -> %s%s\r\n", pointer, box, longestID, resource.ID,
+			colorCurrentName(resource), color(resource.SuggestedName, qc.ColorGreen), nameSourceNote(resource))
+	}
+	fmt.Fprintf(w, "%d of %d selected\r\n", len(s.selected()), len(s.resources))
+}
+
+// readTUIKey reads and decodes one keypress from a raw-mode terminal
+func readTUIKey(reader *bufio.Reader) (tuiKey, error) {
+	b, err := reader.ReadByte()
+	if err != nil {
+		return tuiKeyOther, err
+	}
+	switch b {
+	case 'k':
+		return tuiKeyUp, nil
+	case 'j':
+		return tuiKeyDown, nil
+	case ' ', 'x':
+		return tuiKeyToggle, nil
+	case 'a':
+		return tuiKeyToggleAll, nil
+	case '\r', '\n':
+		return tuiKeyConfirm, nil
+	case 'q', 3: // 3 is Ctrl+C, which raw mode delivers as a byte instead of a signal
+		return tuiKeyCancel, nil
+	case 27:
+		// A lone Esc cancels; arrow keys arrive together as Esc [ A or Esc [ B
+		if reader.Buffered() == 0 {
+			return tuiKeyCancel, nil
+		}
+		if next, _ := reader.ReadByte(); next != '[' {
+			return tuiKeyOther, nil
+		}
+		switch arrow, _ := reader.ReadByte(); arrow {
+		case 'A':
+			return tuiKeyUp, nil
+		case 'B':
+			return tuiKeyDown, nil
+		}
+	}
+	return tuiKeyOther, nil
+}
+
+// tuiChromeLines is the number of lines render draws around the resource rows
+const tuiChromeLines = 2
+
+// selectResourcesTUI is the --tui version of selectResources: an arrow-key checkbox list on
+// the alternate screen. Selected resources are still confirmed tag by tag. It falls back to
+// the text prompt when the terminal can't be put in raw mode.
+func selectResourcesTUI(ctx context.Context, resources []*ResourceInfo) ([]*ResourceInfo, bool) {
+	stdinFd, stdoutFd := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	oldState, err := term.MakeRaw(stdinFd)
+	if err != nil {
+		debugf("--tui unavailable, using the text prompt: %v", err)
+		return selectResources(ctx, resources)
+	}
+	restore := func() {
+		fmt.Print("\x1b[?25h\x1b[?1049l") // Show the cursor and leave the alternate screen
+		term.Restore(stdinFd, oldState)
+	}
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+
+	selection := newTUISelection(resources)
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		height := len(resources)
+		if _, rows, err := term.GetSize(stdoutFd); err == nil {
+			height = rows - tuiChromeLines
+		}
+		fmt.Print("\x1b[H\x1b[J")
+		selection.render(os.Stdout, height)
+
+		key, err := waitTUIKey(ctx, reader)
+		switch {
+		case errors.Is(err, errPromptTimeout):
+			restore()
+			fmt.Printf("%s %v, selecting nothing.\n", color("ℹ️", qc.ColorCyan), err)
+			return nil, false
+		case ctx.Err() != nil:
+			restore()
+			fatal(ctx, ctx.Err())
+		case err != nil:
+			restore()
+			fatal(ctx, fmt.Errorf("failed to read user input: %v", err))
+		}
+		if done, cancelled := selection.handle(key); done {
+			restore()
+			if cancelled {
+				return nil, false
+			}
+			return selection.selected(), false // false = prompt for each tag
+		}
+	}
+}
+
+// waitTUIKey is readTUIKey with the same cancellation as readLine: it returns early when ctx
+// is done (--timeout, SIGTERM) or --prompt-timeout passes without a keypress
+func waitTUIKey(ctx context.Context, reader *bufio.Reader) (tuiKey, error) {
+	if promptAbandoned.Load() {
+		return tuiKeyOther, errPromptTimeout
+	}
+
+	type result struct {
+		key tuiKey
+		err error
+	}
+
+	ch := make(chan result, 1)
+	go func() {
+		key, err := readTUIKey(reader)
+		ch <- result{key, err}
+	}()
+
+	var expired <-chan time.Time
+	if promptTimeout > 0 {
+		timer := time.NewTimer(promptTimeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case <-ctx.Done():
+		promptAbandoned.Store(true)
+		return tuiKeyOther, ctx.Err()
+	case <-expired:
+		promptAbandoned.Store(true)
+		return tuiKeyOther, errPromptTimeout
+	case res := <-ch:
+		return res.key, res.err
+	}
+}
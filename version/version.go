@@ -14,3 +14,12 @@ const (
 
 // Full is the complete version string. Keep this in sync with Major, Minor, and PatchDate.
 var Full = fmt.Sprintf("%d.%d.%s", Major, Minor, PatchDate)
+
+// Commit and BuildDate identify the exact build. They are empty unless injected with ldflags:
+//
+//	go build -ldflags "-X github.com/bevelwork/quick_tag/version.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/bevelwork/quick_tag/version.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	Commit    = ""
+	BuildDate = ""
+)